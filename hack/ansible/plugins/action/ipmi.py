@@ -6,9 +6,18 @@ from abc import ABCMeta, abstractmethod
 import ssl
 import urllib2
 import json
+import os
 import subprocess
 
 from ansible.plugins.action import ActionBase
+try:
+    from ansible.module_utils.ivy_secret import resolve_secret
+except ImportError:
+    # Action plugins run on the controller, where module_utils of this
+    # directory are not importable unless configured in ansible.cfg.
+    import sys
+    sys.path.append(os.path.join(os.path.dirname(os.path.abspath(__file__)), '..', 'module_utils'))
+    from ivy_secret import resolve_secret
 
 class ActionModule(ActionBase):
     '''IPMI Action Module for Ansible 2.3+
@@ -44,6 +53,12 @@ class ActionModule(ActionBase):
             self._ipmi_pass = task_vars["ipmi_pass"]
         except KeyError:
             self._ipmi_pass = None
+        if 'ipmi_secret' in task_vars:
+            try:
+                self._ipmi_pass = resolve_secret(task_vars['ipmi_secret'])
+            except Exception as e:
+                self._fail_with_message('Could not resolve IPMI secret due to: %s' % e)
+                return self._result.export()
 
         self._result.ansible_facts['ipmi_address'] = self._ipmi_addr
         adapter = self._initiate()
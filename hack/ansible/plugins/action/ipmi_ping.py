@@ -1,9 +1,18 @@
 from __future__ import (absolute_import, division, print_function)
 __metaclass__ = type
 
+import os
 import subprocess
 
 from ansible.plugins.action import ActionBase
+try:
+    from ansible.module_utils.ivy_secret import resolve_secret
+except ImportError:
+    # Action plugins run on the controller, where module_utils of this
+    # directory are not importable unless configured in ansible.cfg.
+    import sys
+    sys.path.append(os.path.join(os.path.dirname(os.path.abspath(__file__)), '..', 'module_utils'))
+    from ivy_secret import resolve_secret
 
 class ActionModule(ActionBase):
     '''IPMI Ping Module for Ansible 2.3+
@@ -31,6 +40,13 @@ class ActionModule(ActionBase):
             self._ipmi_pass = task_vars["ipmi_pass"]
         except KeyError:
             self._ipmi_pass = None
+        if 'ipmi_secret' in task_vars:
+            try:
+                self._ipmi_pass = resolve_secret(task_vars['ipmi_secret'])
+            except Exception as e:
+                result['failed'] = True
+                result['msg'] = 'Could not resolve IPMI secret due to: %s' % e
+                return result
 
         if not self._ipmi_addr or not self._ipmi_user or not self._ipmi_pass:
             result['failed'] = True
@@ -0,0 +1,71 @@
+from __future__ import (absolute_import, division, print_function)
+__metaclass__ = type
+
+import os
+import shlex
+import subprocess
+import threading
+
+# Names the variable listing absolute paths of commands which 'exec:'
+# references may run, separated like PATH. References are kept in the CMDB,
+# so they are refused unless their command is allowed by this machine.
+EXEC_ALLOWLIST_ENV = 'IVY_SECRET_EXEC_ALLOWLIST'
+
+# Seconds a command referenced by 'exec:' may run before it is killed.
+EXEC_TIMEOUT = 10
+
+
+def _exec_allowed(command):
+    if not os.path.isabs(command):
+        return False
+    for each in os.environ.get(EXEC_ALLOWLIST_ENV, '').split(os.pathsep):
+        if os.path.isabs(each) and os.path.normpath(each) == os.path.normpath(command):
+            return True
+    return False
+
+
+def _run(ref, args):
+    proc = subprocess.Popen(args, stdout=subprocess.PIPE)
+    timer = threading.Timer(EXEC_TIMEOUT, proc.kill)
+    timer.start()
+    try:
+        out, _ = proc.communicate()
+    finally:
+        expired = not timer.is_alive()
+        timer.cancel()
+    if expired:
+        raise RuntimeError('Could not read secret %s: command did not finish in time' % ref)
+    if proc.returncode != 0:
+        raise RuntimeError('Could not read secret %s: command exited with %d' % (ref, proc.returncode))
+    if not isinstance(out, str):
+        out = out.decode('utf-8')
+    return out
+
+
+def resolve_secret(ref):
+    '''Read the secret referenced by 'file:///path', 'env:NAME' or
+    'exec:/path/to/command [args...]', with trailing line breaks stripped.
+    Commands run only if listed by IVY_SECRET_EXEC_ALLOWLIST, and are killed
+    after EXEC_TIMEOUT seconds.
+    '''
+    scheme, _, target = ref.partition(':')
+    if scheme == 'file':
+        if not target.startswith('//'):
+            raise ValueError('Invalid secret reference: %s' % ref)
+        path = target[2:]
+        if path.startswith('localhost/'):
+            path = path[len('localhost'):]
+        with open(path) as f:
+            value = f.read()
+    elif scheme == 'env':
+        value = os.environ[target]
+    elif scheme == 'exec':
+        args = shlex.split(target)
+        if not args:
+            raise ValueError('Invalid secret reference: %s' % ref)
+        if not _exec_allowed(args[0]):
+            raise ValueError('Command %s of secret %s is not allowed by %s' % (args[0], ref, EXEC_ALLOWLIST_ENV))
+        value = _run(ref, args)
+    else:
+        raise ValueError('Unsupported secret reference: %s' % ref)
+    return value.rstrip('\r\n')
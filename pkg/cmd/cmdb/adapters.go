@@ -0,0 +1,53 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"os"
+
+	tablewriter "github.com/olekukonko/tablewriter"
+	cobra "github.com/spf13/cobra"
+	storage "github.com/universonic/ivy-utils/pkg/storage"
+)
+
+// adaptersCmd represents the adapters command
+var adaptersCmd = &cobra.Command{
+	Use:   "adapters",
+	Short: "List available storage adapters",
+	Long:  `List available storage adapters and their configuration options, which could be used in '--config', '--config-file' or '--config-env'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Adapter", "Option", "Type", "Default", "Description"})
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		for _, adapter := range storage.Adapters() {
+			if len(adapter.Schema) == 0 {
+				table.Append([]string{adapter.Name, "", "", "", ""})
+				continue
+			}
+			for i, option := range adapter.Schema {
+				name := adapter.Name
+				if i > 0 {
+					name = ""
+				}
+				table.Append([]string{name, option.Name, option.Type, option.Default, option.Description})
+			}
+		}
+		table.Render()
+	},
+}
+
+func init() {
+	cmdbCmd.AddCommand(adaptersCmd)
+}
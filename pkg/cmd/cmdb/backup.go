@@ -0,0 +1,83 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"fmt"
+	"os"
+
+	cobra "github.com/spf13/cobra"
+	cmdbutil "github.com/universonic/ivy-utils/pkg/utils/cmdb"
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Export all hosts, trashed hosts and credential profiles into an archive",
+	Long: `Export all hosts, trashed hosts and credential profiles into a gzipped tar
+archive, which could be restored into any storage adapter with 'ivy-utils cmdb
+restore'. The archive has a manifest recording the schema version, numbers of
+entities and checksums of its files. History of hosts is not exported.
+
+Sensitive fields are exported in plain text if encryption is configured, hence
+the archive is only readable by its owner and has to be kept safe.
+
+Example:
+  ivy-utils cmdb backup -o cmdb.tar.gz`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if backupOutput == "" {
+			fmt.Fprintf(os.Stderr, "Output file must be specified.\n")
+			os.Exit(2)
+		}
+		storage, err := NewStorageFromArgs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not spawn storage due to: %v\n", err)
+			os.Exit(10)
+		}
+		defer storage.Close()
+		ctx, cancel := interruptibleContext()
+		defer cancel()
+		f, err := os.OpenFile(backupOutput, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not create output file due to: %v\n", err)
+			os.Exit(1)
+		}
+		inventory := cmdbutil.NewInventoryFromStorage(storage)
+		manifest, err := inventory.Backup(ctx, f)
+		if err == nil {
+			err = f.Close()
+		} else {
+			f.Close()
+		}
+		if err != nil {
+			os.Remove(backupOutput)
+			fmt.Fprintf(os.Stderr, "Could not back up due to: %v\n", err)
+			os.Exit(12)
+		}
+		fmt.Fprintf(os.Stdout, "Successfully backed up %d hosts, %d trashed hosts and %d credential profiles into %s.\n", manifest.Hosts, manifest.TrashedHosts, manifest.CredentialProfiles, backupOutput)
+	},
+}
+
+var (
+	backupOutput string
+)
+
+func init() {
+	cmdbCmd.AddCommand(backupCmd)
+
+	backupCmd.Flags().StringVarP(
+		&backupOutput, "output", "o", backupOutput, "File to write the archive into",
+	)
+}
@@ -15,8 +15,11 @@
 package cmdb
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	cobra "github.com/spf13/cobra"
 	storage "github.com/universonic/ivy-utils/pkg/storage"
@@ -38,6 +41,7 @@ var (
 	config        string
 	configEnvName string
 	configFile    string
+	namespaceName string
 )
 
 // AttachTo attach subcommands onto parent command
@@ -46,18 +50,55 @@ func AttachTo(parentCmd *cobra.Command, parentParams ...interface{}) {
 }
 
 func NewStorageFromArgs() (storagecore.Storage, error) {
-	logger := zap.NewNop().Sugar()
+	cfg, err := storageConfigFromArgs()
+	if err != nil {
+		return nil, err
+	}
+	if namespaceName != "" {
+		if cfg.Adapter != "etcd" {
+			return nil, fmt.Errorf("Namespaces are only supported by etcd storage")
+		}
+		if cfg.Config == nil {
+			cfg.Config = make(map[string]interface{})
+		}
+		cfg.Config["namespace"] = namespaceName
+	}
+	return storage.NewStorage(cfg, zap.NewNop().Sugar())
+}
+
+// storageConfigFromArgs parses storage configuration given by '--config',
+// '--config-file' or '--config-env'.
+func storageConfigFromArgs() (*storage.StorageConfig, error) {
 	if config != "" {
-		return storage.NewStorageFromConfigBytes([]byte(config), logger)
+		return storage.NewStorageConfigFromBytes([]byte(config))
 	} else if configFile != "" {
-		return storage.NewStorageFromConfigFile(configFile, logger)
+		return storage.NewStorageConfigFromFile(configFile)
 	} else if configEnvName != "" {
 		env := os.Getenv(configEnvName)
-		return storage.NewStorageFromConfigBytes([]byte(env), logger)
+		return storage.NewStorageConfigFromBytes([]byte(env))
 	}
 	return nil, fmt.Errorf("Database configuration not specified")
 }
 
+// interruptibleContext returns a context which is cancelled once SIGINT or
+// SIGTERM is received, so that running operations could give up cleanly. A
+// further signal terminates the process as usual.
+func interruptibleContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigChan:
+			fmt.Fprintln(os.Stderr, "Interrupted, waiting for running operations to stop...")
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sigChan)
+	}()
+	return ctx, cancel
+}
+
 func init() {
 
 	// cmdbCmd.PersistentFlags().StringVarP(
@@ -72,4 +113,7 @@ func init() {
 	cmdbCmd.PersistentFlags().StringVar(
 		&configEnvName, "config-env", configEnvName, "Environment variable name to store configuration of CMDB. Its data must be in JSON format.",
 	)
+	cmdbCmd.PersistentFlags().StringVar(
+		&namespaceName, "namespace", namespaceName, "Namespace of etcd storage to use, which overrides the one in configuration. See 'ivy-utils cmdb namespace'.",
+	)
 }
@@ -0,0 +1,130 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	tablewriter "github.com/olekukonko/tablewriter"
+	cobra "github.com/spf13/cobra"
+	storagecore "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show history of CMDB host entities",
+	Long: `Show previous versions of a host with field-level changes, or show hosts as of a given revision with '--revision'.
+
+Examples:
+  ivy-utils cmdb history HOST
+  ivy-utils cmdb history --revision 42
+  ivy-utils cmdb history --revision 42 HOST...`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if historyRevision == 0 && len(args) != 1 {
+			fmt.Fprintf(os.Stderr, "Only a single host must be specified in arguments\n")
+			os.Exit(2)
+		}
+		storage, err := NewStorageFromArgs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not spawn storage due to: %v\n", err)
+			os.Exit(10)
+		}
+		defer storage.Close()
+		ctx, cancel := interruptibleContext()
+		defer cancel()
+		if historyRevision != 0 {
+			hosts, err := storage.ListHostAt(ctx, historyRevision)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not retrieve data from database at revision %d due to: %v\n", historyRevision, err)
+				os.Exit(12)
+			}
+			if len(args) != 0 {
+				selected := make(map[string]bool)
+				for _, each := range args {
+					selected[each] = true
+				}
+				var filtered []storagecore.Host
+				for _, host := range hosts {
+					if selected[host.Hostname] {
+						filtered = append(filtered, host)
+					}
+				}
+				hosts = filtered
+			}
+			fmt.Fprintf(os.Stdout, "%s\n", storagecore.HostList(hosts).CanonicalString())
+			return
+		}
+		history, err := storage.HostHistory(ctx, args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not retrieve history of host '%s' due to: %v\n", args[0], err)
+			os.Exit(12)
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Revision", "Updated At", "Field", "Old Value", "New Value"})
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		for i, each := range history {
+			// history is sorted from the newest to the oldest, so the oldest
+			// version is compared with an empty host.
+			var previous storagecore.Host
+			if i+1 < len(history) {
+				previous = history[i+1].Host
+			}
+			changes, err := storagecore.DiffHost(previous, each.Host)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not compare revisions of host '%s' due to: %v\n", args[0], err)
+				os.Exit(12)
+			}
+			revision := strconv.FormatInt(each.Revision, 10)
+			var updatedAt string
+			if !each.Host.UpdatedAt.IsZero() {
+				updatedAt = each.Host.UpdatedAt.Local().Format(time.RFC3339)
+			}
+			if len(changes) == 0 {
+				table.Append([]string{revision, updatedAt, "", "", ""})
+			}
+			for j, change := range changes {
+				if j > 0 {
+					revision, updatedAt = "", ""
+				}
+				if change.Field == "ipmi_pass" {
+					if change.Old != "" {
+						change.Old = "******"
+					}
+					if change.New != "" {
+						change.New = "******"
+					}
+				}
+				table.Append([]string{revision, updatedAt, change.Field, change.Old, change.New})
+			}
+		}
+		table.Render()
+	},
+}
+
+var (
+	historyRevision int64
+)
+
+func init() {
+	cmdbCmd.AddCommand(historyCmd)
+
+	historyCmd.Flags().Int64Var(
+		&historyRevision, "revision", historyRevision, "Show hosts as of the given revision instead of history.",
+	)
+}
@@ -40,6 +40,8 @@ var locateCmd = &cobra.Command{
 			os.Exit(10)
 		}
 		defer storage.Close()
+		ctx, cancel := interruptibleContext()
+		defer cancel()
 		if modify {
 			if location == "" {
 				fmt.Fprintf(os.Stderr, "'--modify' flag must be used with '--location KEY=VALUE'\n")
@@ -51,7 +53,7 @@ var locateCmd = &cobra.Command{
 				os.Exit(1)
 			}
 			manager := cmdbutil.NewHostLocationManager(hostID, storage)
-			err = manager.Set(kv[0], kv[1])
+			err = manager.Set(ctx, kv[0], kv[1])
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Could not modify host location due to: %v\n", err)
 				os.Exit(20)
@@ -59,7 +61,7 @@ var locateCmd = &cobra.Command{
 			return
 		}
 		manager := cmdbutil.NewHostLocationManager(hostID, storage)
-		err = manager.Describe()
+		err = manager.Describe(ctx)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Could not locate host due to: %v\n", err)
 			os.Exit(20)
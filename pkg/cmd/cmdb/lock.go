@@ -0,0 +1,98 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"fmt"
+	"os"
+
+	cobra "github.com/spf13/cobra"
+	storagecore "github.com/universonic/ivy-utils/pkg/storage/core"
+	cmdbutil "github.com/universonic/ivy-utils/pkg/utils/cmdb"
+)
+
+// lockCmd represents the lock command
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Inspect and release locks of hosts",
+	Long: `Inspect and release locks of hosts. Commands modifying a host, such as
+'ivy-utils cmdb locate --modify' and 'ivy-utils cmdb manage --update', hold its
+lock until they are done, so that operators would not modify the same host at
+the same time. A lock expires shortly after its holder is gone.
+
+Locks are only supported by etcd storage, and are limited to the process by
+memory storage.`,
+}
+
+// lockListCmd represents the lock list command
+var lockListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List locks of hosts with their holders",
+	Run: func(cmd *cobra.Command, args []string) {
+		storage, err := NewStorageFromArgs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not spawn storage due to: %v\n", err)
+			os.Exit(10)
+		}
+		defer storage.Close()
+		ctx, cancel := interruptibleContext()
+		defer cancel()
+		inventory := cmdbutil.NewInventoryFromStorage(storage)
+		locks, err := inventory.ListLocks(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not retrieve data from database due to: %v\n", err)
+			os.Exit(12)
+		}
+		fmt.Fprintf(os.Stdout, "%s\n", storagecore.HostLockList(locks).CanonicalString())
+	},
+}
+
+// lockForceUnlockCmd represents the lock force-unlock command
+var lockForceUnlockCmd = &cobra.Command{
+	Use:   "force-unlock HOSTNAME",
+	Short: "Release the lock of host regardless of its holder",
+	Long: `Release the lock of host regardless of its holder, who is not notified and
+might still be modifying the host. Make sure the holder is gone before using it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Fprintf(os.Stderr, "Only a single host must be specified in arguments\n")
+			os.Exit(2)
+		}
+		storage, err := NewStorageFromArgs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not spawn storage due to: %v\n", err)
+			os.Exit(10)
+		}
+		defer storage.Close()
+		ctx, cancel := interruptibleContext()
+		defer cancel()
+		inventory := cmdbutil.NewInventoryFromStorage(storage)
+		err = inventory.ForceUnlock(ctx, args[0])
+		if err == storagecore.ErrResourceNotFound {
+			fmt.Fprintf(os.Stderr, "Host '%s' is not locked.\n", args[0])
+			os.Exit(11)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not commit changes to database due to: %v\n", err)
+			os.Exit(11)
+		}
+		fmt.Fprintf(os.Stdout, "Successfully unlocked.\n")
+	},
+}
+
+func init() {
+	cmdbCmd.AddCommand(lockCmd)
+	lockCmd.AddCommand(lockListCmd)
+	lockCmd.AddCommand(lockForceUnlockCmd)
+}
@@ -16,19 +16,57 @@ package cmdb
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 
+	tablewriter "github.com/olekukonko/tablewriter"
 	cobra "github.com/spf13/cobra"
 	storagecore "github.com/universonic/ivy-utils/pkg/storage/core"
 	cmdbutil "github.com/universonic/ivy-utils/pkg/utils/cmdb"
+	yaml "gopkg.in/yaml.v2"
 )
 
 // manageCmd represents the manage command
 var manageCmd = &cobra.Command{
 	Use:   "manage",
 	Short: "Manage CMDB host entities",
-	Long:  `Manage CMDB host entities.`,
+	Long: `Manage CMDB host entities.
+
+Removed hosts are moved into trash bin, and could be restored with '--restore'
+until they are purged after the retention period of storage. Use '--permanent'
+together with '--remove' to delete a host for good, or '--trash' to list hosts
+in trash bin.
+
+Hosts could be filtered with '--selector', which consists of comma-separated
+predicates on host fields, e.g. 'ssh_user=root,extra_info.department!=ops'.
+Use '--limit' to list a large fleet page by page, where the following page is
+fetched by passing the printed token with '--continue'.
+
+Hosts could also be looked up by another field with '--by', e.g.
+  ivy-utils cmdb manage --by ipmi-address 10.0.0.1
+
+Instead of giving IPMI credential to every host, hosts could share a credential
+profile, see 'ivy-utils cmdb profile', e.g.
+  ivy-utils cmdb manage --add --ipmi-address 10.0.0.1 --credential-profile dell-default node-01
+
+Multiple changes could be applied atomically with '--batch', where either all
+of them or none is applied. The file is a YAML or JSON list of changes, whose
+action is one of 'create', 'update' and 'delete', e.g.
+  - action: update
+    host: {hostname: node-01, ipmi_addr: 10.0.0.2}
+  - action: create
+    host: {hostname: node-02, ipmi_addr: 10.0.0.1, credential_profile: dell-default}
+  - action: delete
+    host: {hostname: node-03}
+and is applied with
+  ivy-utils cmdb manage --batch -f changes.yaml
+
+Storages tracking revisions, e.g. etcd, print the revision at which each host
+was last changed. Pass it with '--if-revision' to update the host only if no
+one else has changed it since then, e.g.
+  ivy-utils cmdb manage --update --ipmi-address 10.0.0.2 --if-revision 42 node-01`,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		for _, each := range extraInfoOrig {
 			kv := strings.Split(each, "=")
@@ -46,12 +84,18 @@ var manageCmd = &cobra.Command{
 			fmt.Fprintf(os.Stderr, "Multiple action flags was given.\n")
 			os.Exit(1)
 		}
+		if ifRevision != 0 && !updateHost {
+			fmt.Fprintf(os.Stderr, "Flag '--if-revision' could only be used with '--update'.\n")
+			os.Exit(1)
+		}
 		storage, err := NewStorageFromArgs()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Could not spawn storage due to: %v\n", err)
 			os.Exit(10)
 		}
 		defer storage.Close()
+		ctx, cancel := interruptibleContext()
+		defer cancel()
 		if hostComment != "" {
 			host.ExtraInfo["comment"] = hostComment
 		}
@@ -59,54 +103,177 @@ var manageCmd = &cobra.Command{
 			host.ExtraInfo["department"] = hostDept
 		}
 		inventory := cmdbutil.NewInventoryFromStorage(storage)
+		// os.Exit skips deferred calls, hence locks of hosts are released
+		// by exit explicitly
+		unlock := func() error { return nil }
+		defer func() { unlock() }()
+		exit := func(code int) {
+			unlock()
+			os.Exit(code)
+		}
+		if applyBatch {
+			ops, err := readBatchFile(batchFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(2)
+			}
+			hostnames := make([]string, 0, len(ops))
+			for _, each := range ops {
+				hostnames = append(hostnames, each.Host.Hostname)
+			}
+			locked, err := inventory.LockAll(ctx, hostnames, "cmdb manage --batch", cmdbutil.DefaultLockWait)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not lock hosts due to: %v\n", err)
+				os.Exit(11)
+			}
+			unlock = locked
+			err = inventory.ApplyBatch(ctx, ops)
+			if batchErr, ok := err.(*storagecore.BatchError); ok {
+				fmt.Fprintf(os.Stderr, "None of changes was committed, because %d of them failed:\n", len(batchErr.Failures))
+				for _, each := range batchErr.Failures {
+					fmt.Fprintf(os.Stderr, "  #%d %s '%s': %v\n", each.Index, ops[each.Index].Action, each.Hostname, each.Err)
+				}
+				exit(11)
+			} else if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not commit changes to database due to: %v\n", err)
+				exit(11)
+			}
+			fmt.Fprintf(os.Stdout, "Successfully applied %d changes.\n", len(ops))
+			return
+		}
 		if isAction {
 			if len(args) == 0 {
 				fmt.Fprintf(os.Stderr, "At least one hostname must be specified.\n")
 				os.Exit(2)
 			}
 			host.Hostname = args[0]
+			if !addHost {
+				hostnames := []string{host.Hostname}
+				if renameTo != "" {
+					hostnames = append(hostnames, renameTo)
+				}
+				locked, err := inventory.LockAll(ctx, hostnames, "cmdb manage", cmdbutil.DefaultLockWait)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Could not lock host due to: %v\n", err)
+					os.Exit(11)
+				}
+				unlock = locked
+			}
 			verify := func(hostname string) {
-				host, err := inventory.Get(hostname)
+				host, err := inventory.Get(ctx, hostname)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Could not retrieve data from database with key '%s' due to: %v\n", hostname, err)
-					os.Exit(12)
+					exit(12)
 				}
 				fmt.Fprintf(os.Stdout, "%s\n", host.CanonicalString())
 			}
 			if addHost {
-				if host.IPMIAddress == "" || host.IPMIUser == "" || host.IPMIPassword == "" {
+				if !hasIPMICredential(*host) {
 					fmt.Fprintf(os.Stderr, "IPMI endpoint and credential are required\n")
-					os.Exit(2)
+					exit(2)
 				}
-				err = inventory.Add(*host)
+				err = inventory.Add(ctx, *host)
 			} else if removeHost {
-				err = inventory.Delete(host.Hostname)
+				if permanentRemove {
+					err = inventory.Delete(ctx, host.Hostname)
+				} else {
+					err = inventory.Trash(ctx, host.Hostname, removeReason)
+				}
 				if err == nil {
 					fmt.Fprintf(os.Stdout, "Successfully deleted.\n")
 					goto SKIP_VALIDATION
 				}
+			} else if restoreHost {
+				err = inventory.Restore(ctx, host.Hostname)
+			} else if renameTo != "" {
+				err = inventory.Rename(ctx, host.Hostname, renameTo)
+				if err == storagecore.ErrResourceAlreadyExists {
+					fmt.Fprintf(os.Stderr, "Host '%s' already exists and will not be overwritten.\n", renameTo)
+					exit(11)
+				}
+				host.Hostname = renameTo
+			} else if updateHost && ifRevision != 0 {
+				err = inventory.CompareAndUpdate(ctx, *host, ifRevision)
+				if err == storagecore.ErrRevisionMismatch {
+					fmt.Fprintf(os.Stderr, "Host '%s' was changed since revision %d, please check it again.\n", host.Hostname, ifRevision)
+					exit(11)
+				}
 			} else if updateHost {
-				err = inventory.Update(*host)
+				err = inventory.Update(ctx, *host)
 			}
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Could not commit changes to database due to: %v\n", err)
-				os.Exit(11)
+				exit(11)
 			}
 			verify(host.Hostname)
 		SKIP_VALIDATION:
 			return
 		}
+		if listTrash {
+			trashed, err := inventory.ListTrash(ctx)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not retrieve data from database due to: %v\n", err)
+				os.Exit(12)
+			}
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Hostname", "GUID", "Deleted At", "Reason"})
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			for _, each := range trashed {
+				table.Append([]string{
+					each.Host.Hostname,
+					each.Host.GUID,
+					each.DeletedAt.Local().Format(time.RFC3339),
+					each.Reason,
+				})
+			}
+			table.Render()
+			return
+		}
 		var hosts []storagecore.Host
-		if allHosts {
-			hosts, err = inventory.List()
+		if allHosts || hostSelector != "" || listContinue != "" {
+			predicates, err := storagecore.ParseSelector(hostSelector)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(2)
+			}
+			if listLimit <= 0 && listContinue == "" {
+				hosts, err = inventory.Select(ctx, predicates)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Could not retrieve data from database due to: %v\n", err)
+					os.Exit(12)
+				}
+				goto FINALIZE
+			}
+			page, err := inventory.ListPage(ctx, storagecore.ListOptions{
+				Limit:      listLimit,
+				Continue:   listContinue,
+				Predicates: predicates,
+			})
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Could not retrieve data from database due to: %v\n", err)
 				os.Exit(12)
 			}
+			hosts = page.Hosts
+			if page.Continue != "" {
+				defer fmt.Fprintf(os.Stderr, "More hosts are available, continue with: --continue %s\n", page.Continue)
+			}
 			goto FINALIZE
 		} else if len(args) != 0 {
+			var field storagecore.IndexField
+			if lookupBy != "" {
+				field, err = storagecore.ParseIndexField(lookupBy)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%v\n", err)
+					os.Exit(2)
+				}
+			}
 			for _, each := range args {
-				host, err := inventory.Get(each)
+				var host storagecore.Host
+				if field != "" {
+					host, err = inventory.GetBy(ctx, field, each)
+				} else {
+					host, err = inventory.Get(ctx, each)
+				}
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Could not retrieve data from database with key '%s' due to: %v\n", args[0], err)
 					os.Exit(12)
@@ -125,11 +292,50 @@ var manageCmd = &cobra.Command{
 
 var (
 	host                                           = storagecore.NewHost()
-	hostComment, hostDept                          string
+	hostComment, hostDept, removeReason            string
+	hostSelector, listContinue, lookupBy, renameTo string
+	listLimit, ifRevision                          int64
 	addHost, removeHost, updateHost, allHosts, yes bool
+	restoreHost, permanentRemove, listTrash        bool
+	applyBatch                                     bool
+	batchFile                                      string
 	extraInfoOrig                                  []string
 )
 
+// hasIPMICredential returns whether host has IPMI endpoint, and either a
+// credential profile or a complete credential.
+func hasIPMICredential(host storagecore.Host) bool {
+	if host.IPMIAddress == "" {
+		return false
+	}
+	return host.CredentialProfile != "" || (host.IPMIUser != "" && (host.IPMIPassword != "" || host.IPMISecret != ""))
+}
+
+// readBatchFile reads changes from a YAML or JSON file, where hosts to create
+// must have IPMI endpoint and credential just like '--add'.
+func readBatchFile(path string) ([]storagecore.BatchOp, error) {
+	if path == "" {
+		return nil, fmt.Errorf("File of changes must be specified with '--file'.")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read changes due to: %v", err)
+	}
+	var ops []storagecore.BatchOp
+	if err = yaml.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("Could not parse changes due to: %v", err)
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("No change was found in %s.", path)
+	}
+	for i, op := range ops {
+		if op.Action == storagecore.BatchCreate && !hasIPMICredential(op.Host) {
+			return nil, fmt.Errorf("IPMI endpoint and credential are required by #%d '%s'", i, op.Host.Hostname)
+		}
+	}
+	return ops, nil
+}
+
 func validateActionFlags() (ok, isAction bool) {
 	actionFlags := 0
 	if addHost {
@@ -141,6 +347,15 @@ func validateActionFlags() (ok, isAction bool) {
 	if updateHost {
 		actionFlags++
 	}
+	if restoreHost {
+		actionFlags++
+	}
+	if renameTo != "" {
+		actionFlags++
+	}
+	if applyBatch {
+		actionFlags++
+	}
 	return actionFlags <= 1, actionFlags > 0
 }
 
@@ -156,9 +371,45 @@ func init() {
 	manageCmd.Flags().BoolVarP(
 		&updateHost, "update", "u", updateHost, "Update an existing host",
 	)
+	manageCmd.Flags().BoolVar(
+		&restoreHost, "restore", restoreHost, "Restore a removed host from trash bin",
+	)
+	manageCmd.Flags().StringVar(
+		&renameTo, "rename", renameTo, "Rename an existing host to the given hostname, keeping its GUID and history",
+	)
+	manageCmd.Flags().BoolVar(
+		&applyBatch, "batch", applyBatch, "Apply changes given by '--file' atomically",
+	)
+	manageCmd.Flags().StringVarP(
+		&batchFile, "file", "f", batchFile, "YAML or JSON file of changes to apply with '--batch'",
+	)
+	manageCmd.Flags().BoolVar(
+		&permanentRemove, "permanent", permanentRemove, "Delete the host permanently instead of moving it into trash bin. It only works with '--remove'.",
+	)
+	manageCmd.Flags().StringVar(
+		&removeReason, "reason", removeReason, "Reason of removing the host, which will be kept in trash bin",
+	)
+	manageCmd.Flags().BoolVar(
+		&listTrash, "trash", listTrash, "List hosts in trash bin. It will be ignored if an action flag was specified.",
+	)
 	manageCmd.Flags().BoolVar(
 		&allHosts, "all", allHosts, "Select all existing hosts. It will be ignored if an action flag was specified.",
 	)
+	manageCmd.Flags().StringVar(
+		&hostSelector, "selector", hostSelector, "Select hosts satisfying comma-separated predicates, e.g. 'ssh_user=root,extra_info.department!=ops'.",
+	)
+	manageCmd.Flags().StringVar(
+		&lookupBy, "by", lookupBy, "Look hosts up by another field instead of hostname, which is one of 'guid', 'ipmi-address', 'ssh-address' and 'serial'. It will be ignored if an action flag was specified.",
+	)
+	manageCmd.Flags().Int64Var(
+		&listLimit, "limit", listLimit, "Maximum number of hosts to list, where zero means no limit.",
+	)
+	manageCmd.Flags().StringVar(
+		&listContinue, "continue", listContinue, "Continue listing from the token printed by a previous page.",
+	)
+	manageCmd.Flags().Int64Var(
+		&ifRevision, "if-revision", ifRevision, "Update the host only if it was last changed at the given revision. It requires a storage tracking revisions.",
+	)
 	manageCmd.Flags().StringVar(
 		&host.SSHAddress, "ssh-address", host.SSHAddress, "IP address that SSH service is listening on",
 	)
@@ -177,6 +428,12 @@ func init() {
 	manageCmd.Flags().StringVar(
 		&host.IPMIPassword, "ipmi-password", "", "Login password of IPMI interface",
 	)
+	manageCmd.Flags().StringVar(
+		&host.IPMISecret, "ipmi-secret", "", "Reference to login password of IPMI interface, e.g. 'file:///etc/ivy/bmc/dell-default', 'env:IPMI_PASS' or 'exec:/path/to/command' of a command listed by $IVY_SECRET_EXEC_ALLOWLIST",
+	)
+	manageCmd.Flags().StringVar(
+		&host.CredentialProfile, "credential-profile", "", "Name of the shared IPMI credential, which is overridden by '--ipmi-user', '--ipmi-password' and '--ipmi-secret'",
+	)
 	manageCmd.Flags().StringVar(
 		&hostComment, "comment", "", "Comment of the node",
 	)
@@ -0,0 +1,87 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	tablewriter "github.com/olekukonko/tablewriter"
+	cobra "github.com/spf13/cobra"
+	storagecore "github.com/universonic/ivy-utils/pkg/storage/core"
+	cmdbutil "github.com/universonic/ivy-utils/pkg/utils/cmdb"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Rewrite all hosts with the current schema version and build their indexes",
+	Long: `Rewrite all hosts which were stored with an older schema version. Hosts of
+older schema versions are upgraded whenever they are read, thus migrating them
+is not required, but saves upgrading them over and over again. Use '--dry-run'
+to report outdated hosts without changing anything.
+
+Indexes of hosts written before the storage kept indexes are built as well, so
+that looking hosts up by GUID, IPMI address, SSH address or serial no longer
+scans all hosts. Building fails if hosts share the GUID or IPMI address, which
+have to be corrected first.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		storage, err := NewStorageFromArgs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not spawn storage due to: %v\n", err)
+			os.Exit(10)
+		}
+		defer storage.Close()
+		ctx, cancel := interruptibleContext()
+		defer cancel()
+		inventory := cmdbutil.NewInventoryFromStorage(storage)
+		outdated, err := inventory.MigrateSchema(ctx, migrateDryRun)
+		if len(outdated) > 0 {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Hostname", "Schema Version"})
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			for _, each := range outdated {
+				table.Append([]string{each.Hostname, strconv.Itoa(each.Version)})
+			}
+			table.Render()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not migrate hosts after %d were done due to: %v\n", len(outdated), err)
+			os.Exit(11)
+		}
+		if migrateDryRun {
+			fmt.Fprintf(os.Stdout, "%d hosts would be migrated to schema version %d.\n", len(outdated), storagecore.HostSchemaVersion)
+			return
+		}
+		if err = storage.BuildIndexes(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Could not build indexes of hosts due to: %v\n", err)
+			os.Exit(11)
+		}
+		fmt.Fprintf(os.Stdout, "Successfully migrated %d hosts to schema version %d.\n", len(outdated), storagecore.HostSchemaVersion)
+	},
+}
+
+var (
+	migrateDryRun bool
+)
+
+func init() {
+	cmdbCmd.AddCommand(migrateCmd)
+
+	migrateCmd.Flags().BoolVar(
+		&migrateDryRun, "dry-run", migrateDryRun, "Report hosts to migrate without changing them",
+	)
+}
@@ -0,0 +1,119 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	cobra "github.com/spf13/cobra"
+	storage "github.com/universonic/ivy-utils/pkg/storage"
+	cmdbutil "github.com/universonic/ivy-utils/pkg/utils/cmdb"
+	zap "go.uber.org/zap"
+)
+
+// migrateStorageCmd represents the migrate-storage command
+var migrateStorageCmd = &cobra.Command{
+	Use:   "migrate-storage",
+	Short: "Copy all hosts and credential profiles into another storage",
+	Long: `Copy all hosts and credential profiles from one storage into another, keeping
+GUIDs of hosts, and verify counts and content hashes of both storages
+afterwards. Both storages are given by configuration files in YAML or JSON
+format, just like '--config-file'. Trashed hosts and history are not copied.
+
+Progress is recorded into the checkpoint file, thus an interrupted migration
+is resumed by running the same command again. The checkpoint file is removed
+once the migration has been verified.
+
+Example:
+  ivy-utils cmdb migrate-storage --from-config etcd.yaml --to-config bolt.yaml`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if migrateFromConfig == "" || migrateToConfig == "" {
+			fmt.Fprintf(os.Stderr, "Both '--from-config' and '--to-config' must be specified.\n")
+			os.Exit(2)
+		}
+		fingerprint := sha256.New()
+		for _, each := range []string{migrateFromConfig, migrateToConfig} {
+			b, err := ioutil.ReadFile(each)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not read configuration due to: %v\n", err)
+				os.Exit(2)
+			}
+			fingerprint.Write(b)
+			fingerprint.Write([]byte{0})
+		}
+		logger := zap.NewNop().Sugar()
+		from, err := storage.NewStorageFromConfigFile(migrateFromConfig, logger)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not spawn source storage due to: %v\n", err)
+			os.Exit(10)
+		}
+		defer from.Close()
+		to, err := storage.NewStorageFromConfigFile(migrateToConfig, logger)
+		if err != nil {
+			from.Close()
+			fmt.Fprintf(os.Stderr, "Could not spawn target storage due to: %v\n", err)
+			os.Exit(10)
+		}
+		defer to.Close()
+		// os.Exit skips deferred calls, hence both storages are closed by exit
+		// explicitly, e.g. to release the lock of a bolt database
+		exit := func(code int) {
+			to.Close()
+			from.Close()
+			os.Exit(code)
+		}
+		ctx, cancel := interruptibleContext()
+		defer cancel()
+		migration := &cmdbutil.StorageMigration{
+			From:        from,
+			To:          to,
+			Checkpoint:  migrateCheckpoint,
+			Fingerprint: hex.EncodeToString(fingerprint.Sum(nil)),
+		}
+		result, err := migration.Run(ctx, func(hosts int) {
+			fmt.Fprintf(os.Stderr, "Copied %d hosts.\n", hosts)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not migrate storage due to: %v\n", err)
+			exit(11)
+		}
+		if result.ResumedAfter != "" {
+			fmt.Fprintf(os.Stdout, "Resumed after host '%s'.\n", result.ResumedAfter)
+		}
+		fmt.Fprintf(os.Stdout, "Successfully migrated %d hosts and %d credential profiles with digest %s.\n", result.Hosts, result.CredentialProfiles, result.Digest)
+	},
+}
+
+var (
+	migrateFromConfig, migrateToConfig, migrateCheckpoint string
+)
+
+func init() {
+	cmdbCmd.AddCommand(migrateStorageCmd)
+
+	migrateStorageCmd.Flags().StringVar(
+		&migrateFromConfig, "from-config", migrateFromConfig, "Configuration file of the source storage",
+	)
+	migrateStorageCmd.Flags().StringVar(
+		&migrateToConfig, "to-config", migrateToConfig, "Configuration file of the target storage",
+	)
+	migrateStorageCmd.Flags().StringVar(
+		&migrateCheckpoint, "checkpoint", "migrate-storage.checkpoint", "File recording progress of migration",
+	)
+}
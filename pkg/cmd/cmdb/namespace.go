@@ -0,0 +1,114 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"fmt"
+	"os"
+
+	cobra "github.com/spf13/cobra"
+	storage "github.com/universonic/ivy-utils/pkg/storage"
+	storagecore "github.com/universonic/ivy-utils/pkg/storage/core"
+	etcd "github.com/universonic/ivy-utils/pkg/storage/etcd"
+)
+
+// namespaceCmd represents the namespace command
+var namespaceCmd = &cobra.Command{
+	Use:   "namespace",
+	Short: "List and create namespaces of etcd storage",
+	Long: `List and create namespaces of etcd storage. Each namespace keeps an isolated
+inventory, so that multiple environments could share the same etcd cluster. A
+namespace is chosen by 'namespace' of the storage configuration, or by
+'--namespace' which overrides it. The 'default' namespace keeps the inventory
+created before namespaces were introduced.
+
+Example:
+  ivy-utils cmdb namespace create staging -c etcd.yaml
+  ivy-utils cmdb manage --all --namespace staging -c etcd.yaml`,
+}
+
+// namespaceListCmd represents the namespace list command
+var namespaceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List namespaces of etcd storage",
+	Run: func(cmd *cobra.Command, args []string) {
+		adapter, err := etcdFromArgs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not spawn storage due to: %v\n", err)
+			os.Exit(10)
+		}
+		ctx, cancel := interruptibleContext()
+		defer cancel()
+		namespaces, err := adapter.ListNamespaces(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not retrieve data from database due to: %v\n", err)
+			os.Exit(12)
+		}
+		fmt.Fprintf(os.Stdout, "%s\n", namespaces.CanonicalString())
+	},
+}
+
+// namespaceCreateCmd represents the namespace create command
+var namespaceCreateCmd = &cobra.Command{
+	Use:   "create NAME",
+	Short: "Create a namespace of etcd storage",
+	Long: `Create a namespace of etcd storage. Its name consists of lowercase letters,
+digits, '_', '.' and '-', and must start and end with a letter or digit.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Fprintf(os.Stderr, "Only a single namespace must be specified in arguments\n")
+			os.Exit(2)
+		}
+		adapter, err := etcdFromArgs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not spawn storage due to: %v\n", err)
+			os.Exit(10)
+		}
+		ctx, cancel := interruptibleContext()
+		defer cancel()
+		err = adapter.CreateNamespace(ctx, args[0])
+		if err == storagecore.ErrResourceAlreadyExists {
+			fmt.Fprintf(os.Stderr, "Namespace '%s' already exists.\n", args[0])
+			os.Exit(11)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not commit changes to database due to: %v\n", err)
+			os.Exit(11)
+		}
+		fmt.Fprintf(os.Stdout, "Successfully created.\n")
+	},
+}
+
+// etcdFromArgs decodes the etcd configuration given in arguments, where the
+// namespace is irrelevant.
+func etcdFromArgs() (*etcd.Etcd, error) {
+	cfg, err := storageConfigFromArgs()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Adapter != "etcd" {
+		return nil, fmt.Errorf("Namespaces are only supported by etcd storage")
+	}
+	adapter := etcd.New()
+	if err = storage.DecodeConfig(cfg.Config, adapter); err != nil {
+		return nil, err
+	}
+	return adapter, nil
+}
+
+func init() {
+	cmdbCmd.AddCommand(namespaceCmd)
+	namespaceCmd.AddCommand(namespaceListCmd)
+	namespaceCmd.AddCommand(namespaceCreateCmd)
+}
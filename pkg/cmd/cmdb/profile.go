@@ -0,0 +1,157 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"fmt"
+	"os"
+
+	cobra "github.com/spf13/cobra"
+	storagecore "github.com/universonic/ivy-utils/pkg/storage/core"
+	cmdbutil "github.com/universonic/ivy-utils/pkg/utils/cmdb"
+)
+
+// profileCmd represents the profile command
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage shared IPMI credential profiles",
+	Long: `Manage IPMI credential profiles, which are shared by hosts referring to them
+with '--credential-profile'. Changing a profile affects all of its hosts at
+once, while IPMI credential given to a host itself overrides the profile.
+
+Without an action flag, given profiles or all profiles are listed.
+
+Examples:
+  ivy-utils cmdb profile --add --type dell --user root --secret file:///etc/ivy/bmc/dell-default dell-default
+  ivy-utils cmdb profile --update --password calvin dell-default`,
+	Run: func(cmd *cobra.Command, args []string) {
+		actionFlags := 0
+		for _, each := range []bool{addProfile, updateProfile, removeProfile} {
+			if each {
+				actionFlags++
+			}
+		}
+		if actionFlags > 1 {
+			fmt.Fprintf(os.Stderr, "Multiple action flags was given.\n")
+			os.Exit(1)
+		}
+		storage, err := NewStorageFromArgs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not spawn storage due to: %v\n", err)
+			os.Exit(10)
+		}
+		defer storage.Close()
+		ctx, cancel := interruptibleContext()
+		defer cancel()
+		inventory := cmdbutil.NewInventoryFromStorage(storage)
+		if actionFlags > 0 {
+			if len(args) == 0 {
+				fmt.Fprintf(os.Stderr, "Name of credential profile must be specified.\n")
+				os.Exit(2)
+			}
+			profile.Name = args[0]
+			if addProfile {
+				if profile.User == "" || (profile.Password == "" && profile.Secret == "") {
+					fmt.Fprintf(os.Stderr, "User and password or secret are required\n")
+					os.Exit(2)
+				}
+				err = inventory.AddProfile(ctx, profile)
+			} else if updateProfile {
+				// hosts referring to the profile are changed by updating it
+				var hosts []storagecore.Host
+				hosts, err = inventory.Select(ctx, []storagecore.Predicate{{
+					Field:    "credential_profile",
+					Operator: storagecore.OperatorEquals,
+					Value:    profile.Name,
+				}})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Could not retrieve data from database due to: %v\n", err)
+					os.Exit(12)
+				}
+				hostnames := make([]string, 0, len(hosts))
+				for _, each := range hosts {
+					hostnames = append(hostnames, each.Hostname)
+				}
+				var unlock func() error
+				unlock, err = inventory.LockAll(ctx, hostnames, "cmdb profile --update "+profile.Name, cmdbutil.DefaultLockWait)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Could not lock hosts due to: %v\n", err)
+					os.Exit(11)
+				}
+				err = inventory.UpdateProfile(ctx, profile)
+				unlock()
+			} else if removeProfile {
+				err = inventory.DeleteProfile(ctx, profile.Name)
+				if err == nil {
+					fmt.Fprintf(os.Stdout, "Successfully deleted.\n")
+					return
+				}
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not commit changes to database due to: %v\n", err)
+				os.Exit(11)
+			}
+			args = args[:1]
+		}
+		var profiles []storagecore.CredentialProfile
+		if len(args) == 0 {
+			profiles, err = inventory.ListProfiles(ctx)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not retrieve data from database due to: %v\n", err)
+				os.Exit(12)
+			}
+		}
+		for _, each := range args {
+			p, err := inventory.GetProfile(ctx, each)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not retrieve data from database with key '%s' due to: %v\n", each, err)
+				os.Exit(12)
+			}
+			profiles = append(profiles, p)
+		}
+		fmt.Fprintf(os.Stdout, "%s\n", storagecore.CredentialProfileList(profiles).CanonicalString())
+	},
+}
+
+var (
+	profile                                  storagecore.CredentialProfile
+	addProfile, updateProfile, removeProfile bool
+)
+
+func init() {
+	cmdbCmd.AddCommand(profileCmd)
+
+	profileCmd.Flags().BoolVarP(
+		&addProfile, "add", "a", addProfile, "Add a new credential profile",
+	)
+	profileCmd.Flags().BoolVarP(
+		&updateProfile, "update", "u", updateProfile, "Update an existing credential profile",
+	)
+	profileCmd.Flags().BoolVarP(
+		&removeProfile, "remove", "r", removeProfile, "Remove a credential profile which is not used by any host",
+	)
+	profileCmd.Flags().StringVar(
+		&profile.Type, "type", "", "Type of BMC, e.g. 'dell' or 'supermicro'",
+	)
+	profileCmd.Flags().StringVar(
+		&profile.User, "user", "", "Login user of IPMI interface",
+	)
+	profileCmd.Flags().StringVar(
+		&profile.Password, "password", "", "Login password of IPMI interface",
+	)
+	profileCmd.Flags().StringVar(
+		&profile.Secret, "secret", "", "Reference to login password of IPMI interface, e.g. 'file:///etc/ivy/bmc/dell-default', 'env:IPMI_PASS' or 'exec:/path/to/command' of a command listed by $IVY_SECRET_EXEC_ALLOWLIST",
+	)
+}
@@ -19,6 +19,7 @@ import (
 	"os"
 
 	cobra "github.com/spf13/cobra"
+	storagecore "github.com/universonic/ivy-utils/pkg/storage/core"
 	cmdbutil "github.com/universonic/ivy-utils/pkg/utils/cmdb"
 )
 
@@ -34,6 +35,13 @@ var reportCmd = &cobra.Command{
 			os.Exit(10)
 		}
 		defer storage.Close()
+		ctx, cancel := interruptibleContext()
+		defer cancel()
+		predicates, err := storagecore.ParseSelector(hostSelector)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
 		generator := cmdbutil.NewReportGenerator(storage)
 		var mode cmdbutil.ReportMode
 		if inventoryOnly {
@@ -51,7 +59,8 @@ var reportCmd = &cobra.Command{
 		if mode == 0 {
 			mode = cmdbutil.AnsibleMode
 		}
-		err = generator.GenerateAndSaveAs(args, allHosts, mode, output)
+		// a selector implies that hosts are selected from all existing ones
+		err = generator.GenerateAndSaveAs(ctx, args, allHosts || len(predicates) != 0, predicates, mode, output)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Could not generate report due to: %v\n", err)
 			os.Exit(20)
@@ -84,4 +93,7 @@ func init() {
 	reportCmd.Flags().BoolVar(
 		&allHosts, "all", allHosts, "Select all existing hosts.",
 	)
+	reportCmd.Flags().StringVar(
+		&hostSelector, "selector", hostSelector, "Select hosts satisfying comma-separated predicates, e.g. 'ssh_user=root,extra_info.department!=ops'.",
+	)
 }
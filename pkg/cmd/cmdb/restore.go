@@ -0,0 +1,124 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"fmt"
+	"os"
+
+	tablewriter "github.com/olekukonko/tablewriter"
+	cobra "github.com/spf13/cobra"
+	cmdbutil "github.com/universonic/ivy-utils/pkg/utils/cmdb"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore FILE",
+	Short: "Load hosts, trashed hosts and credential profiles from an archive",
+	Long: `Load hosts, trashed hosts and credential profiles from an archive written by
+'ivy-utils cmdb backup', keeping GUIDs of hosts. The archive is verified
+against its manifest before anything is written. Trashed hosts are put back
+into the trash bin unless their hostnames are held by hosts which are not
+trashed, and their retention period starts over.
+
+With '--mode merge', entities absent from storage are created, while existing
+ones are kept and reported as conflicts if they differ from the archive. With
+'--mode replace', storage is made identical to the archive, where hosts absent
+from the archive are moved into trash bin, and credential profiles absent from
+the archive are deleted. Use '--dry-run' to report changes and conflicts
+without writing anything.
+
+Example:
+  ivy-utils cmdb restore --mode replace --dry-run cmdb.tar.gz`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Fprintf(os.Stderr, "Exactly one archive must be specified.\n")
+			os.Exit(2)
+		}
+		mode := cmdbutil.RestoreMode(restoreMode)
+		if mode != cmdbutil.RestoreMerge && mode != cmdbutil.RestoreReplace {
+			fmt.Fprintf(os.Stderr, "Unknown restore mode '%s'.\n", restoreMode)
+			os.Exit(2)
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not open archive due to: %v\n", err)
+			os.Exit(1)
+		}
+		backup, err := cmdbutil.ReadBackup(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		storage, err := NewStorageFromArgs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not spawn storage due to: %v\n", err)
+			os.Exit(10)
+		}
+		defer storage.Close()
+		ctx, cancel := interruptibleContext()
+		defer cancel()
+		inventory := cmdbutil.NewInventoryFromStorage(storage)
+		report, err := inventory.RestoreBackup(ctx, backup, mode, restoreDryRun)
+		if len(report.Changes) > 0 {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Kind", "Name", "Action"})
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			for _, each := range report.Changes {
+				table.Append([]string{each.Kind, each.Name, each.Action})
+			}
+			table.Render()
+		}
+		if len(report.Conflicts) > 0 {
+			table := tablewriter.NewWriter(os.Stderr)
+			table.SetHeader([]string{"Kind", "Name", "Conflict"})
+			table.SetAlignment(tablewriter.ALIGN_LEFT)
+			for _, each := range report.Conflicts {
+				table.Append([]string{each.Kind, each.Name, each.Reason})
+			}
+			table.Render()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not restore after %d changes were done due to: %v\n", len(report.Changes), err)
+			os.Exit(11)
+		}
+		if restoreDryRun {
+			fmt.Fprintf(os.Stdout, "%d changes would be made, %d entities are unchanged and %d conflict.\n", len(report.Changes), report.Unchanged, len(report.Conflicts))
+			return
+		}
+		if len(report.Conflicts) > 0 {
+			fmt.Fprintf(os.Stderr, "Restored with %d changes, %d entities are unchanged and %d were skipped due to conflicts.\n", len(report.Changes), report.Unchanged, len(report.Conflicts))
+			os.Exit(11)
+		}
+		fmt.Fprintf(os.Stdout, "Successfully restored with %d changes, %d entities are unchanged.\n", len(report.Changes), report.Unchanged)
+	},
+}
+
+var (
+	restoreMode   = string(cmdbutil.RestoreMerge)
+	restoreDryRun bool
+)
+
+func init() {
+	cmdbCmd.AddCommand(restoreCmd)
+
+	restoreCmd.Flags().StringVar(
+		&restoreMode, "mode", restoreMode, "How to restore over existing data, which is either 'merge' or 'replace'",
+	)
+	restoreCmd.Flags().BoolVar(
+		&restoreDryRun, "dry-run", restoreDryRun, "Report changes and conflicts without writing anything",
+	)
+}
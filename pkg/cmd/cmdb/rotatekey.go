@@ -0,0 +1,103 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"fmt"
+	"os"
+
+	cobra "github.com/spf13/cobra"
+	encryption "github.com/universonic/ivy-utils/pkg/storage/encryption"
+)
+
+// rotateKeyCmd represents the rotate-key command
+var rotateKeyCmd = &cobra.Command{
+	Use:   "rotate-key",
+	Short: "Re-encrypt sensitive fields of all hosts and credential profiles with a new key",
+	Long: `Re-encrypt sensitive fields of all hosts and credential profiles with a new
+encryption key. Values are decrypted with the keys in 'encryption' section of
+storage configuration, and values which are still plain are encrypted as well.
+Without '--new-key-file' or '--new-key-env', the current key is used, which
+encrypts existing plain values after encryption has been enabled.
+
+Once completed, replace the key in storage configuration with the new one, and
+keep the old key in 'previous_key_files' until trashed hosts are purged.
+
+Examples:
+  ivy-utils cmdb rotate-key --generate-key > new.key
+  ivy-utils cmdb rotate-key --new-key-file new.key`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if generateKey {
+			key, err := encryption.GenerateKey()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not generate encryption key due to: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stdout, "%s\n", key)
+			return
+		}
+		storage, err := NewStorageFromArgs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not spawn storage due to: %v\n", err)
+			os.Exit(10)
+		}
+		defer storage.Close()
+		ctx, cancel := interruptibleContext()
+		defer cancel()
+		encrypted, ok := storage.(*encryption.Storage)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Storage does not support encryption.\n")
+			os.Exit(10)
+		}
+		var newKey *encryption.Key
+		if newKeyFile != "" || newKeyEnv != "" {
+			newKey, err = encryption.LoadKey(newKeyFile, newKeyEnv)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(2)
+			}
+		} else if keyring := encrypted.Keyring(); keyring != nil {
+			newKey = keyring.Primary()
+		} else {
+			fmt.Fprintf(os.Stderr, "Encryption is not configured, please specify a new key.\n")
+			os.Exit(2)
+		}
+		rotated, err := encrypted.RotateKey(ctx, newKey)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not re-encrypt after %d hosts and credential profiles were done due to: %v\n", rotated, err)
+			os.Exit(11)
+		}
+		fmt.Fprintf(os.Stdout, "Successfully re-encrypted %d hosts and credential profiles with key '%s'.\n", rotated, newKey.ID)
+	},
+}
+
+var (
+	newKeyFile, newKeyEnv string
+	generateKey           bool
+)
+
+func init() {
+	cmdbCmd.AddCommand(rotateKeyCmd)
+
+	rotateKeyCmd.Flags().StringVar(
+		&newKeyFile, "new-key-file", newKeyFile, "File holding the new base64 encoded key",
+	)
+	rotateKeyCmd.Flags().StringVar(
+		&newKeyEnv, "new-key-env", newKeyEnv, "Environment variable holding the new base64 encoded key",
+	)
+	rotateKeyCmd.Flags().BoolVar(
+		&generateKey, "generate-key", generateKey, "Print a new random key and exit",
+	)
+}
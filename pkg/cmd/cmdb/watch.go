@@ -0,0 +1,80 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	cobra "github.com/spf13/cobra"
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch changes of CMDB host entities",
+	Long:  `Watch changes of CMDB host entities and print them until interrupted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		storage, err := NewStorageFromArgs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not spawn storage due to: %v\n", err)
+			os.Exit(10)
+		}
+		defer storage.Close()
+		ctx, cancel := interruptibleContext()
+		defer cancel()
+		events, err := storage.WatchHosts(ctx, watchRevision)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not watch hosts due to: %v\n", err)
+			os.Exit(12)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		if !watchJSON {
+			fmt.Fprintf(os.Stdout, "%-10s %-8s %-36s %-24s %-16s %s\n", "REVISION", "EVENT", "GUID", "HOSTNAME", "SSH ADDRESS", "IPMI ADDRESS")
+		}
+		for event := range events {
+			if event.Err != nil {
+				fmt.Fprintf(os.Stderr, "Watch was terminated due to: %v\n", event.Err)
+				os.Exit(12)
+			}
+			if event.Host.IPMIPassword != "" {
+				event.Host.IPMIPassword = "******"
+			}
+			if watchJSON {
+				encoder.Encode(event)
+				continue
+			}
+			fmt.Fprintf(os.Stdout, "%-10d %-8s %-36s %-24s %-16s %s\n",
+				event.Revision, event.Type, event.Host.GUID, event.Host.Hostname, event.Host.SSHAddress, event.Host.IPMIAddress)
+		}
+	},
+}
+
+var (
+	watchRevision int64
+	watchJSON     bool
+)
+
+func init() {
+	cmdbCmd.AddCommand(watchCmd)
+
+	watchCmd.Flags().Int64Var(
+		&watchRevision, "revision", watchRevision, "Start watching from the given revision, including changes happened in the past. Default to the current revision.",
+	)
+	watchCmd.Flags().BoolVar(
+		&watchJSON, "json", watchJSON, "Print events as JSON lines instead of table.",
+	)
+}
@@ -0,0 +1,81 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	bolt "github.com/universonic/ivy-utils/pkg/storage/bolt"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	etcd "github.com/universonic/ivy-utils/pkg/storage/etcd"
+	filesystem "github.com/universonic/ivy-utils/pkg/storage/filesystem"
+	memory "github.com/universonic/ivy-utils/pkg/storage/memory"
+	sqlite "github.com/universonic/ivy-utils/pkg/storage/sqlite"
+	zap "go.uber.org/zap"
+)
+
+// opener is implemented by configurations of all built-in adapters.
+type opener interface {
+	Open(logger *zap.SugaredLogger) (core.Storage, error)
+}
+
+// factoryOf makes a Factory which decodes configuration into a fresh value
+// created by newFn and opens the storage with it.
+func factoryOf(newFn func() opener) Factory {
+	return func(config map[string]interface{}, logger *zap.SugaredLogger) (core.Storage, error) {
+		adapter := newFn()
+		if err := DecodeConfig(config, adapter); err != nil {
+			return nil, err
+		}
+		return adapter.Open(logger)
+	}
+}
+
+func init() {
+	Register("etcd", factoryOf(func() opener { return etcd.New() }),
+		ConfigOption{Name: "endpoints", Type: "[]string", Description: "Endpoints of etcd cluster"},
+		ConfigOption{Name: "namespace", Type: "string", Default: "default", Description: "Namespace isolating the inventory within the cluster, which must have been created"},
+		ConfigOption{Name: "user", Type: "string", Description: "User for authentication"},
+		ConfigOption{Name: "password", Type: "string", Description: "Password for authentication"},
+		ConfigOption{Name: "ssl.server_name", Type: "string", Description: "Server name for verifying certificate of etcd"},
+		ConfigOption{Name: "ssl.ca_cert", Type: "string", Description: "Path of CA certificate"},
+		ConfigOption{Name: "ssl.cert", Type: "string", Description: "Path of client certificate"},
+		ConfigOption{Name: "ssl.key", Type: "string", Description: "Path of client key"},
+		ConfigOption{Name: "trash_retention", Type: "duration", Default: "720h", Description: "Period a trashed host is kept before it is purged"},
+		ConfigOption{Name: "dial_timeout", Type: "duration", Default: "3s", Description: "Time to wait for connecting to endpoints"},
+		ConfigOption{Name: "operation_timeout", Type: "duration", Default: "5s", Description: "Time to wait for each operation, including its retries"},
+		ConfigOption{Name: "retry.max_attempts", Type: "int", Default: "5", Description: "Number of attempts of an update which lost the race against concurrent updates"},
+		ConfigOption{Name: "retry.initial_backoff", Type: "duration", Default: "20ms", Description: "Time to wait before the first retry, which doubles on each retry"},
+		ConfigOption{Name: "retry.max_backoff", Type: "duration", Default: "1s", Description: "Maximum time to wait between retries"},
+	)
+	Register("memory", factoryOf(func() opener { return memory.New() }),
+		ConfigOption{Name: "trash_retention", Type: "duration", Default: "720h", Description: "Period a trashed host is kept before it is purged"},
+	)
+	Register("bolt", factoryOf(func() opener { return bolt.New() }),
+		ConfigOption{Name: "path", Type: "string", Description: "Path of database file (required)"},
+		ConfigOption{Name: "file_mode", Type: "string", Default: "0600", Description: "Permission bits of database file in octal notation"},
+		ConfigOption{Name: "lock_timeout", Type: "duration", Default: "3s", Description: "Time to wait for the file lock held by another process"},
+		ConfigOption{Name: "trash_retention", Type: "duration", Default: "720h", Description: "Period a trashed host is kept before it is purged"},
+	)
+	Register("sqlite", factoryOf(func() opener { return sqlite.New() }),
+		ConfigOption{Name: "path", Type: "string", Description: "Path of database file (required)"},
+		ConfigOption{Name: "busy_timeout", Type: "duration", Default: "5s", Description: "Time to wait for a lock held by another connection"},
+		ConfigOption{Name: "trash_retention", Type: "duration", Default: "720h", Description: "Period a trashed host is kept before it is purged"},
+	)
+	Register("filesystem", factoryOf(func() opener { return filesystem.New() }),
+		ConfigOption{Name: "directory", Type: "string", Description: "Root directory of storage (required)"},
+		ConfigOption{Name: "format", Type: "string", Default: "yaml", Description: "File format of entities, either 'yaml' or 'json'"},
+		ConfigOption{Name: "lock_timeout", Type: "duration", Default: "3s", Description: "Time to wait for the lock file held by another writer"},
+		ConfigOption{Name: "trash_retention", Type: "duration", Default: "720h", Description: "Period a trashed host is kept before it is purged"},
+	)
+}
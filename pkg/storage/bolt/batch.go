@@ -0,0 +1,73 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// ApplyBatch plans and applies the batch within a single writable
+// transaction, which is rolled back on any error.
+func (c *conn) ApplyBatch(ctx context.Context, ops []core.BatchOp) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during applying batch of %d operations due to: %v", len(ops), err)
+		} else {
+			c.logger.Debugf("Applied batch of %d operations", len(ops))
+		}
+	}()
+	return c.update(ctx, func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket([]byte(hostPrefix))
+		current := func(hostname string) (*core.Host, error) {
+			b := bkt.Get([]byte(hostname))
+			if b == nil {
+				return nil, nil
+			}
+			host := new(core.Host)
+			return host, json.Unmarshal(b, host)
+		}
+		owners := func(entry core.IndexEntry) ([]string, error) {
+			if owner := tx.Bucket([]byte(indexPrefix)).Get([]byte(entry.Key())); owner != nil {
+				return []string{string(owner)}, nil
+			}
+			return nil, nil
+		}
+		changes, err := core.PlanBatch(ops, current, owners)
+		if err != nil {
+			return err
+		}
+		values := make([][]byte, len(changes))
+		for i, change := range changes {
+			key := []byte(ops[change.Index].Host.Hostname)
+			// Old entries are all released before new ones are claimed,
+			// which never conflicts as the plan has been checked.
+			if err = updateHostIndexes(tx, bkt.Get(key), nil); err != nil {
+				return err
+			}
+			if change.Updated != nil {
+				if values[i], err = json.Marshal(change.Updated); err != nil {
+					return err
+				}
+			}
+		}
+		for i, change := range changes {
+			key := []byte(ops[change.Index].Host.Hostname)
+			if values[i] == nil {
+				if err = bkt.Delete(key); err != nil {
+					return err
+				}
+				continue
+			}
+			if err = updateHostIndexes(tx, nil, values[i]); err != nil {
+				return err
+			}
+			if err = bkt.Put(key, values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,101 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bolt
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	bbolt "go.etcd.io/bbolt"
+	zap "go.uber.org/zap"
+)
+
+const (
+	defaultFileMode    = 0600
+	defaultLockTimeout = 3 * time.Second
+)
+
+// Bolt is an embedded storage which persists all data into a single local
+// file. It is suitable for small sites where an etcd cluster is overkill.
+type Bolt struct {
+	// Path is the location of database file, which will be created if absent.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// FileMode is the permission bits in octal notation, e.g. "0600".
+	FileMode string `json:"file_mode,omitempty" yaml:"file_mode,omitempty"`
+	// LockTimeout is the amount of time to wait for the file lock held by
+	// another process, e.g. "3s".
+	LockTimeout string `json:"lock_timeout,omitempty" yaml:"lock_timeout,omitempty"`
+	// TrashRetention is the period a trashed host is kept before it is
+	// purged, e.g. "720h".
+	TrashRetention string `json:"trash_retention,omitempty" yaml:"trash_retention,omitempty"`
+}
+
+func (in *Bolt) Open(logger *zap.SugaredLogger) (core.Storage, error) {
+	if in.Path == "" {
+		return nil, fmt.Errorf("Database file path of bolt storage was not specified")
+	}
+	mode := os.FileMode(defaultFileMode)
+	if in.FileMode != "" {
+		m, err := strconv.ParseUint(in.FileMode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid file mode '%s': %v", in.FileMode, err)
+		}
+		mode = os.FileMode(m)
+	}
+	timeout := defaultLockTimeout
+	if in.LockTimeout != "" {
+		d, err := time.ParseDuration(in.LockTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid lock timeout '%s': %v", in.LockTimeout, err)
+		}
+		timeout = d
+	}
+	trashRetention, err := core.ParseTrashRetention(in.TrashRetention)
+	if err != nil {
+		return nil, err
+	}
+	db, err := bbolt.Open(in.Path, mode, &bbolt.Options{Timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range []string{hostPrefix, trashPrefix, profilePrefix} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		if tx.Bucket([]byte(indexPrefix)) == nil {
+			return buildIndexes(tx, logger)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	c := &conn{
+		db:             db,
+		trashRetention: trashRetention,
+		logger:         logger,
+	}
+	return c, nil
+}
+
+func New() *Bolt {
+	return &Bolt{}
+}
@@ -0,0 +1,30 @@
+package bolt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	storagetest "github.com/universonic/ivy-utils/pkg/storage/storagetest"
+	zap "go.uber.org/zap"
+)
+
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ivy-bolt-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	storagetest.Run(t, func(t *testing.T) core.Storage {
+		adapter := New()
+		adapter.Path = filepath.Join(dir, strings.Replace(t.Name(), "/", "_", -1)+".db")
+		storage, err := adapter.Open(zap.NewNop().Sugar())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return storage
+	})
+}
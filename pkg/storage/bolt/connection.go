@@ -0,0 +1,234 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	bbolt "go.etcd.io/bbolt"
+	zap "go.uber.org/zap"
+)
+
+const (
+	// hostPrefix is the bucket which holds host entities keyed by hostname,
+	// mirroring the key layout of etcd storage.
+	hostPrefix = "host"
+)
+
+type conn struct {
+	db             *bbolt.DB
+	trashRetention time.Duration
+	logger         *zap.SugaredLogger
+}
+
+func (c *conn) Close() error {
+	return c.db.Close()
+}
+
+func (c *conn) CreateHost(ctx context.Context, host core.Host) error {
+	if _, err := uuid.FromString(host.GUID); err != nil {
+		host.GUID = uuid.NewV4().String()
+	}
+	host.UpdatedAt = time.Now().UTC()
+	// NOTE: we are currently using hostname as host's primary unique identifier
+	return c.txnCreate(ctx, hostPrefix, host.Hostname, host, updateHostIndexes)
+}
+
+func (c *conn) GetHost(ctx context.Context, id string) (host core.Host, err error) {
+	if err = c.getKey(ctx, hostPrefix, id, &host); err != nil {
+		return
+	}
+	return host, nil
+}
+
+func (c *conn) UpdateHost(ctx context.Context, id string, updater func(host core.Host) (core.Host, error)) error {
+	return c.txnUpdate(ctx, hostPrefix, id, func(currentValue []byte) ([]byte, error) {
+		current := core.NewHost()
+		if len(currentValue) > 0 {
+			if err := json.Unmarshal(currentValue, current); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := updater(*current)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := uuid.FromString(updated.GUID); err != nil {
+			updated.GUID = uuid.NewV4().String()
+		}
+		updated.UpdatedAt = time.Now().UTC()
+		// hostname is the key of host, which is also referred by indexes
+		updated.Hostname = id
+		return json.Marshal(updated)
+	}, updateHostIndexes)
+}
+
+func (c *conn) DeleteHost(ctx context.Context, id string) error {
+	return c.deleteKey(ctx, hostPrefix, id, updateHostIndexes)
+}
+
+func (c *conn) ListHost(ctx context.Context) (hosts []core.Host, err error) {
+	err = c.view(ctx, func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(hostPrefix)).ForEach(func(k, v []byte) error {
+			var host core.Host
+			if err := json.Unmarshal(v, &host); err != nil {
+				return err
+			}
+			hosts = append(hosts, host)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+func (c *conn) txnCreate(ctx context.Context, bucket, key string, value interface{}, index indexer) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during creating data entity '%s/%s' due to: %v", bucket, key, err)
+		} else {
+			c.logger.Debugf("Created key '%s/%s': %v", bucket, key, value)
+		}
+	}()
+	var b []byte
+	b, err = json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.update(ctx, func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt.Get([]byte(key)) != nil {
+			return core.ErrResourceAlreadyExists
+		}
+		if index != nil {
+			if err := index(tx, nil, b); err != nil {
+				return err
+			}
+		}
+		return bkt.Put([]byte(key), b)
+	})
+}
+
+func (c *conn) getKey(ctx context.Context, bucket, key string, value interface{}) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during getting data entity '%s/%s' due to: %v", bucket, key, err)
+		} else {
+			c.logger.Debugf("Retrieved key '%s/%s': %v", bucket, key, value)
+		}
+	}()
+	return c.view(ctx, func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket)).Get([]byte(key))
+		if b == nil {
+			return core.ErrResourceNotFound
+		}
+		return json.Unmarshal(b, value)
+	})
+}
+
+// txnUpdate runs the whole read-modify-write cycle inside a single writable
+// transaction. Bolt allows only one writer at a time, so there is no chance
+// of conflicting updates.
+func (c *conn) txnUpdate(ctx context.Context, bucket, key string, update func(current []byte) ([]byte, error), index indexer) (err error) {
+	var updatedValue []byte
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during updating data entity '%s/%s' due to: %v", bucket, key, err)
+		} else {
+			c.logger.Debugf("Updated key '%s/%s': %s", bucket, key, updatedValue)
+		}
+	}()
+	return c.update(ctx, func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		// Bytes returned by Get are only valid during the transaction and
+		// must not be modified, so hand a copy over to the updater.
+		var currentValue []byte
+		if v := bkt.Get([]byte(key)); v != nil {
+			currentValue = append([]byte(nil), v...)
+		}
+		var err error
+		updatedValue, err = update(currentValue)
+		if err != nil {
+			return err
+		}
+		if index != nil {
+			if err = index(tx, currentValue, updatedValue); err != nil {
+				return err
+			}
+		}
+		return bkt.Put([]byte(key), updatedValue)
+	})
+}
+
+func (c *conn) deleteKey(ctx context.Context, bucket, key string, index indexer) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during deleting data entity '%s/%s' due to: %v", bucket, key, err)
+		} else {
+			c.logger.Debugf("Deleted key '%s/%s'", bucket, key)
+		}
+	}()
+	return c.update(ctx, func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		current := bkt.Get([]byte(key))
+		if current == nil {
+			return core.ErrResourceNotFound
+		}
+		if index != nil {
+			if err := index(tx, current, nil); err != nil {
+				return err
+			}
+		}
+		return bkt.Delete([]byte(key))
+	})
+}
+
+// update runs fn within a read-write transaction, unless ctx is done before
+// the transaction begins.
+func (c *conn) update(ctx context.Context, fn func(tx *bbolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.db.Update(fn)
+}
+
+// view runs fn within a read-only transaction, unless ctx is done before the
+// transaction begins.
+func (c *conn) view(ctx context.Context, fn func(tx *bbolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.db.View(fn)
+}
+
+func (c *conn) WatchHosts(ctx context.Context, fromRevision int64) (<-chan core.HostEvent, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) HostHistory(ctx context.Context, id string) ([]core.HostRevision, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) ListHostAt(ctx context.Context, revision int64) ([]core.Host, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) LockHost(ctx context.Context, lock core.HostLock, ttl time.Duration) (func() error, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) ListHostLocks(ctx context.Context) ([]core.HostLock, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) ForceUnlockHost(ctx context.Context, id string) error {
+	return core.ErrNotSupported
+}
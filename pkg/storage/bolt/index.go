@@ -0,0 +1,151 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	bbolt "go.etcd.io/bbolt"
+	zap "go.uber.org/zap"
+)
+
+const (
+	// indexPrefix is the bucket which holds secondary index keys of hosts,
+	// see core.IndexEntry.
+	indexPrefix = "index"
+)
+
+// indexer maintains secondary indexes within given writable transaction
+// while a key changes from current to updated value, where nil means the key
+// is absent.
+type indexer func(tx *bbolt.Tx, current, updated []byte) error
+
+// updateHostIndexes is the indexer of host keys.
+func updateHostIndexes(tx *bbolt.Tx, current, updated []byte) error {
+	var oldHost, newHost *core.Host
+	if len(current) > 0 {
+		oldHost = new(core.Host)
+		if err := json.Unmarshal(current, oldHost); err != nil {
+			return err
+		}
+	}
+	if len(updated) > 0 {
+		newHost = new(core.Host)
+		if err := json.Unmarshal(updated, newHost); err != nil {
+			return err
+		}
+	}
+	bkt := tx.Bucket([]byte(indexPrefix))
+	removed, added := core.DiffIndexEntries(oldHost, newHost)
+	for _, each := range added {
+		if !each.Field.Unique() {
+			continue
+		}
+		owner := bkt.Get([]byte(each.Key()))
+		if owner != nil && string(owner) != each.Hostname && (oldHost == nil || string(owner) != oldHost.Hostname) {
+			return core.ErrIndexConflict
+		}
+	}
+	for _, each := range removed {
+		if err := bkt.Delete([]byte(each.Key())); err != nil {
+			return err
+		}
+	}
+	for _, each := range added {
+		var value []byte
+		if each.Field.Unique() {
+			value = []byte(each.Hostname)
+		}
+		// bolt treats a nil value as an empty one
+		if err := bkt.Put([]byte(each.Key()), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildIndexes creates the index bucket and indexes all existing hosts, which
+// is required by databases created before secondary indexes were introduced.
+func buildIndexes(tx *bbolt.Tx, logger *zap.SugaredLogger) error {
+	bkt, err := tx.CreateBucket([]byte(indexPrefix))
+	if err != nil {
+		return err
+	}
+	return tx.Bucket([]byte(hostPrefix)).ForEach(func(k, v []byte) error {
+		var host core.Host
+		if err := json.Unmarshal(v, &host); err != nil {
+			return err
+		}
+		for _, entry := range core.HostIndexEntries(host) {
+			var value []byte
+			if entry.Field.Unique() {
+				if bkt.Get([]byte(entry.Key())) != nil {
+					logger.Warnf("Skipped index '%s' of host '%s' as it is taken by another host", entry.Key(), host.Hostname)
+					continue
+				}
+				value = []byte(entry.Hostname)
+			}
+			if err := bkt.Put([]byte(entry.Key()), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *conn) GetHostBy(ctx context.Context, field core.IndexField, value string) (host core.Host, err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during looking up data entity by '%s=%s' due to: %v", field, value, err)
+		} else {
+			c.logger.Debugf("Retrieved host '%s' by '%s=%s'", host.Hostname, field, value)
+		}
+	}()
+	if value == "" {
+		return host, core.ErrResourceNotFound
+	}
+	err = c.view(ctx, func(tx *bbolt.Tx) error {
+		bkt := tx.Bucket([]byte(indexPrefix))
+		var hostname string
+		if field.Unique() {
+			b := bkt.Get([]byte(core.IndexEntry{Field: field, Value: value}.Key()))
+			if b == nil {
+				return core.ErrResourceNotFound
+			}
+			hostname = string(b)
+		} else {
+			prefix := []byte(core.ValuePrefix(field, value))
+			cursor := bkt.Cursor()
+			var keys []string
+			for k, _ := cursor.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)) && len(keys) < 2; k, _ = cursor.Next() {
+				keys = append(keys, string(k))
+			}
+			switch len(keys) {
+			case 0:
+				return core.ErrResourceNotFound
+			case 1:
+			default:
+				return core.ErrAmbiguousIndex
+			}
+			var err error
+			if hostname, err = url.PathUnescape(strings.TrimPrefix(keys[0], string(prefix))); err != nil {
+				return err
+			}
+		}
+		b := tx.Bucket([]byte(hostPrefix)).Get([]byte(hostname))
+		if b == nil {
+			return core.ErrResourceNotFound
+		}
+		return json.Unmarshal(b, &host)
+	})
+	return host, err
+}
+
+// BuildIndexes does nothing, as indexes of existing hosts are built once the
+// database is opened.
+func (c *conn) BuildIndexes(ctx context.Context) error {
+	return ctx.Err()
+}
@@ -0,0 +1,63 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// ListHosts seeks the cursor right after the last returned host, so that only
+// the requested page is loaded. Bolt does not keep previous versions, thus
+// each page is read from the latest state and a revision could not be
+// requested.
+func (c *conn) ListHosts(ctx context.Context, opts core.ListOptions) (page core.HostPage, err error) {
+	if opts.Revision != 0 {
+		return page, core.ErrNotSupported
+	}
+	var after string
+	if opts.Continue != "" {
+		if _, after, err = core.DecodeContinue(opts.Continue); err != nil {
+			return page, err
+		}
+	}
+	err = c.view(ctx, func(tx *bbolt.Tx) error {
+		cursor := tx.Bucket([]byte(hostPrefix)).Cursor()
+		k, v := cursor.First()
+		if after != "" {
+			k, v = cursor.Seek([]byte(after))
+			if k != nil && string(k) == after {
+				k, v = cursor.Next()
+			}
+		}
+		for ; k != nil; k, v = cursor.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var host core.Host
+			if err := json.Unmarshal(v, &host); err != nil {
+				return err
+			}
+			ok, err := core.MatchHost(host, opts.Predicates)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			page.Hosts = append(page.Hosts, host)
+			if opts.Limit > 0 && int64(len(page.Hosts)) >= opts.Limit {
+				if next, _ := cursor.Next(); next != nil {
+					page.Continue = core.EncodeContinue(0, string(k))
+				}
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return core.HostPage{}, err
+	}
+	return page, nil
+}
@@ -0,0 +1,67 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	bbolt "go.etcd.io/bbolt"
+)
+
+const (
+	// profilePrefix is the bucket which holds credential profiles keyed by
+	// name.
+	profilePrefix = "profile"
+)
+
+func (c *conn) CreateCredentialProfile(ctx context.Context, profile core.CredentialProfile) error {
+	profile.UpdatedAt = time.Now().UTC()
+	return c.txnCreate(ctx, profilePrefix, profile.Name, profile, nil)
+}
+
+func (c *conn) GetCredentialProfile(ctx context.Context, name string) (profile core.CredentialProfile, err error) {
+	if err = c.getKey(ctx, profilePrefix, name, &profile); err != nil {
+		return
+	}
+	return profile, nil
+}
+
+func (c *conn) ListCredentialProfiles(ctx context.Context) (profiles []core.CredentialProfile, err error) {
+	err = c.view(ctx, func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(profilePrefix)).ForEach(func(k, v []byte) error {
+			var profile core.CredentialProfile
+			if err := json.Unmarshal(v, &profile); err != nil {
+				return err
+			}
+			profiles = append(profiles, profile)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+func (c *conn) UpdateCredentialProfile(ctx context.Context, name string, updater func(profile core.CredentialProfile) (core.CredentialProfile, error)) error {
+	return c.txnUpdate(ctx, profilePrefix, name, func(currentValue []byte) ([]byte, error) {
+		var current core.CredentialProfile
+		if len(currentValue) > 0 {
+			if err := json.Unmarshal(currentValue, &current); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := updater(current)
+		if err != nil {
+			return nil, err
+		}
+		updated.UpdatedAt = time.Now().UTC()
+		updated.Name = name
+		return json.Marshal(updated)
+	}, nil)
+}
+
+func (c *conn) DeleteCredentialProfile(ctx context.Context, name string) error {
+	return c.deleteKey(ctx, profilePrefix, name, nil)
+}
@@ -0,0 +1,52 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	bbolt "go.etcd.io/bbolt"
+)
+
+func (c *conn) RenameHost(ctx context.Context, id, newID string) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during renaming data entity '%s/%s' to '%s/%s' due to: %v", hostPrefix, id, hostPrefix, newID, err)
+		} else {
+			c.logger.Debugf("Moved key '%s/%s' to '%s/%s'", hostPrefix, id, hostPrefix, newID)
+		}
+	}()
+	if newID == "" {
+		return fmt.Errorf("New hostname must not be empty")
+	}
+	return c.update(ctx, func(tx *bbolt.Tx) error {
+		hosts := tx.Bucket([]byte(hostPrefix))
+		current := hosts.Get([]byte(id))
+		if current == nil {
+			return core.ErrResourceNotFound
+		}
+		if hosts.Get([]byte(newID)) != nil {
+			return core.ErrResourceAlreadyExists
+		}
+		var host core.Host
+		if err := json.Unmarshal(current, &host); err != nil {
+			return err
+		}
+		host.Hostname = newID
+		host.UpdatedAt = time.Now().UTC()
+		b, err := json.Marshal(host)
+		if err != nil {
+			return err
+		}
+		if err = updateHostIndexes(tx, current, b); err != nil {
+			return err
+		}
+		if err = hosts.Delete([]byte(id)); err != nil {
+			return err
+		}
+		return hosts.Put([]byte(newID), b)
+	})
+}
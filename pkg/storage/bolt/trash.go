@@ -0,0 +1,145 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	bbolt "go.etcd.io/bbolt"
+)
+
+const (
+	// trashPrefix is the bucket which holds trashed hosts keyed by hostname.
+	trashPrefix = "trash"
+)
+
+func (c *conn) TrashHost(ctx context.Context, id, reason string) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during trashing data entity '%s/%s' due to: %v", hostPrefix, id, err)
+		} else {
+			c.logger.Debugf("Moved key '%s/%s' to '%s/%s'", hostPrefix, id, trashPrefix, id)
+		}
+	}()
+	return c.update(ctx, func(tx *bbolt.Tx) error {
+		if err := c.purgeTrash(tx); err != nil {
+			return err
+		}
+		hosts := tx.Bucket([]byte(hostPrefix))
+		current := hosts.Get([]byte(id))
+		if current == nil {
+			return core.ErrResourceNotFound
+		}
+		trashed := core.TrashedHost{
+			DeletedAt: time.Now().UTC(),
+			Reason:    reason,
+		}
+		if err := json.Unmarshal(current, &trashed.Host); err != nil {
+			return err
+		}
+		b, err := json.Marshal(trashed)
+		if err != nil {
+			return err
+		}
+		if err = updateHostIndexes(tx, current, nil); err != nil {
+			return err
+		}
+		if err = hosts.Delete([]byte(id)); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(trashPrefix)).Put([]byte(id), b)
+	})
+}
+
+func (c *conn) ListTrash(ctx context.Context) (trashed []core.TrashedHost, err error) {
+	err = c.update(ctx, func(tx *bbolt.Tx) error {
+		if err := c.purgeTrash(tx); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(trashPrefix)).ForEach(func(k, v []byte) error {
+			var each core.TrashedHost
+			if err := json.Unmarshal(v, &each); err != nil {
+				return err
+			}
+			trashed = append(trashed, each)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return trashed, nil
+}
+
+func (c *conn) RestoreHost(ctx context.Context, id string) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during restoring data entity '%s/%s' due to: %v", hostPrefix, id, err)
+		} else {
+			c.logger.Debugf("Moved key '%s/%s' to '%s/%s'", trashPrefix, id, hostPrefix, id)
+		}
+	}()
+	return c.update(ctx, func(tx *bbolt.Tx) error {
+		if err := c.purgeTrash(tx); err != nil {
+			return err
+		}
+		trash := tx.Bucket([]byte(trashPrefix))
+		current := trash.Get([]byte(id))
+		if current == nil {
+			return core.ErrResourceNotFound
+		}
+		hosts := tx.Bucket([]byte(hostPrefix))
+		if hosts.Get([]byte(id)) != nil {
+			return core.ErrResourceAlreadyExists
+		}
+		var trashed core.TrashedHost
+		if err := json.Unmarshal(current, &trashed); err != nil {
+			return err
+		}
+		host := trashed.Host
+		host.UpdatedAt = time.Now().UTC()
+		b, err := json.Marshal(host)
+		if err != nil {
+			return err
+		}
+		if err = updateHostIndexes(tx, nil, b); err != nil {
+			return err
+		}
+		if err = trash.Delete([]byte(id)); err != nil {
+			return err
+		}
+		return hosts.Put([]byte(id), b)
+	})
+}
+
+// purgeTrash removes trashed hosts which have exceeded the retention period
+// within given writable transaction.
+func (c *conn) purgeTrash(tx *bbolt.Tx) error {
+	now := time.Now()
+	var expired [][]byte
+	trash := tx.Bucket([]byte(trashPrefix))
+	err := trash.ForEach(func(k, v []byte) error {
+		var each core.TrashedHost
+		if err := json.Unmarshal(v, &each); err != nil {
+			return err
+		}
+		if each.Expired(c.trashRetention, now) {
+			expired = append(expired, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Buckets must not be modified during iteration.
+	for _, k := range expired {
+		c.logger.Debugf("Purged expired key '%s/%s'", trashPrefix, k)
+		if err = trash.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
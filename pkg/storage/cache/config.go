@@ -0,0 +1,49 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultMaxStaleness = 10 * time.Second
+)
+
+// Config enables the read-through cache of hosts, which requires an adapter
+// supporting watch, i.e. etcd or memory.
+type Config struct {
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	// MaxStaleness bounds how long cached hosts are still served once the
+	// watch was lost, e.g. "10s". Reads go to the wrapped storage afterwards,
+	// until the cache is synced again. Zero means never serving hosts that
+	// might be stale.
+	MaxStaleness string `json:"max_staleness,omitempty" yaml:"max_staleness,omitempty"`
+}
+
+func (in *Config) maxStaleness() (time.Duration, error) {
+	if in.MaxStaleness == "" {
+		return defaultMaxStaleness, nil
+	}
+	d, err := time.ParseDuration(in.MaxStaleness)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid max staleness '%s' of cache: %v", in.MaxStaleness, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("Invalid max staleness '%s' of cache: it must not be negative", in.MaxStaleness)
+	}
+	return d, nil
+}
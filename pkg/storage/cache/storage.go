@@ -0,0 +1,480 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	zap "go.uber.org/zap"
+)
+
+const (
+	minResyncInterval = 500 * time.Millisecond
+	maxResyncInterval = 30 * time.Second
+	pruneInterval     = time.Minute
+)
+
+var errWatchClosed = errors.New("watch of hosts was closed")
+
+// entry is the cached state of a hostname. It is only replaced by a newer
+// revision, so that late events never roll it back. Entries of deleted hosts
+// are pruned once the watch has passed their revision, as no event to come
+// could be older.
+type entry struct {
+	// value is the encoded host, thus callers never share it
+	value    []byte
+	revision int64
+	deleted  bool
+	// dirty means the host is unknown since a write of this process, and it
+	// is read from the wrapped storage until the next change is observed.
+	dirty bool
+}
+
+func hostEntry(host core.Host, revision int64) *entry {
+	b, err := json.Marshal(host)
+	if err != nil {
+		return &entry{revision: revision, dirty: true}
+	}
+	return &entry{value: b, revision: revision}
+}
+
+func eventEntry(event core.HostEvent) *entry {
+	if event.Type == core.EventDeleted {
+		return &entry{revision: event.Revision, deleted: true}
+	}
+	return hostEntry(event.Host, event.Revision)
+}
+
+func (in *entry) decode() (host core.Host, err error) {
+	err = json.Unmarshal(in.value, &host)
+	host.Revision = in.revision
+	return host, err
+}
+
+// put replaces the entry of hostname with e, unless the current entry is
+// newer.
+func put(hosts map[string]*entry, hostname string, e *entry) {
+	if current, ok := hosts[hostname]; ok {
+		if current.revision > e.revision || current.revision == e.revision && !current.dirty {
+			return
+		}
+	}
+	hosts[hostname] = e
+}
+
+// Storage serves hosts from an in-memory copy of the wrapped storage, which
+// is warmed by ListHost and kept fresh by WatchHosts. Reads go to the wrapped
+// storage until the copy is synced, and once the watch has been lost for
+// longer than the staleness bound. Hosts written through Storage are re-read
+// afterwards, so that the process reads its own writes before their events
+// arrive. Listing by pages, history and other entities are not cached.
+type Storage struct {
+	core.Storage
+	maxStaleness time.Duration
+	logger       *zap.SugaredLogger
+
+	mu     sync.RWMutex
+	hosts  map[string]*entry
+	synced bool
+	lostAt time.Time
+	// watched is the revision of the last event applied to hosts.
+	watched int64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Wrap decorates storage with a cache configured by config, which is synced
+// in background until Close is called.
+func Wrap(storage core.Storage, config *Config, logger *zap.SugaredLogger) (*Storage, error) {
+	maxStaleness, err := config.maxStaleness()
+	if err != nil {
+		return nil, err
+	}
+	in := &Storage{
+		Storage:      storage,
+		maxStaleness: maxStaleness,
+		logger:       logger,
+		done:         make(chan struct{}),
+	}
+	var ctx context.Context
+	ctx, in.cancel = context.WithCancel(context.Background())
+	// the first watch is opened ahead, so that adapters without watch are
+	// reported right away.
+	events, stop, err := in.watch(ctx)
+	if err != nil {
+		in.cancel()
+		return nil, fmt.Errorf("Could not watch hosts for cache due to: %v", err)
+	}
+	go in.run(ctx, events, stop)
+	return in, nil
+}
+
+// Close stops syncing the cache and closes the wrapped storage.
+func (in *Storage) Close() error {
+	in.cancel()
+	<-in.done
+	return in.Storage.Close()
+}
+
+// WaitSynced blocks until hosts are served from the cache, or ctx is done.
+func (in *Storage) WaitSynced(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		in.mu.RLock()
+		synced := in.synced
+		in.mu.RUnlock()
+		if synced {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (in *Storage) watch(ctx context.Context) (<-chan core.HostEvent, context.CancelFunc, error) {
+	ctx, stop := context.WithCancel(ctx)
+	events, err := in.Storage.WatchHosts(ctx, 0)
+	if err != nil {
+		stop()
+		return nil, nil, err
+	}
+	return events, stop, nil
+}
+
+// run keeps the cache synced, and starts over with a new watch once it is
+// lost.
+func (in *Storage) run(ctx context.Context, events <-chan core.HostEvent, stop context.CancelFunc) {
+	defer close(in.done)
+	defer in.logger.Sync()
+	interval := minResyncInterval
+	for {
+		if events != nil {
+			err := in.sync(ctx, events)
+			stop()
+			if ctx.Err() != nil {
+				return
+			}
+			if in.lose() {
+				interval = minResyncInterval
+			}
+			in.logger.Errorf("Cache of hosts lost its watch due to: %v", err)
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+		if interval *= 2; interval > maxResyncInterval {
+			interval = maxResyncInterval
+		}
+		var err error
+		if events, stop, err = in.watch(ctx); err != nil {
+			in.logger.Errorf("Could not watch hosts for cache due to: %v", err)
+			events = nil
+		}
+	}
+}
+
+// sync warms a fresh copy of hosts with ListHost and applies events to it,
+// until the watch fails. Events observed during the listing are applied
+// afterwards, as the watch was opened before the listing started.
+func (in *Storage) sync(ctx context.Context, events <-chan core.HostEvent) error {
+	listed := make(chan []core.Host, 1)
+	failed := make(chan error, 1)
+	go func() {
+		hosts, err := in.Storage.ListHost(ctx)
+		if err != nil {
+			failed <- err
+			return
+		}
+		listed <- hosts
+	}()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+	pending := make(map[string]*entry)
+	var watched int64
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return errWatchClosed
+			}
+			if event.Err != nil {
+				return event.Err
+			}
+			watched = event.Revision
+			if pending != nil {
+				put(pending, event.Host.Hostname, eventEntry(event))
+				continue
+			}
+			in.mu.Lock()
+			put(in.hosts, event.Host.Hostname, eventEntry(event))
+			in.watched = watched
+			in.mu.Unlock()
+		case hosts := <-listed:
+			fresh := make(map[string]*entry, len(hosts))
+			for _, host := range hosts {
+				put(fresh, host.Hostname, hostEntry(host, host.Revision))
+			}
+			for hostname, e := range pending {
+				put(fresh, hostname, e)
+			}
+			pending = nil
+			in.mu.Lock()
+			in.hosts = fresh
+			in.synced = true
+			in.watched = watched
+			in.mu.Unlock()
+			in.logger.Debugf("Cache of %d hosts is synced", len(hosts))
+		case <-prune.C:
+			if pending == nil {
+				if n := in.prune(); n > 0 {
+					in.logger.Debugf("Pruned %d deleted hosts from cache", n)
+				}
+			}
+		case err := <-failed:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// prune removes entries of deleted hosts which the watch has passed, and
+// returns the number of entries removed. A missing entry means the host is
+// absent as well, while any later event replaces it either way.
+func (in *Storage) prune() (n int) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for hostname, e := range in.hosts {
+		if e.deleted && !e.dirty && e.revision <= in.watched {
+			delete(in.hosts, hostname)
+			n++
+		}
+	}
+	return n
+}
+
+// lose marks the cache as out of sync, and returns whether it was synced.
+func (in *Storage) lose() bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	synced := in.synced
+	if synced {
+		in.synced = false
+		in.lostAt = time.Now()
+	}
+	return synced
+}
+
+// usable returns whether cached hosts could be served. Caller must hold the
+// lock.
+func (in *Storage) usable() bool {
+	if in.hosts == nil {
+		return false
+	}
+	return in.synced || time.Since(in.lostAt) <= in.maxStaleness
+}
+
+// cached returns the entry of hostname, or ok is false if it must be read
+// from the wrapped storage.
+func (in *Storage) cached(hostname string) (e *entry, ok bool) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if !in.usable() {
+		return nil, false
+	}
+	e = in.hosts[hostname]
+	if e == nil {
+		e = &entry{deleted: true}
+	}
+	return e, !e.dirty
+}
+
+// snapshot returns all cached hosts sorted by hostname, or ok is false if
+// they must be read from the wrapped storage.
+func (in *Storage) snapshot() (hosts []core.Host, ok bool, err error) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if !in.usable() {
+		return nil, false, nil
+	}
+	for _, e := range in.hosts {
+		if e.dirty {
+			return nil, false, nil
+		}
+		if e.deleted {
+			continue
+		}
+		host, err := e.decode()
+		if err != nil {
+			return nil, true, err
+		}
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Hostname < hosts[j].Hostname })
+	return hosts, true, nil
+}
+
+func (in *Storage) GetHost(ctx context.Context, id string) (core.Host, error) {
+	e, ok := in.cached(id)
+	if !ok {
+		return in.Storage.GetHost(ctx, id)
+	}
+	if err := ctx.Err(); err != nil {
+		return core.Host{}, err
+	}
+	if e.deleted {
+		return core.Host{}, core.ErrResourceNotFound
+	}
+	return e.decode()
+}
+
+func (in *Storage) ListHost(ctx context.Context) ([]core.Host, error) {
+	hosts, ok, err := in.snapshot()
+	if !ok {
+		return in.Storage.ListHost(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return hosts, ctx.Err()
+}
+
+func (in *Storage) GetHostBy(ctx context.Context, field core.IndexField, value string) (core.Host, error) {
+	hosts, ok, err := in.snapshot()
+	if !ok {
+		return in.Storage.GetHostBy(ctx, field, value)
+	}
+	if err != nil {
+		return core.Host{}, err
+	}
+	if err = ctx.Err(); err != nil {
+		return core.Host{}, err
+	}
+	selected := core.SelectHostsBy(hosts, field, value)
+	switch len(selected) {
+	case 0:
+		return core.Host{}, core.ErrResourceNotFound
+	case 1:
+		return selected[0], nil
+	}
+	return core.Host{}, core.ErrAmbiguousIndex
+}
+
+// write runs fn against the wrapped storage, and re-reads hosts it might have
+// changed regardless of its error, which could be returned after the change
+// was made.
+func (in *Storage) write(ctx context.Context, fn func() error, hostnames ...string) error {
+	in.mu.RLock()
+	before := make(map[string]int64, len(hostnames))
+	for _, each := range hostnames {
+		if e := in.hosts[each]; e != nil {
+			before[each] = e.revision
+		}
+	}
+	in.mu.RUnlock()
+	err := fn()
+	for _, each := range hostnames {
+		in.refresh(ctx, each, before[each])
+	}
+	return err
+}
+
+// refresh re-reads hostname whose cached revision was before. A host found
+// absent, or not read at all, is only recorded unless a newer change was
+// observed meanwhile.
+func (in *Storage) refresh(ctx context.Context, hostname string, before int64) {
+	in.mu.RLock()
+	warmed := in.hosts != nil
+	in.mu.RUnlock()
+	if !warmed {
+		return
+	}
+	host, err := in.Storage.GetHost(ctx, hostname)
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if err == nil {
+		put(in.hosts, hostname, hostEntry(host, host.Revision))
+		return
+	}
+	if current := in.hosts[hostname]; current != nil && current.revision != before {
+		return
+	}
+	if err == core.ErrResourceNotFound {
+		in.hosts[hostname] = &entry{revision: before, deleted: true}
+		return
+	}
+	in.logger.Errorf("Could not refresh cache of host '%s' due to: %v", hostname, err)
+	in.hosts[hostname] = &entry{revision: before, dirty: true}
+}
+
+func (in *Storage) CreateHost(ctx context.Context, host core.Host) error {
+	return in.write(ctx, func() error {
+		return in.Storage.CreateHost(ctx, host)
+	}, host.Hostname)
+}
+
+func (in *Storage) UpdateHost(ctx context.Context, id string, updater func(host core.Host) (core.Host, error)) error {
+	return in.write(ctx, func() error {
+		return in.Storage.UpdateHost(ctx, id, updater)
+	}, id)
+}
+
+func (in *Storage) DeleteHost(ctx context.Context, id string) error {
+	return in.write(ctx, func() error {
+		return in.Storage.DeleteHost(ctx, id)
+	}, id)
+}
+
+func (in *Storage) ApplyBatch(ctx context.Context, ops []core.BatchOp) error {
+	var hostnames []string
+	for _, op := range ops {
+		hostnames = append(hostnames, op.Host.Hostname)
+	}
+	return in.write(ctx, func() error {
+		return in.Storage.ApplyBatch(ctx, ops)
+	}, hostnames...)
+}
+
+func (in *Storage) RenameHost(ctx context.Context, id, newID string) error {
+	return in.write(ctx, func() error {
+		return in.Storage.RenameHost(ctx, id, newID)
+	}, id, newID)
+}
+
+func (in *Storage) TrashHost(ctx context.Context, id, reason string) error {
+	return in.write(ctx, func() error {
+		return in.Storage.TrashHost(ctx, id, reason)
+	}, id)
+}
+
+func (in *Storage) RestoreHost(ctx context.Context, id string) error {
+	return in.write(ctx, func() error {
+		return in.Storage.RestoreHost(ctx, id)
+	}, id)
+}
@@ -0,0 +1,346 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	memory "github.com/universonic/ivy-utils/pkg/storage/memory"
+	zap "go.uber.org/zap"
+)
+
+// controlledWatch forwards the watch of the wrapped storage unless it is
+// muted, and fails it once broken, refusing to watch again.
+type controlledWatch struct {
+	core.Storage
+	mu     sync.Mutex
+	muted  bool
+	broken chan struct{}
+}
+
+func newControlledWatch(storage core.Storage) *controlledWatch {
+	return &controlledWatch{Storage: storage, broken: make(chan struct{})}
+}
+
+func (in *controlledWatch) mute() {
+	in.mu.Lock()
+	in.muted = true
+	in.mu.Unlock()
+}
+
+func (in *controlledWatch) breakWatch() {
+	close(in.broken)
+}
+
+func (in *controlledWatch) WatchHosts(ctx context.Context, fromRevision int64) (<-chan core.HostEvent, error) {
+	select {
+	case <-in.broken:
+		return nil, errors.New("Watch is unavailable")
+	default:
+	}
+	events, err := in.Storage.WatchHosts(ctx, fromRevision)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan core.HostEvent)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				in.mu.Lock()
+				muted := in.muted
+				in.mu.Unlock()
+				if muted {
+					continue
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			case <-in.broken:
+				select {
+				case out <- core.HostEvent{Err: errors.New("Watch was lost")}:
+				case <-ctx.Done():
+				}
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func openMemory(t *testing.T) core.Storage {
+	storage, err := memory.New().Open(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+func openCache(t *testing.T, storage core.Storage, maxStaleness string) *Storage {
+	cache, err := Wrap(storage, &Config{Enabled: true, MaxStaleness: maxStaleness}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = cache.WaitSynced(ctx); err != nil {
+		cache.Close()
+		t.Fatal(err)
+	}
+	return cache
+}
+
+func createHost(t *testing.T, storage core.Storage, hostname, ipmiAddress string) {
+	host := core.NewHost()
+	host.Hostname = hostname
+	host.IPMIAddress = ipmiAddress
+	host.ExtraInfo["rack"] = "a1"
+	if err := storage.CreateHost(context.Background(), *host); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// eventually polls cond until it holds, or fails after a few seconds.
+func eventually(t *testing.T, cond func() bool, format string, args ...interface{}) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf(format, args...)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPutKeepsNewerRevision(t *testing.T) {
+	hosts := make(map[string]*entry)
+	put(hosts, "node-01", &entry{revision: 5, value: []byte(`{}`)})
+	put(hosts, "node-01", &entry{revision: 3, value: []byte(`{}`)})
+	if hosts["node-01"].revision != 5 {
+		t.Errorf("Expected older revision to be ignored, got revision %d", hosts["node-01"].revision)
+	}
+	put(hosts, "node-01", &entry{revision: 5, deleted: true})
+	if hosts["node-01"].deleted {
+		t.Error("Expected the same revision not to replace a clean entry")
+	}
+	put(hosts, "node-01", &entry{revision: 5, dirty: true})
+	put(hosts, "node-01", &entry{revision: 5, value: []byte(`{}`)})
+	if hosts["node-01"].dirty {
+		t.Error("Expected the same revision to replace a dirty entry")
+	}
+	put(hosts, "node-01", &entry{revision: 7, deleted: true})
+	put(hosts, "node-01", &entry{revision: 6, value: []byte(`{}`)})
+	if !hosts["node-01"].deleted {
+		t.Error("Expected a late event not to resurrect a deleted host")
+	}
+}
+
+func TestCacheFollowsWatch(t *testing.T) {
+	ctx := context.Background()
+	storage := openMemory(t)
+	createHost(t, storage, "node-01", "10.0.0.1")
+	cache := openCache(t, storage, "")
+	defer cache.Close()
+
+	host, err := cache.GetHost(ctx, "node-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host.IPMIAddress != "10.0.0.1" || host.Revision == 0 {
+		t.Errorf("Expected host listed with its revision, got: %+v", host)
+	}
+
+	createHost(t, storage, "node-02", "10.0.0.2")
+	eventually(t, func() bool {
+		host, err := cache.GetHostBy(ctx, core.IndexIPMIAddress, "10.0.0.2")
+		return err == nil && host.Hostname == "node-02"
+	}, "Expected host created elsewhere to be cached")
+
+	if err = storage.DeleteHost(ctx, "node-02"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool {
+		_, err := cache.GetHost(ctx, "node-02")
+		return err == core.ErrResourceNotFound
+	}, "Expected host deleted elsewhere to be removed from cache")
+	hosts, err := cache.ListHost(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].Hostname != "node-01" {
+		t.Errorf("Expected only 'node-01' listed, got: %+v", hosts)
+	}
+
+	// returned hosts are copies
+	host.ExtraInfo["owner"] = "ops"
+	if again, _ := cache.GetHost(ctx, "node-01"); again.ExtraInfo["owner"] != nil {
+		t.Error("Expected cached host not to be shared with callers")
+	}
+}
+
+func TestPruneTombstones(t *testing.T) {
+	ctx := context.Background()
+	storage := openMemory(t)
+	createHost(t, storage, "node-01", "10.0.0.1")
+	createHost(t, storage, "node-02", "10.0.0.2")
+	cache := openCache(t, storage, "")
+	defer cache.Close()
+
+	if err := storage.DeleteHost(ctx, "node-01"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool {
+		_, err := cache.GetHost(ctx, "node-01")
+		return err == core.ErrResourceNotFound
+	}, "Expected deleted host to be removed from cache")
+
+	cache.mu.RLock()
+	tombstone := cache.hosts["node-01"]
+	cache.mu.RUnlock()
+	if tombstone == nil || !tombstone.deleted {
+		t.Fatalf("Expected tombstone of deleted host, got: %+v", tombstone)
+	}
+	// a tombstone ahead of the watch is kept
+	cache.mu.Lock()
+	cache.hosts["node-03"] = &entry{revision: cache.watched + 1, deleted: true}
+	cache.mu.Unlock()
+
+	if n := cache.prune(); n != 1 {
+		t.Errorf("Expected 1 tombstone pruned, got %d", n)
+	}
+	cache.mu.RLock()
+	_, stale := cache.hosts["node-01"]
+	_, ahead := cache.hosts["node-03"]
+	cache.mu.RUnlock()
+	if stale || !ahead {
+		t.Errorf("Expected only tombstones passed by the watch to be pruned")
+	}
+	if _, err := cache.GetHost(ctx, "node-01"); err != core.ErrResourceNotFound {
+		t.Errorf("Expected pruned host to stay absent, got error: %v", err)
+	}
+	if _, err := cache.GetHost(ctx, "node-02"); err != nil {
+		t.Errorf("Expected host to be kept, got error: %v", err)
+	}
+}
+
+func TestCacheReadsOwnWrites(t *testing.T) {
+	ctx := context.Background()
+	storage := openMemory(t)
+	createHost(t, storage, "node-01", "10.0.0.1")
+	createHost(t, storage, "node-02", "10.0.0.2")
+	watch := newControlledWatch(storage)
+	cache := openCache(t, watch, "")
+	defer cache.Close()
+	// no event arrives from now on
+	watch.mute()
+
+	err := cache.UpdateHost(ctx, "node-01", func(host core.Host) (core.Host, error) {
+		host.IPMIAddress = "10.0.0.9"
+		return host, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, err := cache.GetHost(ctx, "node-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host.IPMIAddress != "10.0.0.9" {
+		t.Errorf("Expected updated host to be read back, got IPMI address '%s'", host.IPMIAddress)
+	}
+	if err = cache.DeleteHost(ctx, "node-02"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cache.GetHost(ctx, "node-02"); err != core.ErrResourceNotFound {
+		t.Errorf("Expected deleted host to be absent, got error: %v", err)
+	}
+	if err = cache.RenameHost(ctx, "node-01", "node-11"); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := cache.ListHost(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].Hostname != "node-11" {
+		t.Errorf("Expected only 'node-11' listed, got: %+v", hosts)
+	}
+
+	// changes made elsewhere are not observed without events
+	createHost(t, storage, "node-03", "10.0.0.3")
+	if _, err = cache.GetHost(ctx, "node-03"); err != core.ErrResourceNotFound {
+		t.Errorf("Expected host created elsewhere to be served from cache, got error: %v", err)
+	}
+}
+
+func TestCacheMaxStaleness(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		maxStaleness string
+		// stale means hosts are still served from cache once the watch is
+		// lost
+		stale bool
+	}{
+		{"1h", true},
+		{"0s", false},
+	}
+	for _, c := range cases {
+		t.Run(c.maxStaleness, func(t *testing.T) {
+			storage := openMemory(t)
+			createHost(t, storage, "node-01", "10.0.0.1")
+			watch := newControlledWatch(storage)
+			cache := openCache(t, watch, c.maxStaleness)
+			defer cache.Close()
+
+			watch.breakWatch()
+			eventually(t, func() bool {
+				cache.mu.RLock()
+				defer cache.mu.RUnlock()
+				return !cache.synced
+			}, "Expected cache to notice the lost watch")
+			createHost(t, storage, "node-02", "10.0.0.2")
+
+			_, err := cache.GetHost(ctx, "node-02")
+			if c.stale && err != core.ErrResourceNotFound {
+				t.Errorf("Expected stale cache to be served, got error: %v", err)
+			}
+			if !c.stale && err != nil {
+				t.Errorf("Expected read from storage, got error: %v", err)
+			}
+			hosts, err := cache.ListHost(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := 2
+			if c.stale {
+				want = 1
+			}
+			if len(hosts) != want {
+				t.Errorf("Expected %d hosts listed, got %d", want, len(hosts))
+			}
+		})
+	}
+}
@@ -14,17 +14,94 @@
 
 package core
 
-// Storage is the interface that is used for interacting with database.
+import (
+	"context"
+	"time"
+)
+
+// Storage is the interface that is used for interacting with database. All
+// methods but Close take a context, and give up with its error once it is
+// done.
 type Storage interface {
 	Close() error
 
-	CreateHost(host Host) error
+	CreateHost(ctx context.Context, host Host) error
+
+	GetHost(ctx context.Context, id string) (Host, error)
+
+	ListHost(ctx context.Context) ([]Host, error)
+
+	// ListHosts returns a page of hosts sorted by hostname which satisfy the
+	// predicates of opts. Following pages are read at the same revision as
+	// the first one, if the adapter tracks revisions.
+	ListHosts(ctx context.Context, opts ListOptions) (HostPage, error)
+
+	// GetHostBy looks host up by an indexed field. It returns
+	// ErrAmbiguousIndex if multiple hosts share the value of a non-unique
+	// field.
+	GetHostBy(ctx context.Context, field IndexField, value string) (Host, error)
+
+	UpdateHost(ctx context.Context, id string, updater func(host Host) (Host, error)) error
+
+	DeleteHost(ctx context.Context, id string) error
+
+	// ApplyBatch applies all operations or none of them. If any precondition
+	// fails, a *BatchError reporting every failed operation is returned.
+	ApplyBatch(ctx context.Context, ops []BatchOp) error
+
+	// RenameHost changes the hostname of host atomically, while its GUID and
+	// other fields are kept. It fails with ErrResourceAlreadyExists if newID
+	// is taken by another host.
+	RenameHost(ctx context.Context, id, newID string) error
+
+	// TrashHost moves host into the trash bin, where it is excluded from
+	// listing and will be purged after the retention period.
+	TrashHost(ctx context.Context, id, reason string) error
+
+	ListTrash(ctx context.Context) ([]TrashedHost, error)
+
+	// RestoreHost moves host from the trash bin back, which fails with
+	// ErrResourceAlreadyExists if another host has taken the hostname.
+	RestoreHost(ctx context.Context, id string) error
+
+	// WatchHosts streams changes of hosts since given revision. A zero
+	// revision means starting from the current one. The channel is closed
+	// once ctx is done, or after an event carrying an error was sent.
+	WatchHosts(ctx context.Context, fromRevision int64) (<-chan HostEvent, error)
+
+	// HostHistory returns known versions of host from the newest to the
+	// oldest. Versions older than the last compaction are unavailable.
+	HostHistory(ctx context.Context, id string) ([]HostRevision, error)
+
+	// ListHostAt returns all hosts as of given revision.
+	ListHostAt(ctx context.Context, revision int64) ([]Host, error)
+
+	CreateCredentialProfile(ctx context.Context, profile CredentialProfile) error
+
+	GetCredentialProfile(ctx context.Context, name string) (CredentialProfile, error)
+
+	ListCredentialProfiles(ctx context.Context) ([]CredentialProfile, error)
+
+	UpdateCredentialProfile(ctx context.Context, name string, updater func(profile CredentialProfile) (CredentialProfile, error)) error
+
+	// DeleteCredentialProfile removes the profile regardless of hosts which
+	// still refer to it.
+	DeleteCredentialProfile(ctx context.Context, name string) error
 
-	GetHost(id string) (Host, error)
+	// LockHost acquires the lock of host described by lock, waiting until ctx
+	// is done, in which case ErrHostLocked is returned. The lock is kept alive
+	// until unlock is called, and expires after ttl once its holder is gone.
+	// Adapters without locks return ErrNotSupported.
+	LockHost(ctx context.Context, lock HostLock, ttl time.Duration) (unlock func() error, err error)
 
-	ListHost() ([]Host, error)
+	ListHostLocks(ctx context.Context) ([]HostLock, error)
 
-	UpdateHost(id string, updater func(host Host) (Host, error)) error
+	// ForceUnlockHost releases the lock of host regardless of its holder.
+	ForceUnlockHost(ctx context.Context, id string) error
 
-	DeleteHost(id string) error
+	// BuildIndexes indexes hosts written before the adapter kept indexes. It
+	// fails with ErrIndexConflict if hosts share the value of a unique field.
+	// Adapters which index hosts whenever they are opened, or keep no index,
+	// do nothing.
+	BuildIndexes(ctx context.Context) error
 }
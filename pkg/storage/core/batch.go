@@ -0,0 +1,210 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// BatchAction is the kind of change of a batch operation.
+type BatchAction string
+
+const (
+	BatchCreate BatchAction = "create"
+	BatchUpdate BatchAction = "update"
+	BatchDelete BatchAction = "delete"
+)
+
+// BatchOp is a single change of host within a batch. Create requires the host
+// to be absent, while update and delete require it to exist. Delete only
+// cares about the hostname.
+type BatchOp struct {
+	Action BatchAction `json:"action" yaml:"action"`
+	Host   Host        `json:"host" yaml:"host"`
+	// Update computes the updated host from the current one. If it is nil,
+	// Host replaces the current host, whose GUID is kept unless Host carries
+	// a valid one.
+	Update func(current Host) (Host, error) `json:"-" yaml:"-"`
+}
+
+// BatchFailure is the failure of the batch operation at Index.
+type BatchFailure struct {
+	Index    int
+	Hostname string
+	Err      error
+}
+
+// BatchError reports operations that prevented a batch from being applied,
+// in which case none of the operations was applied.
+type BatchError struct {
+	Failures []BatchFailure
+}
+
+func (in *BatchError) Error() string {
+	var msgs []string
+	for _, each := range in.Failures {
+		msgs = append(msgs, fmt.Sprintf("#%d '%s': %v", each.Index, each.Hostname, each.Err))
+	}
+	return fmt.Sprintf("%d operations of batch failed: %s", len(in.Failures), strings.Join(msgs, "; "))
+}
+
+func (in *BatchError) add(index int, hostname string, err error) {
+	in.Failures = append(in.Failures, BatchFailure{Index: index, Hostname: hostname, Err: err})
+}
+
+// BatchChange is the outcome of a batch operation, where Current and Updated
+// are nil if the host is absent before and after the change respectively.
+type BatchChange struct {
+	Index   int
+	Current *Host
+	Updated *Host
+}
+
+// PlanBatch checks all preconditions of ops and computes their changes, so
+// that adapters could apply them without any failure but I/O errors. Current
+// returns the current host of given hostname, or nil if it is absent. Owners
+// returns hostnames holding the value of given unique index entry, and could
+// be nil if the adapter enforces unique indexes by itself.
+//
+// Unique values are checked against the state after the whole batch, thus a
+// value could be moved from one host to another within the same batch.
+func PlanBatch(ops []BatchOp, current func(hostname string) (*Host, error), owners func(entry IndexEntry) ([]string, error)) ([]BatchChange, error) {
+	failures := new(BatchError)
+	touched := make(map[string]bool)
+	for i, op := range ops {
+		switch {
+		case op.Action != BatchCreate && op.Action != BatchUpdate && op.Action != BatchDelete:
+			failures.add(i, op.Host.Hostname, fmt.Errorf("Unknown action '%s'", op.Action))
+		case op.Host.Hostname == "":
+			failures.add(i, op.Host.Hostname, fmt.Errorf("Hostname is required"))
+		case touched[op.Host.Hostname]:
+			failures.add(i, op.Host.Hostname, fmt.Errorf("Host is changed more than once"))
+		}
+		touched[op.Host.Hostname] = true
+	}
+	if len(failures.Failures) > 0 {
+		return nil, failures
+	}
+	now := time.Now().UTC()
+	changes := make([]BatchChange, 0, len(ops))
+	for i, op := range ops {
+		cur, err := current(op.Host.Hostname)
+		if err != nil {
+			return nil, err
+		}
+		change := BatchChange{Index: i, Current: cur}
+		switch op.Action {
+		case BatchCreate:
+			if cur != nil {
+				failures.add(i, op.Host.Hostname, ErrResourceAlreadyExists)
+				continue
+			}
+			updated := op.Host
+			if _, err := uuid.FromString(updated.GUID); err != nil {
+				updated.GUID = uuid.NewV4().String()
+			}
+			change.Updated = &updated
+		case BatchUpdate:
+			if cur == nil {
+				failures.add(i, op.Host.Hostname, ErrResourceNotFound)
+				continue
+			}
+			if cur.ExtraInfo == nil {
+				cur.ExtraInfo = make(ExtendableFields)
+			}
+			updated := op.Host
+			if op.Update != nil {
+				if updated, err = op.Update(*cur); err != nil {
+					failures.add(i, op.Host.Hostname, err)
+					continue
+				}
+			}
+			if _, err := uuid.FromString(updated.GUID); err != nil {
+				updated.GUID = cur.GUID
+			}
+			if _, err := uuid.FromString(updated.GUID); err != nil {
+				updated.GUID = uuid.NewV4().String()
+			}
+			updated.Hostname = op.Host.Hostname
+			change.Updated = &updated
+		case BatchDelete:
+			if cur == nil {
+				failures.add(i, op.Host.Hostname, ErrResourceNotFound)
+				continue
+			}
+		}
+		if change.Updated != nil {
+			change.Updated.UpdatedAt = now
+		}
+		changes = append(changes, change)
+	}
+	// unique values claimed by hosts of the batch
+	claimed := make(map[IndexEntry]bool)
+	for _, change := range changes {
+		if change.Updated == nil {
+			continue
+		}
+		conflict, err := claimUniqueIndexes(*change.Updated, claimed, touched, owners)
+		if err != nil {
+			return nil, err
+		}
+		if conflict {
+			failures.add(change.Index, change.Updated.Hostname, ErrIndexConflict)
+		}
+	}
+	if len(failures.Failures) > 0 {
+		sort.Slice(failures.Failures, func(i, j int) bool {
+			return failures.Failures[i].Index < failures.Failures[j].Index
+		})
+		return nil, failures
+	}
+	return changes, nil
+}
+
+// claimUniqueIndexes records unique values of host in claimed, and returns
+// whether any of them is claimed by another host of the batch or held by a
+// host out of the batch.
+func claimUniqueIndexes(host Host, claimed map[IndexEntry]bool, touched map[string]bool, owners func(entry IndexEntry) ([]string, error)) (conflict bool, err error) {
+	for _, entry := range HostIndexEntries(host) {
+		if !entry.Field.Unique() {
+			continue
+		}
+		value := IndexEntry{Field: entry.Field, Value: entry.Value}
+		if claimed[value] {
+			conflict = true
+			continue
+		}
+		claimed[value] = true
+		if owners == nil {
+			continue
+		}
+		holders, err := owners(entry)
+		if err != nil {
+			return false, err
+		}
+		for _, holder := range holders {
+			// hosts of the batch release their values anyway
+			if !touched[holder] {
+				conflict = true
+			}
+		}
+	}
+	return conflict, nil
+}
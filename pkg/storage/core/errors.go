@@ -22,4 +22,25 @@ var (
 
 	// ErrResourceAlreadyExists is the error returned by storages if a resource ID is taken during a create.
 	ErrResourceAlreadyExists = errors.New("ID already exists")
+
+	// ErrNotSupported is the error returned by storages if an operation is not supported by the adapter.
+	ErrNotSupported = errors.New("operation not supported by storage adapter")
+
+	// ErrInvalidContinue is the error returned by storages if a continue token is malformed.
+	ErrInvalidContinue = errors.New("invalid continue token")
+
+	// ErrIndexConflict is the error returned by storages if a unique field of host is taken by another host.
+	ErrIndexConflict = errors.New("unique field is taken by another host")
+
+	// ErrAmbiguousIndex is the error returned by storages if multiple hosts are found by a non-unique field.
+	ErrAmbiguousIndex = errors.New("multiple hosts matched")
+
+	// ErrHostLocked is the error returned by storages if the lock of host is held by another holder.
+	ErrHostLocked = errors.New("host is locked by another holder")
+
+	// ErrConcurrentUpdate is the error returned by storages if an optimistic update lost the race against another update.
+	ErrConcurrentUpdate = errors.New("concurrent conflicting update happened")
+
+	// ErrRevisionMismatch is the error returned if a host was changed since the revision expected by a compare-and-set update.
+	ErrRevisionMismatch = errors.New("host was changed since the expected revision")
 )
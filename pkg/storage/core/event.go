@@ -0,0 +1,35 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+// EventType indicates the kind of change of an entity.
+type EventType string
+
+const (
+	EventCreated EventType = "CREATED"
+	EventUpdated EventType = "UPDATED"
+	EventDeleted EventType = "DELETED"
+)
+
+// HostEvent indicates a change of host. For deleted hosts, Host carries the
+// last known value if available, or the hostname only.
+type HostEvent struct {
+	Type     EventType `json:"type,omitempty" yaml:"type,omitempty"`
+	Revision int64     `json:"revision,omitempty" yaml:"revision,omitempty"`
+	Host     Host      `json:"host" yaml:"host"`
+	// Err is set if the watch was terminated due to an error, which would be
+	// the last event sent before the channel is closed.
+	Err error `json:"-" yaml:"-"`
+}
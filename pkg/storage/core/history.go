@@ -0,0 +1,101 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// HostRevision is a historical version of host.
+type HostRevision struct {
+	Revision int64 `json:"revision" yaml:"revision"`
+	Host     Host  `json:"host" yaml:"host"`
+}
+
+// FieldChange is a change of a single field between two versions of host.
+// Fields of ExtraInfo are named as 'extra_info.<key>'.
+type FieldChange struct {
+	Field string `json:"field" yaml:"field"`
+	Old   string `json:"old,omitempty" yaml:"old,omitempty"`
+	New   string `json:"new,omitempty" yaml:"new,omitempty"`
+}
+
+// DiffHost returns changed fields from old to new, sorted by field name.
+// UpdatedAt is ignored as it changes on every write.
+func DiffHost(old, new Host) ([]FieldChange, error) {
+	oldFields, err := flattenHost(old)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := flattenHost(new)
+	if err != nil {
+		return nil, err
+	}
+	var changes []FieldChange
+	for field, oldValue := range oldFields {
+		if newValue := newFields[field]; newValue != oldValue {
+			changes = append(changes, FieldChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+	for field, newValue := range newFields {
+		if _, ok := oldFields[field]; !ok {
+			changes = append(changes, FieldChange{Field: field, New: newValue})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+// flattenHost converts host into a mapping from field name to its string
+// representation, omitting empty fields.
+func flattenHost(host Host) (map[string]string, error) {
+	b, err := json.Marshal(host)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err = json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	delete(raw, "updated_at")
+	delete(raw, "schema_version")
+	fields := make(map[string]string)
+	for field, value := range raw {
+		if field != "extra_info" {
+			fields[field] = stringify(value)
+			continue
+		}
+		extraInfo, _ := value.(map[string]interface{})
+		for k, v := range extraInfo {
+			fields["extra_info."+k] = stringify(v)
+		}
+	}
+	return fields, nil
+}
+
+func stringify(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(s)
+		return string(b)
+	}
+	return fmt.Sprintf("%v", v)
+}
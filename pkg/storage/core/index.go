@@ -0,0 +1,163 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// IndexField is a field of host which could be used to look hosts up besides
+// the hostname.
+type IndexField string
+
+const (
+	IndexGUID        IndexField = "guid"
+	IndexIPMIAddress IndexField = "ipmi_addr"
+	IndexSSHAddress  IndexField = "ssh_addr"
+	// IndexSerial refers to the 'serial' field of ExtraInfo.
+	IndexSerial IndexField = "serial"
+)
+
+// IndexFields are all indexed fields of host.
+var IndexFields = []IndexField{IndexGUID, IndexIPMIAddress, IndexSSHAddress, IndexSerial}
+
+// ParseIndexField accepts both the field name and its command line flavour,
+// e.g. 'ipmi_addr' or 'ipmi-address'.
+func ParseIndexField(s string) (IndexField, error) {
+	switch strings.Replace(strings.ToLower(s), "-", "_", -1) {
+	case "guid":
+		return IndexGUID, nil
+	case "ipmi_addr", "ipmi_address":
+		return IndexIPMIAddress, nil
+	case "ssh_addr", "ssh_address":
+		return IndexSSHAddress, nil
+	case "serial":
+		return IndexSerial, nil
+	}
+	return "", fmt.Errorf("Unknown index field '%s'", s)
+}
+
+// Unique returns whether no two hosts could share the same value of field.
+func (in IndexField) Unique() bool {
+	return in == IndexGUID || in == IndexIPMIAddress
+}
+
+// ValueOf returns the value of field in host, or an empty string if it is
+// absent. Empty values are never indexed.
+func (in IndexField) ValueOf(host Host) string {
+	switch in {
+	case IndexGUID:
+		return host.GUID
+	case IndexIPMIAddress:
+		return host.IPMIAddress
+	case IndexSSHAddress:
+		return host.SSHAddress
+	case IndexSerial:
+		return stringify(host.ExtraInfo["serial"])
+	}
+	return ""
+}
+
+// IndexEntry is a single index record of host.
+type IndexEntry struct {
+	Field    IndexField
+	Value    string
+	Hostname string
+}
+
+// Key returns the key of entry relative to the index prefix of adapters.
+// Entries of unique fields are keyed by value as '<field>/<value>' and point
+// to the hostname, while the others are keyed as '<field>/<value>/<hostname>'
+// so that they could be found by a prefix scan.
+func (in IndexEntry) Key() string {
+	key := ValuePrefix(in.Field, in.Value)
+	if in.Field.Unique() {
+		return strings.TrimSuffix(key, "/")
+	}
+	return key + url.PathEscape(in.Hostname)
+}
+
+// ValuePrefix returns the common key prefix of all entries of given value,
+// relative to the index prefix of adapters.
+func ValuePrefix(field IndexField, value string) string {
+	return string(field) + "/" + url.PathEscape(value) + "/"
+}
+
+// HostIndexEntries returns index entries of all non-empty indexed fields.
+func HostIndexEntries(host Host) (entries []IndexEntry) {
+	for _, field := range IndexFields {
+		if value := field.ValueOf(host); value != "" {
+			entries = append(entries, IndexEntry{Field: field, Value: value, Hostname: host.Hostname})
+		}
+	}
+	return entries
+}
+
+// DiffIndexEntries returns entries to be removed and added when a host
+// changes from old to new, where nil means the host is absent.
+func DiffIndexEntries(old, new *Host) (removed, added []IndexEntry) {
+	oldEntries := make(map[IndexEntry]bool)
+	if old != nil {
+		for _, each := range HostIndexEntries(*old) {
+			oldEntries[each] = true
+		}
+	}
+	newEntries := make(map[IndexEntry]bool)
+	if new != nil {
+		for _, each := range HostIndexEntries(*new) {
+			newEntries[each] = true
+			if !oldEntries[each] {
+				added = append(added, each)
+			}
+		}
+	}
+	if old != nil {
+		for _, each := range HostIndexEntries(*old) {
+			if !newEntries[each] {
+				removed = append(removed, each)
+			}
+		}
+	}
+	return removed, added
+}
+
+// SelectHostsBy returns hosts whose field equals to value, which is useful for
+// adapters that do not maintain indexes.
+func SelectHostsBy(hosts []Host, field IndexField, value string) (selected []Host) {
+	for _, host := range hosts {
+		if value != "" && field.ValueOf(host) == value {
+			selected = append(selected, host)
+		}
+	}
+	return selected
+}
+
+// CheckUniqueIndexes returns ErrIndexConflict if any unique field of host is
+// taken by another one of hosts.
+func CheckUniqueIndexes(hosts []Host, host Host) error {
+	for _, field := range IndexFields {
+		if !field.Unique() {
+			continue
+		}
+		for _, each := range SelectHostsBy(hosts, field, field.ValueOf(host)) {
+			if each.Hostname != host.Hostname {
+				return ErrIndexConflict
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ListOptions controls which hosts are returned by Storage.ListHosts.
+type ListOptions struct {
+	// Limit is the maximum number of hosts in a page. Zero means no limit.
+	Limit int64
+	// Continue is the token returned by a previous page, which resumes the
+	// listing right after the last host of that page.
+	Continue string
+	// Revision is the revision all pages are read at. Zero means the current
+	// one, which is then carried over by continue tokens. It is ignored when
+	// Continue is set.
+	Revision int64
+	// Predicates must all be satisfied by the returned hosts.
+	Predicates []Predicate
+}
+
+// HostPage is a page of hosts sorted by hostname.
+type HostPage struct {
+	Hosts []Host
+	// Continue is empty if there are no more hosts.
+	Continue string
+	// Revision is the revision the page was read at, or zero if the adapter
+	// does not track revisions.
+	Revision int64
+}
+
+// Operator compares a field of host with a value.
+type Operator string
+
+const (
+	OperatorEquals    Operator = "="
+	OperatorNotEquals Operator = "!="
+)
+
+// Predicate matches hosts by a single field. Fields are named after their
+// JSON representation, while fields of ExtraInfo are named as
+// 'extra_info.<key>'. Absent fields equal to an empty string.
+type Predicate struct {
+	Field    string
+	Operator Operator
+	Value    string
+}
+
+func (in Predicate) String() string {
+	return in.Field + string(in.Operator) + in.Value
+}
+
+// selectableFields are the fields of Host which could be used in predicates,
+// besides fields of ExtraInfo.
+var selectableFields = map[string]bool{
+	"guid":               true,
+	"hostname":           true,
+	"ssh_addr":           true,
+	"ssh_port":           true,
+	"ssh_user":           true,
+	"ipmi_addr":          true,
+	"ipmi_user":          true,
+	"ipmi_secret":        true,
+	"credential_profile": true,
+}
+
+// ParseSelector parses comma-separated predicates, e.g.
+// 'ssh_user=root,extra_info.department!=ops'.
+func ParseSelector(selector string) (predicates []Predicate, err error) {
+	for _, each := range strings.Split(selector, ",") {
+		each = strings.TrimSpace(each)
+		if each == "" {
+			continue
+		}
+		var predicate Predicate
+		if i := strings.Index(each, string(OperatorNotEquals)); i >= 0 {
+			predicate = Predicate{Field: each[:i], Operator: OperatorNotEquals, Value: each[i+2:]}
+		} else if i = strings.Index(each, string(OperatorEquals)); i >= 0 {
+			predicate = Predicate{Field: each[:i], Operator: OperatorEquals, Value: strings.TrimPrefix(each[i+1:], "=")}
+		} else {
+			return nil, fmt.Errorf("Invalid predicate '%s': operator is missing", each)
+		}
+		predicate.Field = strings.TrimSpace(predicate.Field)
+		predicate.Value = strings.TrimSpace(predicate.Value)
+		if !selectableFields[predicate.Field] && !(strings.HasPrefix(predicate.Field, "extra_info.") && len(predicate.Field) > len("extra_info.")) {
+			return nil, fmt.Errorf("Invalid predicate '%s': unknown field '%s'", each, predicate.Field)
+		}
+		predicates = append(predicates, predicate)
+	}
+	return predicates, nil
+}
+
+// MatchHost returns whether host satisfies all predicates.
+func MatchHost(host Host, predicates []Predicate) (bool, error) {
+	if len(predicates) == 0 {
+		return true, nil
+	}
+	fields, err := flattenHost(host)
+	if err != nil {
+		return false, err
+	}
+	for _, predicate := range predicates {
+		equals := fields[predicate.Field] == predicate.Value
+		switch predicate.Operator {
+		case OperatorEquals:
+			if !equals {
+				return false, nil
+			}
+		case OperatorNotEquals:
+			if equals {
+				return false, nil
+			}
+		default:
+			return false, fmt.Errorf("Unknown operator '%s'", predicate.Operator)
+		}
+	}
+	return true, nil
+}
+
+type continueToken struct {
+	Revision int64  `json:"rev,omitempty"`
+	Key      string `json:"key"`
+}
+
+// EncodeContinue builds an opaque continue token, where key is the hostname
+// of the last host returned and revision is the revision the listing is read
+// at.
+func EncodeContinue(revision int64, key string) string {
+	b, _ := json.Marshal(continueToken{Revision: revision, Key: key})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeContinue is the reverse of EncodeContinue.
+func DecodeContinue(token string) (revision int64, key string, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, "", ErrInvalidContinue
+	}
+	var t continueToken
+	if err = json.Unmarshal(b, &t); err != nil || t.Key == "" {
+		return 0, "", ErrInvalidContinue
+	}
+	return t.Revision, t.Key, nil
+}
+
+// PageHosts applies given options on hosts that have been loaded already,
+// which is useful for adapters that could not page natively. Revision is the
+// revision hosts were read at.
+func PageHosts(hosts []Host, opts ListOptions, revision int64) (page HostPage, err error) {
+	var after string
+	if opts.Continue != "" {
+		if _, after, err = DecodeContinue(opts.Continue); err != nil {
+			return page, err
+		}
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Hostname < hosts[j].Hostname })
+	page.Revision = revision
+	for i, host := range hosts {
+		if host.Hostname <= after {
+			continue
+		}
+		ok, err := MatchHost(host, opts.Predicates)
+		if err != nil {
+			return page, err
+		}
+		if !ok {
+			continue
+		}
+		page.Hosts = append(page.Hosts, host)
+		if opts.Limit > 0 && int64(len(page.Hosts)) >= opts.Limit {
+			if i+1 < len(hosts) {
+				page.Continue = EncodeContinue(revision, host.Hostname)
+			}
+			break
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,57 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"time"
+
+	tablewriter "github.com/olekukonko/tablewriter"
+)
+
+// HostLock is the lease-based lock of a host, which is held by an operator
+// during maintenance. ExpiresAt is when the lock expires unless its holder
+// keeps it alive, and is zero if the lock never expires while held.
+type HostLock struct {
+	Hostname   string    `json:"hostname" yaml:"hostname"`
+	Holder     string    `json:"holder" yaml:"holder"`
+	Reason     string    `json:"reason,omitempty" yaml:"reason,omitempty"`
+	AcquiredAt time.Time `json:"acquired_at" yaml:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+}
+
+type HostLockList []HostLock
+
+func (locks HostLockList) CanonicalString() string {
+	var buf bytes.Buffer
+	table := tablewriter.NewWriter(&buf)
+	table.SetHeader([]string{"Hostname", "Holder", "Reason", "Acquired At", "Expires At"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for _, lock := range locks {
+		var expiresAt string
+		if !lock.ExpiresAt.IsZero() {
+			expiresAt = lock.ExpiresAt.Local().Format(time.RFC3339)
+		}
+		table.Append([]string{
+			lock.Hostname,
+			lock.Holder,
+			lock.Reason,
+			lock.AcquiredAt.Local().Format(time.RFC3339),
+			expiresAt,
+		})
+	}
+	table.Render()
+	return buf.String()
+}
@@ -0,0 +1,94 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"time"
+
+	tablewriter "github.com/olekukonko/tablewriter"
+)
+
+const (
+	// ExtraInfoBMCType is the key of ExtraInfo which receives the BMC type of
+	// the credential profile of host, unless host has its own one.
+	ExtraInfoBMCType = "bmc_type"
+)
+
+// CredentialProfile is a BMC credential shared by hosts, which refer to it by
+// name through Host.CredentialProfile. Like hosts, Secret takes precedence
+// over Password.
+type CredentialProfile struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Type is the type of BMC, e.g. 'dell' or 'supermicro'.
+	Type     string `json:"type,omitempty" yaml:"type,omitempty"`
+	User     string `json:"user,omitempty" yaml:"user,omitempty"`
+	Password string `json:"pass,omitempty" yaml:"pass,omitempty"`
+	Secret   string `json:"secret,omitempty" yaml:"secret,omitempty"`
+	// UpdatedAt is maintained by storages whenever the profile is written.
+	UpdatedAt time.Time `json:"updated_at,omitempty" yaml:"updated_at,omitempty"`
+}
+
+// ApplyTo returns host with the IPMI credential taken from profile, where
+// fields set on host itself are kept as overrides. Password and secret are
+// overridden together.
+func (in CredentialProfile) ApplyTo(host Host) Host {
+	if host.IPMIUser == "" {
+		host.IPMIUser = in.User
+	}
+	if host.IPMIPassword == "" && host.IPMISecret == "" {
+		host.IPMIPassword = in.Password
+		host.IPMISecret = in.Secret
+	}
+	if _, ok := host.ExtraInfo[ExtraInfoBMCType]; !ok && in.Type != "" {
+		extraInfo := make(ExtendableFields, len(host.ExtraInfo)+1)
+		for k, v := range host.ExtraInfo {
+			extraInfo[k] = v
+		}
+		extraInfo[ExtraInfoBMCType] = in.Type
+		host.ExtraInfo = extraInfo
+	}
+	return host
+}
+
+type CredentialProfileList []CredentialProfile
+
+func (profiles CredentialProfileList) CanonicalString() string {
+	var buf bytes.Buffer
+	table := tablewriter.NewWriter(&buf)
+	table.SetHeader([]string{"Name", "Type", "User", "Password", "Updated At"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for _, profile := range profiles {
+		var armoredPassword string
+		if profile.Secret != "" {
+			armoredPassword = profile.Secret
+		} else if profile.Password != "" {
+			armoredPassword = "******"
+		}
+		var updatedAt string
+		if !profile.UpdatedAt.IsZero() {
+			updatedAt = profile.UpdatedAt.Local().Format(time.RFC3339)
+		}
+		table.Append([]string{
+			profile.Name,
+			profile.Type,
+			profile.User,
+			armoredPassword,
+			updatedAt,
+		})
+	}
+	table.Render()
+	return buf.String()
+}
@@ -0,0 +1,62 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy re-runs optimistic updates which lost the race against
+// concurrent updates, waiting with exponential backoff between attempts.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one, where a
+	// value less than 1 means a single attempt.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy is applied by adapters with optimistic updates, unless
+// they are configured otherwise.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 20 * time.Millisecond,
+	MaxBackoff:     time.Second,
+}
+
+// Do runs fn until it returns an error other than ErrConcurrentUpdate, all
+// attempts are used up, or ctx is done. Since fn is run again as a whole, it
+// must read the current value by itself, and so does any updater it calls.
+func (in RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	backoff := in.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err != ErrConcurrentUpdate || attempt >= in.MaxAttempts {
+			return err
+		}
+		// jitter keeps competing writers from retrying in lockstep
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		if backoff *= 2; backoff > in.MaxBackoff {
+			backoff = in.MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// HostMigration upgrades a persisted host record by a single schema version.
+// Numbers of record are decoded as json.Number.
+type HostMigration func(record map[string]interface{}) error
+
+// hostMigrations holds all changes of host layout in the order they have to
+// be applied, where the schema version of a record equals to the number of
+// migrations applied to it. Records written before schema versions were
+// introduced are at version 0. Existing migrations must never be changed,
+// append a new one instead.
+var hostMigrations = []HostMigration{
+	// 1: SSH port was mistakenly tagged as 'ssh_addr' in YAML, and could be
+	// given as a string in hand-written files.
+	func(record map[string]interface{}) error {
+		if port, ok := record["ssh_addr"].(json.Number); ok {
+			if _, exists := record["ssh_port"]; !exists {
+				record["ssh_port"] = port
+			}
+			delete(record, "ssh_addr")
+		}
+		if port, ok := record["ssh_port"].(string); ok {
+			if port == "" {
+				delete(record, "ssh_port")
+				return nil
+			}
+			if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+				return fmt.Errorf("Invalid SSH port '%s'", port)
+			}
+			record["ssh_port"] = json.Number(port)
+		}
+		return nil
+	},
+}
+
+// HostSchemaVersion is the schema version of hosts written by this build.
+var HostSchemaVersion = len(hostMigrations)
+
+// hostAlias has the same layout as Host without its methods, so that it could
+// be encoded by default.
+type hostAlias Host
+
+// MarshalJSON always stamps the current schema version on host.
+func (host Host) MarshalJSON() ([]byte, error) {
+	host.SchemaVersion = HostSchemaVersion
+	return json.Marshal(hostAlias(host))
+}
+
+// MarshalYAML always stamps the current schema version on host.
+func (host Host) MarshalYAML() (interface{}, error) {
+	host.SchemaVersion = HostSchemaVersion
+	return hostAlias(host), nil
+}
+
+// UnmarshalJSON upgrades records of older schema versions before decoding
+// them, while SchemaVersion keeps the version that the record was stored
+// with. Records of newer versions are refused.
+func (in *Host) UnmarshalJSON(data []byte) error {
+	var stored struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	if stored.SchemaVersion > HostSchemaVersion {
+		return fmt.Errorf("Host schema version %d is newer than the supported version %d", stored.SchemaVersion, HostSchemaVersion)
+	}
+	if stored.SchemaVersion < HostSchemaVersion {
+		var err error
+		if data, err = migrateHostRecord(data, stored.SchemaVersion); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(data, (*hostAlias)(in)); err != nil {
+		return err
+	}
+	in.SchemaVersion = stored.SchemaVersion
+	return nil
+}
+
+// migrateHostRecord applies migrations to data of given schema version.
+func migrateHostRecord(data []byte, version int) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var record map[string]interface{}
+	if err := decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return data, nil
+	}
+	for i := version; i < len(hostMigrations); i++ {
+		if err := hostMigrations[i](record); err != nil {
+			return nil, fmt.Errorf("Could not migrate host to schema version %d due to: %v", i+1, err)
+		}
+	}
+	return json.Marshal(record)
+}
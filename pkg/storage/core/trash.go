@@ -0,0 +1,45 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import "time"
+
+const (
+	// DefaultTrashRetention is the period a trashed host is kept before it is
+	// purged, unless the adapter is configured otherwise.
+	DefaultTrashRetention = 30 * 24 * time.Hour
+)
+
+// TrashedHost is a deleted host kept in the trash bin, which could be
+// restored until it is purged.
+type TrashedHost struct {
+	Host      Host      `json:"host" yaml:"host"`
+	DeletedAt time.Time `json:"deleted_at" yaml:"deleted_at"`
+	Reason    string    `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// Expired returns whether the trashed host should have been purged at now.
+func (in TrashedHost) Expired(retention time.Duration, now time.Time) bool {
+	return !now.Before(in.DeletedAt.Add(retention))
+}
+
+// ParseTrashRetention parses the retention period from adapter configuration,
+// an empty string means DefaultTrashRetention.
+func ParseTrashRetention(s string) (time.Duration, error) {
+	if s == "" {
+		return DefaultTrashRetention, nil
+	}
+	return time.ParseDuration(s)
+}
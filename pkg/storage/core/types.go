@@ -18,51 +18,82 @@ import (
 	"bytes"
 	"encoding/json"
 	"strconv"
+	"time"
 
 	tablewriter "github.com/olekukonko/tablewriter"
 )
 
-// Host indicates host data object
+// Host indicates host data object. IPMISecret references the IPMI password
+// kept outside of the storage, e.g. 'file:///etc/ivy/bmc/dell-default',
+// 'env:IPMI_PASS' or 'exec:/usr/local/bin/bmc-pass dell', whose command must
+// be allowed by the machine resolving it, see secret.ExecAllowlistEnv. It
+// takes precedence over IPMIPassword and is resolved only when the password
+// is actually used.
+// CredentialProfile names the shared credential of host, whose fields are
+// overridden by the IPMI credential of host itself, see CredentialProfile.
 type Host struct {
-	GUID         string           `json:"guid,omitempty" yaml:"guid,omitempty"`
-	Hostname     string           `json:"hostname,omitempty" yaml:"hostname,omitempty"`
-	SSHAddress   string           `json:"ssh_addr,omitempty" yaml:"ssh_addr,omitempty"`
-	SSHPort      uint16           `json:"ssh_port,omitempty" yaml:"ssh_addr,omitempty"`
-	SSHUser      string           `json:"ssh_user,omitempty" yaml:"ssh_user,omitempty"`
-	IPMIAddress  string           `json:"ipmi_addr,omitempty" yaml:"ipmi_addr,omitempty"`
-	IPMIUser     string           `json:"ipmi_user,omitempty" yaml:"ipmi_user,omitempty"`
-	IPMIPassword string           `json:"ipmi_pass,omitempty" yaml:"ipmi_pass,omitempty"`
-	ExtraInfo    ExtendableFields `json:"extra_info,omitempty" yaml:"extra_info,omitempty"`
+	GUID              string           `json:"guid,omitempty" yaml:"guid,omitempty"`
+	Hostname          string           `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+	SSHAddress        string           `json:"ssh_addr,omitempty" yaml:"ssh_addr,omitempty"`
+	SSHPort           uint16           `json:"ssh_port,omitempty" yaml:"ssh_port,omitempty"`
+	SSHUser           string           `json:"ssh_user,omitempty" yaml:"ssh_user,omitempty"`
+	IPMIAddress       string           `json:"ipmi_addr,omitempty" yaml:"ipmi_addr,omitempty"`
+	IPMIUser          string           `json:"ipmi_user,omitempty" yaml:"ipmi_user,omitempty"`
+	IPMIPassword      string           `json:"ipmi_pass,omitempty" yaml:"ipmi_pass,omitempty"`
+	IPMISecret        string           `json:"ipmi_secret,omitempty" yaml:"ipmi_secret,omitempty"`
+	CredentialProfile string           `json:"credential_profile,omitempty" yaml:"credential_profile,omitempty"`
+	ExtraInfo         ExtendableFields `json:"extra_info,omitempty" yaml:"extra_info,omitempty"`
+	// UpdatedAt is maintained by storages whenever the host is written.
+	UpdatedAt time.Time `json:"updated_at,omitempty" yaml:"updated_at,omitempty"`
+	// SchemaVersion is the schema version that the host was stored with,
+	// which is always written as HostSchemaVersion, see HostMigration.
+	SchemaVersion int `json:"schema_version,omitempty" yaml:"schema_version,omitempty"`
+	// Revision is the revision of storage at which the host was last changed,
+	// as of reading it. It is never stored, and is zero if the adapter does
+	// not track revisions.
+	Revision int64 `json:"-" yaml:"-"`
 }
 
 func (host Host) CanonicalString() string {
 	var buf bytes.Buffer
 	table := tablewriter.NewWriter(&buf)
-	table.SetHeader([]string{"GUID", "Hostname", "SSH Address", "SSH Port", "SSH User", "IPMI Address", "IPMI User", "IPMI Password", "Extra Info"})
+	table.SetHeader([]string{"GUID", "Hostname", "Revision", "SSH Address", "SSH Port", "SSH User", "IPMI Address", "IPMI User", "IPMI Password", "Credential Profile", "Extra Info"})
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
 	str, err := json.Marshal(host.ExtraInfo)
 	if err != nil {
 		str = []byte("<N/A>")
 	}
 	var armoredPassword string
-	if host.IPMIPassword != "" {
+	if host.IPMISecret != "" {
+		armoredPassword = host.IPMISecret
+	} else if host.IPMIPassword != "" {
 		armoredPassword = "******"
 	}
 	table.Append([]string{
 		host.GUID,
 		host.Hostname,
+		formatRevision(host.Revision),
 		host.SSHAddress,
 		strconv.Itoa(int(host.SSHPort)),
 		host.SSHUser,
 		host.IPMIAddress,
 		host.IPMIUser,
 		armoredPassword,
+		host.CredentialProfile,
 		string(str),
 	})
 	table.Render()
 	return buf.String()
 }
 
+// formatRevision leaves the revision blank if it is not tracked.
+func formatRevision(revision int64) string {
+	if revision == 0 {
+		return ""
+	}
+	return strconv.FormatInt(revision, 10)
+}
+
 func NewHost() *Host {
 	return &Host{
 		ExtraInfo: make(ExtendableFields),
@@ -77,22 +108,28 @@ type HostList []Host
 func (hosts HostList) CanonicalString() string {
 	var buf bytes.Buffer
 	table := tablewriter.NewWriter(&buf)
-	table.SetHeader([]string{"GUID", "Hostname", "SSH Address", "SSH Port", "SSH User", "IPMI Address", "IPMI User", "IPMI Password", "Extra Info"})
+	table.SetHeader([]string{"GUID", "Hostname", "Revision", "SSH Address", "SSH Port", "SSH User", "IPMI Address", "IPMI User", "IPMI Password", "Credential Profile", "Extra Info"})
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
 	for _, host := range hosts {
 		str, err := json.Marshal(host.ExtraInfo)
 		if err != nil {
 			str = []byte("<N/A>")
 		}
+		armoredPassword := "******"
+		if host.IPMISecret != "" {
+			armoredPassword = host.IPMISecret
+		}
 		table.Append([]string{
 			host.GUID,
 			host.Hostname,
+			formatRevision(host.Revision),
 			host.SSHAddress,
 			strconv.Itoa(int(host.SSHPort)),
 			host.SSHUser,
 			host.IPMIAddress,
 			host.IPMIUser,
-			"******",
+			armoredPassword,
+			host.CredentialProfile,
 			string(str),
 		})
 	}
@@ -0,0 +1,80 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Config specifies where master keys are loaded from. Exactly one of KeyFile
+// and KeyEnv is required, whose content is a base64 encoded key of KeySize
+// bytes.
+type Config struct {
+	// KeyFile is the path of a file holding the master key.
+	KeyFile string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+	// KeyEnv is the name of an environment variable holding the master key.
+	KeyEnv string `json:"key_env,omitempty" yaml:"key_env,omitempty"`
+	// PreviousKeyFiles hold keys which were replaced by a rotation. They are
+	// only used for decryption, e.g. of hosts trashed before the rotation.
+	PreviousKeyFiles []string `json:"previous_key_files,omitempty" yaml:"previous_key_files,omitempty"`
+}
+
+// Keyring loads all keys of config.
+func (in *Config) Keyring() (*Keyring, error) {
+	primary, err := LoadKey(in.KeyFile, in.KeyEnv)
+	if err != nil {
+		return nil, err
+	}
+	var previous []*Key
+	for _, each := range in.PreviousKeyFiles {
+		key, err := LoadKey(each, "")
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+	return NewKeyring(primary, previous...), nil
+}
+
+// LoadKey reads a master key from either a file or an environment variable.
+func LoadKey(file, env string) (*Key, error) {
+	switch {
+	case file != "" && env != "":
+		return nil, fmt.Errorf("Only one of encryption key file and environment variable could be specified")
+	case file != "":
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read encryption key file '%s': %v", file, err)
+		}
+		key, err := ParseKey(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid encryption key in file '%s': %v", file, err)
+		}
+		return key, nil
+	case env != "":
+		encoded, ok := os.LookupEnv(env)
+		if !ok || encoded == "" {
+			return nil, fmt.Errorf("Encryption key is missing: environment variable '%s' is not set", env)
+		}
+		key, err := ParseKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid encryption key in environment variable '%s': %v", env, err)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("Encryption key is missing: either 'key_file' or 'key_env' must be specified")
+}
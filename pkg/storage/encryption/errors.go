@@ -0,0 +1,31 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import "errors"
+
+var (
+	// ErrKeyMissing is the error returned if an encrypted value is read while
+	// no encryption key was configured.
+	ErrKeyMissing = errors.New("Value is encrypted but no encryption key was configured, please specify 'encryption' in storage configuration")
+
+	// ErrMalformedValue is the error returned if an encrypted value is corrupted.
+	ErrMalformedValue = errors.New("Malformed encrypted value")
+
+	// ErrDecryptionFailed is the error returned if a value could not be
+	// authenticated with the key, which means either the key is wrong or the
+	// value was tampered with.
+	ErrDecryptionFailed = errors.New("Could not decrypt value, either the key is wrong or the value was tampered with")
+)
@@ -0,0 +1,205 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// KeySize is the size of master keys, which are AES-256 keys.
+	KeySize = 32
+
+	// valuePrefix marks an encrypted value, which is formatted as
+	// 'enc:v1:<key id>:<wrapped data key>:<ciphertext>'.
+	valuePrefix = "enc:v1:"
+)
+
+// Key is a master key, which only wraps per-value data keys.
+type Key struct {
+	// ID is derived from the key itself, so that the key which encrypted a
+	// value could be told without revealing it.
+	ID   string
+	aead cipher.AEAD
+}
+
+// NewKey creates a master key from KeySize random bytes.
+func NewKey(raw []byte) (*Key, error) {
+	if len(raw) != KeySize {
+		return nil, fmt.Errorf("Encryption key must be %d bytes, got %d bytes", KeySize, len(raw))
+	}
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(raw)
+	return &Key{ID: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// ParseKey creates a master key from its base64 encoding, e.g. the output of
+// 'head -c 32 /dev/urandom | base64'.
+func ParseKey(encoded string) (*Key, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("Encryption key is not encoded in base64: %v", err)
+	}
+	return NewKey(raw)
+}
+
+// GenerateKey returns a random master key in base64 encoding.
+func GenerateKey() (string, error) {
+	raw := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// Keyring encrypts with its primary key, while values encrypted by any of its
+// keys could be decrypted.
+type Keyring struct {
+	primary *Key
+	keys    map[string]*Key
+}
+
+func NewKeyring(primary *Key, previous ...*Key) *Keyring {
+	keyring := &Keyring{
+		primary: primary,
+		keys:    map[string]*Key{primary.ID: primary},
+	}
+	for _, each := range previous {
+		keyring.keys[each.ID] = each
+	}
+	return keyring
+}
+
+// Primary returns the key used for encryption.
+func (in *Keyring) Primary() *Key {
+	return in.primary
+}
+
+// IsEncrypted returns whether value was produced by Keyring.Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, valuePrefix)
+}
+
+// KeyIDOf returns ID of the key which encrypted value, or an empty string if
+// value is not encrypted.
+func KeyIDOf(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, valuePrefix), ":", 2)
+	return parts[0]
+}
+
+// Encrypt seals plaintext with a random data key, which is wrapped by the
+// primary key and stored beside the ciphertext.
+func (in *Keyring) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	// the key ID is authenticated, so that a wrapped data key could not be
+	// passed off as one of another key.
+	wrapped, err := seal(in.primary.aead, dataKey, []byte(in.primary.ID))
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataAEAD, []byte(plaintext), nil)
+	if err != nil {
+		return "", err
+	}
+	return valuePrefix + in.primary.ID + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt reverses Encrypt. Values which are not encrypted are returned as
+// is, so that existing plain values stay readable until they are rotated.
+func (in *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, valuePrefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformedValue
+	}
+	key, ok := in.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("Value was encrypted by key '%s', which is not provided", parts[0])
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformedValue
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformedValue
+	}
+	dataKey, err := open(key.aead, wrapped, []byte(key.ID))
+	if err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataAEAD, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal prepends a random nonce to the ciphertext.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformedValue
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,151 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func newTestKey(t *testing.T) *Key {
+	encoded, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseKey(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestKeyringRoundTrip(t *testing.T) {
+	key := newTestKey(t)
+	keyring := NewKeyring(key)
+	encrypted, err := keyring.Encrypt("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "s3cret") {
+		t.Fatalf("Expected encrypted value, got '%s'", encrypted)
+	}
+	if id := KeyIDOf(encrypted); id != key.ID {
+		t.Errorf("Expected value encrypted by key '%s', got '%s'", key.ID, id)
+	}
+	again, err := keyring.Encrypt("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == encrypted {
+		t.Error("Expected a random data key for each value")
+	}
+	decrypted, err := keyring.Decrypt(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted != "s3cret" {
+		t.Errorf("Expected 's3cret', got '%s'", decrypted)
+	}
+	plain, err := keyring.Decrypt("plain")
+	if err != nil || plain != "plain" {
+		t.Errorf("Expected plain value to be returned as is, got '%s' with error: %v", plain, err)
+	}
+}
+
+func TestKeyringDecryptsWithPreviousKey(t *testing.T) {
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+	encrypted, err := NewKeyring(oldKey).Encrypt("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := NewKeyring(newKey, oldKey)
+	decrypted, err := keyring.Decrypt(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted != "s3cret" {
+		t.Errorf("Expected 's3cret', got '%s'", decrypted)
+	}
+	reencrypted, err := keyring.Encrypt(decrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := KeyIDOf(reencrypted); id != newKey.ID {
+		t.Errorf("Expected value encrypted by primary key '%s', got '%s'", newKey.ID, id)
+	}
+	if _, err = NewKeyring(newKey).Decrypt(encrypted); err == nil {
+		t.Error("Expected decryption to fail without the key which encrypted the value")
+	}
+}
+
+func TestKeyringDetectsTampering(t *testing.T) {
+	key := newTestKey(t)
+	keyring := NewKeyring(key)
+	encrypted, err := keyring.Encrypt("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(strings.TrimPrefix(encrypted, valuePrefix), ":")
+	flip := func(encoded string) string {
+		raw, err := base64.RawStdEncoding.DecodeString(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw[len(raw)-1] ^= 0x01
+		return base64.RawStdEncoding.EncodeToString(raw)
+	}
+	otherWrapped, err := NewKeyring(newTestKey(t)).Encrypt("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]struct {
+		value string
+		err   error
+	}{
+		"ciphertext": {valuePrefix + parts[0] + ":" + parts[1] + ":" + flip(parts[2]), ErrDecryptionFailed},
+		"data key":   {valuePrefix + parts[0] + ":" + flip(parts[1]) + ":" + parts[2], ErrDecryptionFailed},
+		// a data key wrapped by another key is passed off as one of key
+		"foreign key": {valuePrefix + key.ID + ":" + strings.SplitN(otherWrapped, ":", 5)[3] + ":" + parts[2], ErrDecryptionFailed},
+		"truncated":   {valuePrefix + parts[0] + ":" + parts[1], ErrMalformedValue},
+		"encoding":    {valuePrefix + parts[0] + ":" + parts[1] + ":!" + parts[2], ErrMalformedValue},
+	}
+	for name, c := range cases {
+		if _, err := keyring.Decrypt(c.value); err != c.err {
+			t.Errorf("Expected error '%v' for tampered %s, got: %v", c.err, name, err)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	short := base64.StdEncoding.EncodeToString(make([]byte, KeySize-1))
+	for _, encoded := range []string{"", "not base64!", short} {
+		if _, err := ParseKey(encoded); err == nil {
+			t.Errorf("Expected key '%s' to be rejected", encoded)
+		}
+	}
+	raw := make([]byte, KeySize)
+	first, err := ParseKey(base64.StdEncoding.EncodeToString(raw) + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewKey(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != second.ID {
+		t.Errorf("Expected the same key ID, got '%s' and '%s'", first.ID, second.ID)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import (
+	"context"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+func (in *Storage) CreateCredentialProfile(ctx context.Context, profile core.CredentialProfile) error {
+	var err error
+	if profile.Password, err = in.seal(profile.Password); err != nil {
+		return err
+	}
+	return in.Storage.CreateCredentialProfile(ctx, profile)
+}
+
+func (in *Storage) GetCredentialProfile(ctx context.Context, name string) (core.CredentialProfile, error) {
+	profile, err := in.Storage.GetCredentialProfile(ctx, name)
+	if err != nil {
+		return profile, err
+	}
+	profile.Password, err = in.unseal(profile.Password)
+	return profile, err
+}
+
+func (in *Storage) ListCredentialProfiles(ctx context.Context) ([]core.CredentialProfile, error) {
+	profiles, err := in.Storage.ListCredentialProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range profiles {
+		if profiles[i].Password, err = in.unseal(profiles[i].Password); err != nil {
+			return nil, err
+		}
+	}
+	return profiles, nil
+}
+
+// UpdateCredentialProfile keeps the ciphertext of an unchanged password, the
+// same as UpdateHost.
+func (in *Storage) UpdateCredentialProfile(ctx context.Context, name string, updater func(profile core.CredentialProfile) (core.CredentialProfile, error)) error {
+	return in.Storage.UpdateCredentialProfile(ctx, name, func(current core.CredentialProfile) (core.CredentialProfile, error) {
+		decrypted, err := in.unseal(current.Password)
+		if err != nil {
+			return current, err
+		}
+		plain := current
+		plain.Password = decrypted
+		updated, err := updater(plain)
+		if err != nil {
+			return updated, err
+		}
+		if updated.Password == decrypted && IsEncrypted(current.Password) {
+			updated.Password = current.Password
+			return updated, nil
+		}
+		updated.Password, err = in.seal(updated.Password)
+		return updated, err
+	})
+}
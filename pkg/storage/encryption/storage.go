@@ -0,0 +1,334 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import (
+	"context"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	rotateBatchSize = 500
+)
+
+// Storage encrypts sensitive fields of hosts and credential profiles before
+// they reach the wrapped storage, and decrypts them transparently on reads.
+// Only passwords are sensitive for now, while secret references are not.
+type Storage struct {
+	core.Storage
+	keyring *Keyring
+}
+
+// Wrap decorates storage with keyring. A nil keyring leaves values as they
+// are, while reads of encrypted values fail with ErrKeyMissing.
+func Wrap(storage core.Storage, keyring *Keyring) *Storage {
+	return &Storage{
+		Storage: storage,
+		keyring: keyring,
+	}
+}
+
+// Keyring returns the keys in use, or nil if encryption is not configured.
+func (in *Storage) Keyring() *Keyring {
+	return in.keyring
+}
+
+// seal encrypts value unless it is empty or encrypted already.
+func (in *Storage) seal(value string) (string, error) {
+	if in.keyring == nil || value == "" || IsEncrypted(value) {
+		return value, nil
+	}
+	return in.keyring.Encrypt(value)
+}
+
+// unseal decrypts value if it is encrypted.
+func (in *Storage) unseal(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if in.keyring == nil {
+		return value, ErrKeyMissing
+	}
+	return in.keyring.Decrypt(value)
+}
+
+func (in *Storage) encrypt(host core.Host) (core.Host, error) {
+	encrypted, err := in.seal(host.IPMIPassword)
+	if err != nil {
+		return host, err
+	}
+	host.IPMIPassword = encrypted
+	return host, nil
+}
+
+func (in *Storage) decrypt(host core.Host) (core.Host, error) {
+	decrypted, err := in.unseal(host.IPMIPassword)
+	if err != nil {
+		return host, err
+	}
+	host.IPMIPassword = decrypted
+	return host, nil
+}
+
+func (in *Storage) decryptAll(hosts []core.Host) ([]core.Host, error) {
+	for i := range hosts {
+		var err error
+		if hosts[i], err = in.decrypt(hosts[i]); err != nil {
+			return nil, err
+		}
+	}
+	return hosts, nil
+}
+
+func (in *Storage) CreateHost(ctx context.Context, host core.Host) error {
+	host, err := in.encrypt(host)
+	if err != nil {
+		return err
+	}
+	return in.Storage.CreateHost(ctx, host)
+}
+
+func (in *Storage) GetHost(ctx context.Context, id string) (core.Host, error) {
+	host, err := in.Storage.GetHost(ctx, id)
+	if err != nil {
+		return host, err
+	}
+	return in.decrypt(host)
+}
+
+func (in *Storage) GetHostBy(ctx context.Context, field core.IndexField, value string) (core.Host, error) {
+	host, err := in.Storage.GetHostBy(ctx, field, value)
+	if err != nil {
+		return host, err
+	}
+	return in.decrypt(host)
+}
+
+func (in *Storage) ListHost(ctx context.Context) ([]core.Host, error) {
+	hosts, err := in.Storage.ListHost(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return in.decryptAll(hosts)
+}
+
+func (in *Storage) ListHosts(ctx context.Context, opts core.ListOptions) (core.HostPage, error) {
+	page, err := in.Storage.ListHosts(ctx, opts)
+	if err != nil {
+		return page, err
+	}
+	page.Hosts, err = in.decryptAll(page.Hosts)
+	return page, err
+}
+
+// UpdateHost hands the decrypted host over to updater. An unchanged password
+// keeps its ciphertext, so that history does not report a change on every
+// update.
+func (in *Storage) UpdateHost(ctx context.Context, id string, updater func(host core.Host) (core.Host, error)) error {
+	return in.Storage.UpdateHost(ctx, id, in.wrapUpdater(updater))
+}
+
+// wrapUpdater returns an updater of the wrapped storage, which hands the
+// decrypted host over to updater.
+func (in *Storage) wrapUpdater(updater func(host core.Host) (core.Host, error)) func(host core.Host) (core.Host, error) {
+	return func(current core.Host) (core.Host, error) {
+		decrypted, err := in.decrypt(current)
+		if err != nil {
+			return current, err
+		}
+		updated, err := updater(decrypted)
+		if err != nil {
+			return updated, err
+		}
+		if updated.IPMIPassword == decrypted.IPMIPassword && IsEncrypted(current.IPMIPassword) {
+			updated.IPMIPassword = current.IPMIPassword
+			return updated, nil
+		}
+		return in.encrypt(updated)
+	}
+}
+
+// ApplyBatch encrypts hosts of the batch the same as CreateHost and
+// UpdateHost do.
+func (in *Storage) ApplyBatch(ctx context.Context, ops []core.BatchOp) error {
+	encrypted := make([]core.BatchOp, len(ops))
+	for i, op := range ops {
+		var err error
+		if op.Host, err = in.encrypt(op.Host); err != nil {
+			return err
+		}
+		if op.Update != nil {
+			op.Update = in.wrapUpdater(op.Update)
+		}
+		encrypted[i] = op
+	}
+	return in.Storage.ApplyBatch(ctx, encrypted)
+}
+
+func (in *Storage) ListTrash(ctx context.Context) ([]core.TrashedHost, error) {
+	trashed, err := in.Storage.ListTrash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range trashed {
+		if trashed[i].Host, err = in.decrypt(trashed[i].Host); err != nil {
+			return nil, err
+		}
+	}
+	return trashed, nil
+}
+
+// WatchHosts decrypts hosts of events. Once a host could not be decrypted,
+// an event carrying the error is sent and the watch is stopped.
+func (in *Storage) WatchHosts(ctx context.Context, fromRevision int64) (<-chan core.HostEvent, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	events, err := in.Storage.WatchHosts(ctx, fromRevision)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	out := make(chan core.HostEvent)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for event := range events {
+			if event.Err == nil {
+				if event.Host, event.Err = in.decrypt(event.Host); event.Err != nil {
+					event.Host = core.Host{}
+				}
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+			}
+			if event.Err != nil || ctx.Err() != nil {
+				break
+			}
+		}
+		// drain events, so that the wrapped watcher could quit after cancel
+		cancel()
+		for range events {
+		}
+	}()
+	return out, nil
+}
+
+func (in *Storage) HostHistory(ctx context.Context, id string) ([]core.HostRevision, error) {
+	history, err := in.Storage.HostHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range history {
+		if history[i].Host, err = in.decrypt(history[i].Host); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
+
+func (in *Storage) ListHostAt(ctx context.Context, revision int64) ([]core.Host, error) {
+	hosts, err := in.Storage.ListHostAt(ctx, revision)
+	if err != nil {
+		return nil, err
+	}
+	return in.decryptAll(hosts)
+}
+
+// RotateKey re-encrypts every host and credential profile with newKey, which
+// also encrypts values that are still plain. Values are decrypted with the
+// current keyring, thus newKey could be the current primary key as well. It
+// returns the number of entities rewritten.
+//
+// Hosts in the trash bin could not be rewritten, so keep the replaced key in
+// 'previous_key_files' until they are purged.
+func (in *Storage) RotateKey(ctx context.Context, newKey *Key) (rotated int, err error) {
+	var previous []*Key
+	if in.keyring != nil {
+		for _, each := range in.keyring.keys {
+			previous = append(previous, each)
+		}
+	}
+	target := NewKeyring(newKey, previous...)
+	reencrypt := func(value string) (string, error) {
+		if value == "" || KeyIDOf(value) == newKey.ID {
+			return value, nil
+		}
+		plaintext, err := target.Decrypt(value)
+		if err != nil {
+			return value, err
+		}
+		return target.Encrypt(plaintext)
+	}
+	opts := core.ListOptions{Limit: rotateBatchSize}
+	for {
+		// pages are read from the wrapped storage, so that nothing is
+		// decrypted in vain.
+		page, err := in.Storage.ListHosts(ctx, opts)
+		if err != nil {
+			return rotated, err
+		}
+		for _, host := range page.Hosts {
+			if host.IPMIPassword == "" || KeyIDOf(host.IPMIPassword) == newKey.ID {
+				continue
+			}
+			err = in.Storage.UpdateHost(ctx, host.Hostname, func(current core.Host) (core.Host, error) {
+				// UpdateHost creates absent hosts, which must not happen
+				// to a host removed in the meantime.
+				if current.Hostname == "" {
+					return current, core.ErrResourceNotFound
+				}
+				var err error
+				current.IPMIPassword, err = reencrypt(current.IPMIPassword)
+				return current, err
+			})
+			if err == core.ErrResourceNotFound {
+				continue
+			} else if err != nil {
+				return rotated, err
+			}
+			rotated++
+		}
+		if page.Continue == "" {
+			break
+		}
+		opts.Continue = page.Continue
+	}
+	profiles, err := in.Storage.ListCredentialProfiles(ctx)
+	if err != nil {
+		return rotated, err
+	}
+	for _, profile := range profiles {
+		if profile.Password == "" || KeyIDOf(profile.Password) == newKey.ID {
+			continue
+		}
+		err = in.Storage.UpdateCredentialProfile(ctx, profile.Name, func(current core.CredentialProfile) (core.CredentialProfile, error) {
+			if current.Name == "" {
+				return current, core.ErrResourceNotFound
+			}
+			var err error
+			current.Password, err = reencrypt(current.Password)
+			return current, err
+		})
+		if err == core.ErrResourceNotFound {
+			continue
+		} else if err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+	in.keyring = target
+	return rotated, nil
+}
@@ -0,0 +1,240 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import (
+	"context"
+	"testing"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	memory "github.com/universonic/ivy-utils/pkg/storage/memory"
+	zap "go.uber.org/zap"
+)
+
+func openMemory(t *testing.T) core.Storage {
+	storage, err := memory.New().Open(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+func createHost(t *testing.T, storage core.Storage, hostname, password string) {
+	host := core.NewHost()
+	host.Hostname = hostname
+	host.IPMIPassword = password
+	if err := storage.CreateHost(context.Background(), *host); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStorageEncryptsPasswords(t *testing.T) {
+	ctx := context.Background()
+	raw := openMemory(t)
+	defer raw.Close()
+	key := newTestKey(t)
+	storage := Wrap(raw, NewKeyring(key))
+	createHost(t, storage, "node-01", "s3cret")
+
+	stored, err := raw.GetHost(ctx, "node-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KeyIDOf(stored.IPMIPassword) != key.ID {
+		t.Fatalf("Expected password encrypted by key '%s', got '%s'", key.ID, stored.IPMIPassword)
+	}
+	host, err := storage.GetHost(ctx, "node-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host.IPMIPassword != "s3cret" {
+		t.Errorf("Expected 's3cret', got '%s'", host.IPMIPassword)
+	}
+
+	// hosts written before encryption was configured stay readable
+	createHost(t, raw, "node-02", "plain")
+	hosts, err := storage.ListHost(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 || hosts[0].IPMIPassword != "s3cret" || hosts[1].IPMIPassword != "plain" {
+		t.Errorf("Expected decrypted and plain passwords, got: %+v", hosts)
+	}
+
+	if err = storage.CreateCredentialProfile(ctx, core.CredentialProfile{Name: "dell", User: "root", Password: "calvin"}); err != nil {
+		t.Fatal(err)
+	}
+	storedProfile, err := raw.GetCredentialProfile(ctx, "dell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(storedProfile.Password) {
+		t.Fatalf("Expected encrypted profile password, got '%s'", storedProfile.Password)
+	}
+	profile, err := storage.GetCredentialProfile(ctx, "dell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Password != "calvin" {
+		t.Errorf("Expected 'calvin', got '%s'", profile.Password)
+	}
+}
+
+func TestStorageWithoutKey(t *testing.T) {
+	ctx := context.Background()
+	raw := openMemory(t)
+	defer raw.Close()
+	createHost(t, Wrap(raw, NewKeyring(newTestKey(t))), "node-01", "s3cret")
+
+	storage := Wrap(raw, nil)
+	if _, err := storage.GetHost(ctx, "node-01"); err != ErrKeyMissing {
+		t.Errorf("Expected ErrKeyMissing on reading host, got: %v", err)
+	}
+	if _, err := storage.ListHost(ctx); err != ErrKeyMissing {
+		t.Errorf("Expected ErrKeyMissing on listing hosts, got: %v", err)
+	}
+	err := storage.UpdateHost(ctx, "node-01", func(host core.Host) (core.Host, error) {
+		host.SSHPort = 2222
+		return host, nil
+	})
+	if err != ErrKeyMissing {
+		t.Errorf("Expected ErrKeyMissing on updating host, got: %v", err)
+	}
+	// plain values are written as they are
+	createHost(t, storage, "node-02", "plain")
+	stored, err := raw.GetHost(ctx, "node-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.IPMIPassword != "plain" {
+		t.Errorf("Expected plain password, got '%s'", stored.IPMIPassword)
+	}
+}
+
+func TestUpdateHostKeepsCiphertext(t *testing.T) {
+	ctx := context.Background()
+	raw := openMemory(t)
+	defer raw.Close()
+	storage := Wrap(raw, NewKeyring(newTestKey(t)))
+	createHost(t, storage, "node-01", "s3cret")
+	before, err := raw.GetHost(ctx, "node-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = storage.UpdateHost(ctx, "node-01", func(host core.Host) (core.Host, error) {
+		if host.IPMIPassword != "s3cret" {
+			t.Errorf("Expected updater to be handed 's3cret', got '%s'", host.IPMIPassword)
+		}
+		host.SSHPort = 2222
+		return host, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := raw.GetHost(ctx, "node-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.IPMIPassword != before.IPMIPassword {
+		t.Errorf("Expected unchanged password to keep its ciphertext, got '%s' instead of '%s'", after.IPMIPassword, before.IPMIPassword)
+	}
+
+	err = storage.ApplyBatch(ctx, []core.BatchOp{{
+		Action: core.BatchUpdate,
+		Host:   core.Host{Hostname: "node-01"},
+		Update: func(host core.Host) (core.Host, error) {
+			host.IPMIPassword = "changed"
+			return host, nil
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := raw.GetHost(ctx, "node-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(changed.IPMIPassword) || changed.IPMIPassword == before.IPMIPassword {
+		t.Errorf("Expected changed password to be encrypted anew, got '%s'", changed.IPMIPassword)
+	}
+	host, err := storage.GetHost(ctx, "node-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host.IPMIPassword != "changed" {
+		t.Errorf("Expected 'changed', got '%s'", host.IPMIPassword)
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	ctx := context.Background()
+	raw := openMemory(t)
+	defer raw.Close()
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+	storage := Wrap(raw, NewKeyring(oldKey))
+	createHost(t, storage, "node-01", "s3cret")
+	createHost(t, raw, "node-02", "plain")
+	createHost(t, storage, "node-03", "")
+	if err := storage.CreateCredentialProfile(ctx, core.CredentialProfile{Name: "dell", Password: "calvin"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := storage.RotateKey(ctx, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated != 3 {
+		t.Errorf("Expected 3 entities rewritten, got %d", rotated)
+	}
+	if primary := storage.Keyring().Primary(); primary.ID != newKey.ID {
+		t.Errorf("Expected primary key '%s' after rotation, got '%s'", newKey.ID, primary.ID)
+	}
+	hosts, err := raw.ListHost(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range hosts {
+		if host.IPMIPassword != "" && KeyIDOf(host.IPMIPassword) != newKey.ID {
+			t.Errorf("Expected password of host '%s' encrypted by key '%s', got '%s'", host.Hostname, newKey.ID, host.IPMIPassword)
+		}
+	}
+
+	// the replaced key is no longer needed
+	hosts, err = Wrap(raw, NewKeyring(newKey)).ListHost(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"s3cret", "plain", ""} {
+		if hosts[i].IPMIPassword != want {
+			t.Errorf("Expected password '%s' of host '%s', got '%s'", want, hosts[i].Hostname, hosts[i].IPMIPassword)
+		}
+	}
+	profile, err := Wrap(raw, NewKeyring(newKey)).GetCredentialProfile(ctx, "dell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Password != "calvin" {
+		t.Errorf("Expected 'calvin', got '%s'", profile.Password)
+	}
+
+	// rotating again rewrites nothing
+	if rotated, err = storage.RotateKey(ctx, newKey); err != nil {
+		t.Fatal(err)
+	}
+	if rotated != 0 {
+		t.Errorf("Expected nothing rewritten, got %d", rotated)
+	}
+}
@@ -0,0 +1,136 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	clientv3 "github.com/coreos/etcd/clientv3"
+	rpctypes "github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// ApplyBatch reads all hosts of the batch at the same revision, plans the
+// batch, and commits it within a single transaction which compares the
+// revisions of those hosts. The size of a batch is limited by
+// '--max-txn-ops' of etcd, as each host takes an operation for itself and
+// one for each changed index entry.
+func (c *conn) ApplyBatch(ctx context.Context, ops []core.BatchOp) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	defer func() {
+		defer c.logger.Sync()
+		if err == rpctypes.ErrTooManyOps {
+			err = fmt.Errorf("Batch of %d operations does not fit into a single transaction, please split it: %v", len(ops), err)
+		}
+		if err != nil {
+			c.logger.Errorf("Error occurred during applying batch of %d operations due to: %v", len(ops), err)
+		} else {
+			c.logger.Debugf("Applied batch of %d operations", len(ops))
+		}
+	}()
+	gets := make([]clientv3.Op, len(ops))
+	for i, op := range ops {
+		gets[i] = clientv3.OpGet(canonicalID(hostPrefix, op.Host.Hostname))
+	}
+	var snapshot *clientv3.TxnResponse
+	snapshot, err = c.db.Txn(ctx).Then(gets...).Commit()
+	if err != nil {
+		return err
+	}
+	values := make(map[string][]byte)
+	modRevs := make(map[string]int64)
+	for i, op := range ops {
+		if kvs := snapshot.Responses[i].GetResponseRange().Kvs; len(kvs) > 0 {
+			values[op.Host.Hostname] = kvs[0].Value
+			modRevs[op.Host.Hostname] = kvs[0].ModRevision
+		}
+	}
+	current := func(hostname string) (*core.Host, error) {
+		b, ok := values[hostname]
+		if !ok {
+			return nil, nil
+		}
+		host := &core.Host{Revision: modRevs[hostname]}
+		return host, json.Unmarshal(b, host)
+	}
+	// unique indexes are guarded by comparisons of the transaction instead
+	var changes []core.BatchChange
+	changes, err = core.PlanBatch(ops, current, nil)
+	if err != nil {
+		return err
+	}
+	var (
+		cmps           []clientv3.Cmp
+		writes, checks []clientv3.Op
+		removed, added []core.IndexEntry
+		claims         = make(map[string]int)
+	)
+	for _, change := range changes {
+		hostname := ops[change.Index].Host.Hostname
+		key := canonicalID(hostPrefix, hostname)
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(key), "=", modRevs[hostname]))
+		checks = append(checks, clientv3.OpGet(key, clientv3.WithKeysOnly()))
+		if change.Updated == nil {
+			writes = append(writes, clientv3.OpDelete(key))
+		} else {
+			var b []byte
+			if b, err = json.Marshal(change.Updated); err != nil {
+				return err
+			}
+			writes = append(writes, clientv3.OpPut(key, string(b)))
+		}
+		r, a := core.DiffIndexEntries(change.Current, change.Updated)
+		removed = append(removed, r...)
+		added = append(added, a...)
+		for _, each := range a {
+			claims[indexKey(each)] = change.Index
+		}
+	}
+	idx := indexChanges(removed, added)
+	var res *clientv3.TxnResponse
+	res, err = c.db.Txn(ctx).
+		If(append(cmps, idx.cmps...)...).
+		Then(append(writes, idx.ops...)...).
+		Else(append(checks, idx.checkOps()...)...).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if res.Succeeded {
+		return nil
+	}
+	failures := new(core.BatchError)
+	for i, change := range changes {
+		hostname := ops[change.Index].Host.Hostname
+		var modRev int64
+		if kvs := res.Responses[i].GetResponseRange().Kvs; len(kvs) > 0 {
+			modRev = kvs[0].ModRevision
+		}
+		switch {
+		case modRev == modRevs[hostname]:
+			continue
+		case change.Current == nil:
+			err = core.ErrResourceAlreadyExists
+		case modRev == 0:
+			err = core.ErrResourceNotFound
+		default:
+			err = core.ErrConcurrentUpdate
+		}
+		failures.Failures = append(failures.Failures, core.BatchFailure{Index: change.Index, Hostname: hostname, Err: err})
+	}
+	for i, key := range idx.uniqueKeys {
+		if res.Responses[len(changes)+i].GetResponseRange().Count > 0 {
+			index := claims[key]
+			failures.Failures = append(failures.Failures, core.BatchFailure{Index: index, Hostname: ops[index].Host.Hostname, Err: core.ErrIndexConflict})
+		}
+	}
+	if len(failures.Failures) == 0 {
+		return core.ErrConcurrentUpdate
+	}
+	sort.Slice(failures.Failures, func(i, j int) bool {
+		return failures.Failures[i].Index < failures.Failures[j].Index
+	})
+	return failures
+}
@@ -15,7 +15,8 @@
 package etcd
 
 import (
-	"path/filepath"
+	"context"
+	"fmt"
 	"time"
 
 	clientv3 "github.com/coreos/etcd/clientv3"
@@ -26,7 +27,11 @@ import (
 )
 
 const (
-	defaultDialTimeout = 2 * time.Second
+	defaultDialTimeout = 3 * time.Second
+
+	// defaultOperationTimeout will be applied to each storage's operation,
+	// unless it is configured otherwise.
+	defaultOperationTimeout = 5 * time.Second
 )
 
 type EtcdSSLOptions struct {
@@ -37,18 +42,112 @@ type EtcdSSLOptions struct {
 	SSLCert    string `json:"cert,omitempty" yaml:"cert,omitempty"`
 }
 
+// EtcdRetryOptions configures how updates which lost the race against
+// concurrent updates are retried, see core.RetryPolicy. Omitted fields fall
+// back to core.DefaultRetryPolicy.
+type EtcdRetryOptions struct {
+	MaxAttempts    int    `json:"max_attempts,omitempty" yaml:"max_attempts,omitempty"`
+	InitialBackoff string `json:"initial_backoff,omitempty" yaml:"initial_backoff,omitempty"`
+	MaxBackoff     string `json:"max_backoff,omitempty" yaml:"max_backoff,omitempty"`
+}
+
+func (in *EtcdRetryOptions) policy() (policy core.RetryPolicy, err error) {
+	policy = core.DefaultRetryPolicy
+	if in == nil {
+		return policy, nil
+	}
+	if in.MaxAttempts > 0 {
+		policy.MaxAttempts = in.MaxAttempts
+	}
+	if policy.InitialBackoff, err = parseTimeout(in.InitialBackoff, policy.InitialBackoff); err != nil {
+		return policy, fmt.Errorf("Invalid initial backoff '%s': %v", in.InitialBackoff, err)
+	}
+	if policy.MaxBackoff, err = parseTimeout(in.MaxBackoff, policy.MaxBackoff); err != nil {
+		return policy, fmt.Errorf("Invalid max backoff '%s': %v", in.MaxBackoff, err)
+	}
+	return policy, nil
+}
+
 type Etcd struct {
-	Endpoints  []string        `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
-	Namespace  []string        `json:"-" yaml:"-"`
+	Endpoints []string `json:"endpoints,omitempty" yaml:"endpoints,omitempty"`
+	// Namespace isolates the inventory from others within the same cluster,
+	// it must have been created unless it is empty or DefaultNamespace.
+	Namespace  string          `json:"namespace,omitempty" yaml:"namespace,omitempty"`
 	User       string          `json:"user,omitempty" yaml:"user,omitempty"`
 	Password   string          `json:"password,omitempty" yaml:"password,omitempty"`
 	SSLOptions *EtcdSSLOptions `json:"ssl,omitempty" yaml:"ssl,omitempty"`
+	// TrashRetention is the period a trashed host is kept before etcd expires
+	// it, e.g. "720h".
+	TrashRetention string `json:"trash_retention,omitempty" yaml:"trash_retention,omitempty"`
+	// DialTimeout bounds the time of connecting to endpoints, e.g. "3s".
+	DialTimeout string `json:"dial_timeout,omitempty" yaml:"dial_timeout,omitempty"`
+	// OperationTimeout bounds the time of each storage's operation, e.g.
+	// "5s". Operations give up earlier once their context is done.
+	OperationTimeout string `json:"operation_timeout,omitempty" yaml:"operation_timeout,omitempty"`
+	// Retry configures retries of updates which lost the race against
+	// concurrent updates, which are bounded by OperationTimeout as well.
+	Retry *EtcdRetryOptions `json:"retry,omitempty" yaml:"retry,omitempty"`
 }
 
 func (in *Etcd) Open(logger *zap.SugaredLogger) (core.Storage, error) {
+	trashRetention, err := core.ParseTrashRetention(in.TrashRetention)
+	if err != nil {
+		return nil, err
+	}
+	operationTimeout, err := parseTimeout(in.OperationTimeout, defaultOperationTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid operation timeout '%s': %v", in.OperationTimeout, err)
+	}
+	retry, err := in.Retry.policy()
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := namespacePrefix(in.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	db, err := in.dial()
+	if err != nil {
+		return nil, err
+	}
+	if in.Namespace != "" && in.Namespace != DefaultNamespace {
+		ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+		res, err := db.Get(ctx, namespaceRegistryPrefix+in.Namespace)
+		cancel()
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		if len(res.Kvs) == 0 {
+			db.Close()
+			return nil, fmt.Errorf("Namespace '%s' does not exist", in.Namespace)
+		}
+	}
+	db.KV = namespace.NewKV(db.KV, prefix)
+	db.Watcher = namespace.NewWatcher(db.Watcher, prefix)
+	c := &conn{
+		db:               db,
+		trashRetention:   trashRetention,
+		retry:            retry,
+		operationTimeout: operationTimeout,
+		logger:           logger,
+	}
+	if err = c.checkIndexes(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// dial connects to the etcd cluster without any namespace.
+func (in *Etcd) dial() (*clientv3.Client, error) {
+	dialTimeout, err := parseTimeout(in.DialTimeout, defaultDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid dial timeout '%s': %v", in.DialTimeout, err)
+	}
 	cfg := clientv3.Config{
 		Endpoints:   in.Endpoints,
-		DialTimeout: defaultDialTimeout * time.Second,
+		DialTimeout: dialTimeout,
 		Username:    in.User,
 		Password:    in.Password,
 	}
@@ -83,21 +182,23 @@ func (in *Etcd) Open(logger *zap.SugaredLogger) (core.Storage, error) {
 		cfg.TLS = clientTLS
 	}
 
-	cfg.DialTimeout = 3 * time.Second
+	return clientv3.New(cfg)
+}
 
-	db, err := clientv3.New(cfg)
+// parseTimeout parses a positive duration from adapter configuration, an empty
+// string means def.
+func parseTimeout(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	in.Namespace = []string{"/cn.ivyent", "ivy-utils"}
-	if len(in.Namespace) > 0 {
-		db.KV = namespace.NewKV(db.KV, filepath.Join(in.Namespace...))
+	if d <= 0 {
+		return 0, fmt.Errorf("Timeout must be positive")
 	}
-	c := &conn{
-		db:     db,
-		logger: logger,
-	}
-	return c, nil
+	return d, nil
 }
 
 func New() *Etcd {
@@ -0,0 +1,76 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	embed "github.com/coreos/etcd/embed"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	storagetest "github.com/universonic/ivy-utils/pkg/storage/storagetest"
+	zap "go.uber.org/zap"
+)
+
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ivy-etcd-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	endpoint, stop := startEtcd(t, dir)
+	defer stop()
+	// each case runs in a namespace of its own, so that it starts empty
+	var cases int
+	storagetest.Run(t, func(t *testing.T) core.Storage {
+		cases++
+		adapter := New()
+		adapter.Endpoints = []string{endpoint}
+		adapter.Namespace = fmt.Sprintf("conformance-%d", cases)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := adapter.CreateNamespace(ctx, adapter.Namespace); err != nil {
+			t.Fatal(err)
+		}
+		storage, err := adapter.Open(zap.NewNop().Sugar())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return storage
+	})
+}
+
+// startEtcd starts a single member cluster keeping its data in dir, which
+// listens on free ports of the loopback interface.
+func startEtcd(t *testing.T, dir string) (endpoint string, stop func()) {
+	clientURL, peerURL := freeURL(t), freeURL(t)
+	cfg := embed.NewConfig()
+	cfg.Dir = dir
+	cfg.LCUrls, cfg.ACUrls = []url.URL{clientURL}, []url.URL{clientURL}
+	cfg.LPUrls, cfg.APUrls = []url.URL{peerURL}, []url.URL{peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	server, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-server.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		server.Close()
+		t.Fatal("Embedded etcd was not ready in time")
+	}
+	return clientURL.String(), server.Close
+}
+
+func freeURL(t *testing.T) url.URL {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return url.URL{Scheme: "http", Host: l.Addr().String()}
+}
@@ -3,7 +3,6 @@ package etcd
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"path/filepath"
 	"time"
 
@@ -15,48 +14,63 @@ import (
 
 const (
 	hostPrefix = "host"
-
-	// defaultStorageTimeout will be applied to all storage's operations.
-	defaultStorageTimeout = 5 * time.Second
 )
 
 type conn struct {
-	db     *clientv3.Client
-	logger *zap.SugaredLogger
+	db             *clientv3.Client
+	trashRetention time.Duration
+	// retry re-runs updates which lost the race against concurrent updates
+	retry core.RetryPolicy
+	// operationTimeout bounds each operation in addition to its context
+	operationTimeout time.Duration
+	// indexed tells whether indexes of existing hosts were built when the
+	// storage was opened
+	indexed bool
+	logger  *zap.SugaredLogger
 }
 
 func (c *conn) Close() error {
 	return c.db.Close()
 }
 
-func (c *conn) CreateHost(host core.Host) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) CreateHost(ctx context.Context, host core.Host) error {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
 	defer cancel()
 	if _, err := uuid.FromString(host.GUID); err != nil {
 		host.GUID = uuid.NewV4().String()
 	}
+	host.UpdatedAt = time.Now().UTC()
 	// NOTE: we are currently using hostname as host's primary unique identifier
-	return c.txnCreate(ctx, canonicalID(hostPrefix, host.Hostname), host)
+	return c.txnCreate(ctx, canonicalID(hostPrefix, host.Hostname), host, hostIndexes)
 }
 
-func (c *conn) GetHost(id string) (host core.Host, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) GetHost(ctx context.Context, id string) (host core.Host, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
 	defer cancel()
-	if err = c.getKey(ctx, canonicalID(hostPrefix, id), &host); err != nil {
+	if host.Revision, err = c.getKey(ctx, canonicalID(hostPrefix, id), &host); err != nil {
 		return
 	}
 	return host, nil
 }
 
-func (c *conn) UpdateHost(id string, updater func(host core.Host) (core.Host, error)) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+// UpdateHost runs updater again with the latest host whenever a concurrent
+// update wins the race, as configured by the retry policy.
+func (c *conn) UpdateHost(ctx context.Context, id string, updater func(host core.Host) (core.Host, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
 	defer cancel()
-	return c.txnUpdate(ctx, canonicalID(hostPrefix, id), func(currentValue []byte) ([]byte, error) {
+	return c.retry.Do(ctx, func() error {
+		return c.txnUpdate(ctx, canonicalID(hostPrefix, id), hostUpdater(id, updater), hostIndexes)
+	})
+}
+
+func hostUpdater(id string, updater func(host core.Host) (core.Host, error)) func(currentValue []byte, revision int64) ([]byte, error) {
+	return func(currentValue []byte, revision int64) ([]byte, error) {
 		current := core.NewHost()
 		if len(currentValue) > 0 {
 			if err := json.Unmarshal(currentValue, current); err != nil {
 				return nil, err
 			}
+			current.Revision = revision
 		}
 		updated, err := updater(*current)
 		if err != nil {
@@ -65,18 +79,23 @@ func (c *conn) UpdateHost(id string, updater func(host core.Host) (core.Host, er
 		if _, err := uuid.FromString(updated.GUID); err != nil {
 			updated.GUID = uuid.NewV4().String()
 		}
+		updated.UpdatedAt = time.Now().UTC()
+		// hostname is the key of host, which is also referred by indexes
+		updated.Hostname = id
 		return json.Marshal(updated)
-	})
+	}
 }
 
-func (c *conn) DeleteHost(id string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) DeleteHost(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
 	defer cancel()
-	return c.deleteKey(ctx, canonicalID(hostPrefix, id))
+	return c.retry.Do(ctx, func() error {
+		return c.deleteKey(ctx, canonicalID(hostPrefix, id), hostIndexes)
+	})
 }
 
-func (c *conn) ListHost() (hosts []core.Host, err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultStorageTimeout)
+func (c *conn) ListHost(ctx context.Context) (hosts []core.Host, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
 	defer cancel()
 	res, err := c.db.Get(ctx, hostPrefix, clientv3.WithPrefix())
 	if err != nil {
@@ -87,12 +106,13 @@ func (c *conn) ListHost() (hosts []core.Host, err error) {
 		if err = json.Unmarshal(v.Value, &host); err != nil {
 			return nil, err
 		}
+		host.Revision = v.ModRevision
 		hosts = append(hosts, host)
 	}
 	return hosts, nil
 }
 
-func (c *conn) txnCreate(ctx context.Context, key string, value interface{}) (err error) {
+func (c *conn) txnCreate(ctx context.Context, key string, value interface{}, index indexer) (err error) {
 	defer func() {
 		defer c.logger.Sync()
 		if err != nil {
@@ -106,22 +126,34 @@ func (c *conn) txnCreate(ctx context.Context, key string, value interface{}) (er
 	if err != nil {
 		return err
 	}
+	var idx indexTxn
+	if index != nil {
+		if idx, err = index(nil, b); err != nil {
+			return err
+		}
+	}
 	txn := c.db.Txn(ctx)
 	var res *clientv3.TxnResponse
 	res, err = txn.
-		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
-		Then(clientv3.OpPut(key, string(b))).
+		If(append([]clientv3.Cmp{clientv3.Compare(clientv3.CreateRevision(key), "=", 0)}, idx.cmps...)...).
+		Then(append([]clientv3.Op{clientv3.OpPut(key, string(b))}, idx.ops...)...).
+		Else(append([]clientv3.Op{clientv3.OpGet(key, clientv3.WithCountOnly())}, idx.checkOps()...)...).
 		Commit()
 	if err != nil {
 		return err
 	}
 	if !res.Succeeded {
-		return core.ErrResourceAlreadyExists
+		if res.Responses[0].GetResponseRange().Count > 0 {
+			return core.ErrResourceAlreadyExists
+		}
+		return idx.conflict(res.Responses[1:])
 	}
 	return nil
 }
 
-func (c *conn) getKey(ctx context.Context, key string, value interface{}) (err error) {
+// getKey decodes the value of key into value, and returns the revision at
+// which key was last modified.
+func (c *conn) getKey(ctx context.Context, key string, value interface{}) (revision int64, err error) {
 	defer func() {
 		defer c.logger.Sync()
 		if err != nil {
@@ -133,15 +165,18 @@ func (c *conn) getKey(ctx context.Context, key string, value interface{}) (err e
 	var r *clientv3.GetResponse
 	r, err = c.db.Get(ctx, key)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if r.Count == 0 {
-		return core.ErrResourceNotFound
+		return 0, core.ErrResourceNotFound
 	}
-	return json.Unmarshal(r.Kvs[0].Value, value)
+	return r.Kvs[0].ModRevision, json.Unmarshal(r.Kvs[0].Value, value)
 }
 
-func (c *conn) txnUpdate(ctx context.Context, key string, update func(current []byte) ([]byte, error)) (err error) {
+// txnUpdate replaces the value of key with the one computed by update from the
+// current value and the revision it was last modified at. It fails with
+// core.ErrConcurrentUpdate if key is modified in the meantime.
+func (c *conn) txnUpdate(ctx context.Context, key string, update func(current []byte, revision int64) ([]byte, error), index indexer) (err error) {
 	var updatedValue []byte
 	defer func() {
 		defer c.logger.Sync()
@@ -163,27 +198,39 @@ func (c *conn) txnUpdate(ctx context.Context, key string, update func(current []
 		modRev = getResp.Kvs[0].ModRevision
 	}
 
-	updatedValue, err = update(currentValue)
+	updatedValue, err = update(currentValue, modRev)
 	if err != nil {
 		return err
 	}
 
+	var idx indexTxn
+	if index != nil {
+		if idx, err = index(currentValue, updatedValue); err != nil {
+			return err
+		}
+	}
 	txn := c.db.Txn(ctx)
 	var updateResp *clientv3.TxnResponse
 	updateResp, err = txn.
-		If(clientv3.Compare(clientv3.ModRevision(key), "=", modRev)).
-		Then(clientv3.OpPut(key, string(updatedValue))).
+		If(append([]clientv3.Cmp{clientv3.Compare(clientv3.ModRevision(key), "=", modRev)}, idx.cmps...)...).
+		Then(append([]clientv3.Op{clientv3.OpPut(key, string(updatedValue))}, idx.ops...)...).
+		Else(idx.checkOps()...).
 		Commit()
 	if err != nil {
 		return err
 	}
 	if !updateResp.Succeeded {
-		return fmt.Errorf("Could not update key=%q due to: concurrent conflicting update happened", key)
+		if err = idx.conflict(updateResp.Responses); err != nil {
+			return err
+		}
+		return core.ErrConcurrentUpdate
 	}
 	return nil
 }
 
-func (c *conn) deleteKey(ctx context.Context, key string) (err error) {
+// deleteKey removes key together with its secondary indexes, if index is not
+// nil.
+func (c *conn) deleteKey(ctx context.Context, key string, index indexer) (err error) {
 	defer func() {
 		defer c.logger.Sync()
 		if err != nil {
@@ -192,14 +239,40 @@ func (c *conn) deleteKey(ctx context.Context, key string) (err error) {
 			c.logger.Debugf("Deleted key '%s'", key)
 		}
 	}()
-	var res *clientv3.DeleteResponse
-	res, err = c.db.Delete(ctx, key)
+	if index == nil {
+		var res *clientv3.DeleteResponse
+		res, err = c.db.Delete(ctx, key)
+		if err != nil {
+			return err
+		}
+		if res.Deleted == 0 {
+			return core.ErrResourceNotFound
+		}
+		return nil
+	}
+	var getResp *clientv3.GetResponse
+	getResp, err = c.db.Get(ctx, key)
 	if err != nil {
 		return err
 	}
-	if res.Deleted == 0 {
+	if getResp.Count == 0 {
 		return core.ErrResourceNotFound
 	}
+	var idx indexTxn
+	if idx, err = index(getResp.Kvs[0].Value, nil); err != nil {
+		return err
+	}
+	var res *clientv3.TxnResponse
+	res, err = c.db.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", getResp.Kvs[0].ModRevision)).
+		Then(append([]clientv3.Op{clientv3.OpDelete(key)}, idx.ops...)...).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !res.Succeeded {
+		return core.ErrConcurrentUpdate
+	}
 	return nil
 }
 
@@ -0,0 +1,107 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+
+	clientv3 "github.com/coreos/etcd/clientv3"
+	rpctypes "github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// HostHistory walks backwards through previous revisions of the host key,
+// until the version which created the key or the compacted revision is
+// reached. If the key was created by renaming, the walk continues with the
+// previous hostname, which is found by the GUID index right before.
+func (c *conn) HostHistory(ctx context.Context, id string) (history []core.HostRevision, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	key := canonicalID(hostPrefix, id)
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during retrieving history of data entity '%s' due to: %v", key, err)
+		} else {
+			c.logger.Debugf("Retrieved %d revisions of key '%s'", len(history), key)
+		}
+	}()
+	var res *clientv3.GetResponse
+	res, err = c.db.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if res.Count == 0 {
+		return nil, core.ErrResourceNotFound
+	}
+	kv := res.Kvs[0]
+	walking := key
+	for {
+		var host core.Host
+		if err = json.Unmarshal(kv.Value, &host); err != nil {
+			return nil, err
+		}
+		host.Revision = kv.ModRevision
+		history = append(history, core.HostRevision{Revision: kv.ModRevision, Host: host})
+		if kv.Version <= 1 {
+			var previous string
+			previous, err = c.renamedFrom(ctx, host, kv.ModRevision)
+			if err != nil {
+				return nil, err
+			}
+			if previous == "" {
+				return history, nil
+			}
+			walking = canonicalID(hostPrefix, previous)
+		}
+		res, err = c.db.Get(ctx, walking, clientv3.WithRev(kv.ModRevision-1))
+		if err == rpctypes.ErrCompacted {
+			return history, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if res.Count == 0 {
+			return history, nil
+		}
+		kv = res.Kvs[0]
+	}
+}
+
+func (c *conn) ListHostAt(ctx context.Context, revision int64) (hosts []core.Host, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	res, err := c.db.Get(ctx, hostPrefix, clientv3.WithPrefix(), clientv3.WithRev(revision))
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range res.Kvs {
+		var host core.Host
+		if err = json.Unmarshal(v.Value, &host); err != nil {
+			return nil, err
+		}
+		host.Revision = v.ModRevision
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// renamedFrom returns the previous hostname if host was renamed at given
+// revision, or an empty string otherwise.
+func (c *conn) renamedFrom(ctx context.Context, host core.Host, revision int64) (string, error) {
+	if host.GUID == "" {
+		return "", nil
+	}
+	res, err := c.db.Get(ctx, indexKey(core.IndexEntry{Field: core.IndexGUID, Value: host.GUID}), clientv3.WithRev(revision-1))
+	if err == rpctypes.ErrCompacted {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if res.Count == 0 {
+		return "", nil
+	}
+	previous := string(res.Kvs[0].Value)
+	if previous == host.Hostname {
+		return "", nil
+	}
+	return previous, nil
+}
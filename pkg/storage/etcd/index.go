@@ -0,0 +1,337 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	clientv3 "github.com/coreos/etcd/clientv3"
+	etcdserverpb "github.com/coreos/etcd/etcdserver/etcdserverpb"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	// indexPrefix holds secondary index keys of hosts, see core.IndexEntry.
+	indexPrefix = "index"
+	// indexMarker is written once indexes of all existing hosts were built.
+	indexMarker = indexPrefix + "/.built"
+	// buildIndexesPageSize is the number of hosts indexed at a time.
+	buildIndexesPageSize = 100
+	// maxTxnOps is the default limit of operations within a transaction of
+	// etcd servers.
+	maxTxnOps = 128
+)
+
+// indexer computes changes of secondary indexes while a key changes from
+// current to updated value, where an empty value means the key is absent.
+type indexer func(current, updated []byte) (indexTxn, error)
+
+// indexTxn carries comparisons and operations which maintain secondary
+// indexes within the same transaction as the primary key.
+type indexTxn struct {
+	cmps []clientv3.Cmp
+	ops  []clientv3.Op
+	// uniqueKeys are index keys required to be absent, which are fetched in
+	// the else branch to tell an index conflict from other failures.
+	uniqueKeys []string
+}
+
+// checkOps returns operations for the else branch of transaction, whose
+// responses are inspected by conflict.
+func (in indexTxn) checkOps() (ops []clientv3.Op) {
+	for _, key := range in.uniqueKeys {
+		ops = append(ops, clientv3.OpGet(key, clientv3.WithCountOnly()))
+	}
+	return ops
+}
+
+// conflict returns core.ErrIndexConflict if any unique index key was taken,
+// according to the responses of checkOps.
+func (in indexTxn) conflict(responses []*etcdserverpb.ResponseOp) error {
+	for _, each := range responses {
+		if r := each.GetResponseRange(); r != nil && r.Count > 0 {
+			return core.ErrIndexConflict
+		}
+	}
+	return nil
+}
+
+func indexKey(entry core.IndexEntry) string { return indexPrefix + "/" + entry.Key() }
+
+// hostIndexes is the indexer of host keys.
+func hostIndexes(current, updated []byte) (txn indexTxn, err error) {
+	var oldHost, newHost *core.Host
+	if len(current) > 0 {
+		oldHost = new(core.Host)
+		if err = json.Unmarshal(current, oldHost); err != nil {
+			return txn, err
+		}
+	}
+	if len(updated) > 0 {
+		newHost = new(core.Host)
+		if err = json.Unmarshal(updated, newHost); err != nil {
+			return txn, err
+		}
+	}
+	removed, added := core.DiffIndexEntries(oldHost, newHost)
+	return indexChanges(removed, added), nil
+}
+
+// indexChanges builds the transaction which removes and adds given entries.
+// etcd rejects a transaction touching the same key twice, which happens when
+// a unique entry is moved to another hostname.
+func indexChanges(removed, added []core.IndexEntry) (txn indexTxn) {
+	moved := make(map[string]bool)
+	for _, each := range added {
+		key := indexKey(each)
+		if !each.Field.Unique() {
+			txn.ops = append(txn.ops, clientv3.OpPut(key, ""))
+			continue
+		}
+		for _, old := range removed {
+			if indexKey(old) == key {
+				moved[key] = true
+			}
+		}
+		if !moved[key] {
+			txn.cmps = append(txn.cmps, clientv3.Compare(clientv3.CreateRevision(key), "=", 0))
+			txn.uniqueKeys = append(txn.uniqueKeys, key)
+		}
+		txn.ops = append(txn.ops, clientv3.OpPut(key, each.Hostname))
+	}
+	for _, each := range removed {
+		if key := indexKey(each); !moved[key] {
+			txn.ops = append(txn.ops, clientv3.OpDelete(key))
+		}
+	}
+	return txn
+}
+
+// GetHostBy reads the index and the host at the same revision, so that a
+// host renamed in between is never mistaken for a missing one. Hosts are
+// scanned instead if indexes of existing hosts have not been built.
+func (c *conn) GetHostBy(ctx context.Context, field core.IndexField, value string) (host core.Host, err error) {
+	if !c.indexed {
+		return c.scanHostBy(ctx, field, value)
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during looking up data entity by '%s=%s' due to: %v", field, value, err)
+		} else {
+			c.logger.Debugf("Retrieved host '%s' by '%s=%s'", host.Hostname, field, value)
+		}
+	}()
+	if value == "" {
+		return host, core.ErrResourceNotFound
+	}
+	var hostname string
+	var res *clientv3.GetResponse
+	if field.Unique() {
+		res, err = c.db.Get(ctx, indexKey(core.IndexEntry{Field: field, Value: value}))
+		if err != nil {
+			return host, err
+		}
+		if res.Count == 0 {
+			return host, core.ErrResourceNotFound
+		}
+		hostname = string(res.Kvs[0].Value)
+	} else {
+		prefix := indexPrefix + "/" + core.ValuePrefix(field, value)
+		res, err = c.db.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly(), clientv3.WithLimit(2))
+		if err != nil {
+			return host, err
+		}
+		switch res.Count {
+		case 0:
+			return host, core.ErrResourceNotFound
+		case 1:
+		default:
+			return host, core.ErrAmbiguousIndex
+		}
+		if hostname, err = url.PathUnescape(strings.TrimPrefix(string(res.Kvs[0].Key), prefix)); err != nil {
+			return host, err
+		}
+	}
+	var hostRes *clientv3.GetResponse
+	hostRes, err = c.db.Get(ctx, canonicalID(hostPrefix, hostname), clientv3.WithRev(res.Header.Revision))
+	if err != nil {
+		return host, err
+	}
+	if hostRes.Count == 0 {
+		return host, core.ErrResourceNotFound
+	}
+	err = json.Unmarshal(hostRes.Kvs[0].Value, &host)
+	host.Revision = hostRes.Kvs[0].ModRevision
+	return host, err
+}
+
+func (c *conn) scanHostBy(ctx context.Context, field core.IndexField, value string) (host core.Host, err error) {
+	hosts, err := c.ListHost(ctx)
+	if err != nil {
+		return host, err
+	}
+	selected := core.SelectHostsBy(hosts, field, value)
+	switch len(selected) {
+	case 0:
+		return host, core.ErrResourceNotFound
+	case 1:
+		return selected[0], nil
+	}
+	if field.Unique() {
+		return host, core.ErrIndexConflict
+	}
+	return host, core.ErrAmbiguousIndex
+}
+
+// checkIndexes tells whether indexes of existing hosts were built, which is
+// assumed for an empty storage as well. Marking an empty storage is merely
+// attempted, so that read-only users could still open it.
+func (c *conn) checkIndexes(ctx context.Context) error {
+	res, err := c.rangeOnce(ctx, indexMarker, clientv3.WithCountOnly())
+	if err != nil {
+		return err
+	}
+	if res.Count > 0 {
+		c.indexed = true
+		return nil
+	}
+	if res, err = c.rangeOnce(ctx, hostPrefix+"/", clientv3.WithPrefix(), clientv3.WithCountOnly()); err != nil {
+		return err
+	}
+	if res.Count > 0 {
+		c.logger.Warnf("Indexes of existing hosts have not been built, thus lookups scan all hosts until they are built by 'cmdb migrate'")
+		return nil
+	}
+	c.indexed = true
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	if _, err = c.db.Put(ctx, indexMarker, ""); err != nil {
+		c.logger.Debugf("Could not mark indexes of empty storage as built due to: %v", err)
+	}
+	return nil
+}
+
+// BuildIndexes indexes hosts written before secondary indexes were
+// introduced, a page of hosts at a time. Hosts sharing the value of a unique
+// field are reported, and indexes are not marked as built until they are
+// resolved, so that lookups keep scanning hosts.
+func (c *conn) BuildIndexes(ctx context.Context) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during building indexes of '%s' due to: %v", hostPrefix, err)
+		} else {
+			c.logger.Debugf("Built indexes of '%s'", hostPrefix)
+		}
+	}()
+	var conflicts []string
+	opts := core.ListOptions{Limit: buildIndexesPageSize}
+	for {
+		var page core.HostPage
+		page, err = c.ListHosts(ctx, opts)
+		if err != nil {
+			return err
+		}
+		err = c.retry.Do(ctx, func() error {
+			found, err := c.buildIndexesOf(ctx, page.Hosts)
+			if err == nil {
+				conflicts = append(conflicts, found...)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if page.Continue == "" {
+			break
+		}
+		opts.Continue = page.Continue
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("%v: %s", core.ErrIndexConflict, strings.Join(conflicts, ", "))
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	_, err = c.db.Put(ctx, indexMarker, "")
+	return err
+}
+
+// buildIndexesOf writes index entries of hosts in transactions of at most
+// maxTxnOps operations each, within the operation timeout. Unique entries
+// held by other hosts are skipped and returned as conflicts.
+func (c *conn) buildIndexesOf(ctx context.Context, hosts []core.Host) (conflicts []string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	var entries []core.IndexEntry
+	var gets []clientv3.Op
+	for _, host := range hosts {
+		for _, entry := range core.HostIndexEntries(host) {
+			entries = append(entries, entry)
+			if entry.Field.Unique() {
+				gets = append(gets, clientv3.OpGet(indexKey(entry)))
+			}
+		}
+	}
+	// owners of unique entries, either stored or to be written
+	owners := make(map[string]string)
+	for len(gets) > 0 {
+		n := len(gets)
+		if n > maxTxnOps {
+			n = maxTxnOps
+		}
+		var res *clientv3.TxnResponse
+		if res, err = c.db.Txn(ctx).Then(gets[:n]...).Commit(); err != nil {
+			return nil, err
+		}
+		for _, each := range res.Responses {
+			for _, kv := range each.GetResponseRange().Kvs {
+				owners[string(kv.Key)] = string(kv.Value)
+			}
+		}
+		gets = gets[n:]
+	}
+	var txn indexTxn
+	for _, entry := range entries {
+		key := indexKey(entry)
+		if owner, ok := owners[key]; ok && entry.Field.Unique() {
+			if owner != entry.Hostname {
+				conflicts = append(conflicts, fmt.Sprintf("'%s' of host '%s' is held by host '%s'", entry.Value, entry.Hostname, owner))
+			}
+			continue
+		}
+		if entry.Field.Unique() {
+			owners[key] = entry.Hostname
+		}
+		if len(txn.ops) == maxTxnOps {
+			if err = c.commitIndexes(ctx, txn); err != nil {
+				return nil, err
+			}
+			txn = indexTxn{}
+		}
+		each := indexChanges(nil, []core.IndexEntry{entry})
+		txn.cmps = append(txn.cmps, each.cmps...)
+		txn.ops = append(txn.ops, each.ops...)
+	}
+	if len(txn.ops) > 0 {
+		if err = c.commitIndexes(ctx, txn); err != nil {
+			return nil, err
+		}
+	}
+	return conflicts, nil
+}
+
+func (c *conn) commitIndexes(ctx context.Context, txn indexTxn) error {
+	res, err := c.db.Txn(ctx).If(txn.cmps...).Then(txn.ops...).Commit()
+	if err != nil {
+		return err
+	}
+	if !res.Succeeded {
+		return core.ErrConcurrentUpdate
+	}
+	return nil
+}
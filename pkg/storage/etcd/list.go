@@ -0,0 +1,100 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	clientv3 "github.com/coreos/etcd/clientv3"
+	rpctypes "github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	// listBatchSize is the maximum number of keys fetched by a single range
+	// request of ListHosts, which bounds memory usage regardless of the size
+	// of fleet.
+	listBatchSize = 500
+)
+
+// ListHosts pages through host keys with range requests pinned at a single
+// revision, and filters them before moving to the next batch.
+func (c *conn) ListHosts(ctx context.Context, opts core.ListOptions) (page core.HostPage, err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during listing data entities '%s' due to: %v", hostPrefix, err)
+		} else {
+			c.logger.Debugf("Listed %d keys of '%s' at revision %d", len(page.Hosts), hostPrefix, page.Revision)
+		}
+	}()
+	prefix := hostPrefix + "/"
+	start := prefix
+	revision := opts.Revision
+	if opts.Continue != "" {
+		var after string
+		revision, after, err = core.DecodeContinue(opts.Continue)
+		if err != nil {
+			return page, err
+		}
+		// the smallest key which is greater than the last returned one
+		start = canonicalID(hostPrefix, after) + "\x00"
+	}
+	end := clientv3.GetPrefixRangeEnd(prefix)
+	for {
+		batch := int64(listBatchSize)
+		if remaining := opts.Limit - int64(len(page.Hosts)); opts.Limit > 0 && len(opts.Predicates) == 0 && remaining < batch {
+			batch = remaining
+		}
+		getOpts := []clientv3.OpOption{clientv3.WithRange(end), clientv3.WithLimit(batch)}
+		if revision > 0 {
+			getOpts = append(getOpts, clientv3.WithRev(revision))
+		}
+		var res *clientv3.GetResponse
+		res, err = c.rangeOnce(ctx, start, getOpts...)
+		if err == rpctypes.ErrCompacted {
+			return page, fmt.Errorf("Revision %d has been compacted, please restart listing", revision)
+		} else if err != nil {
+			return page, err
+		}
+		if revision == 0 {
+			revision = res.Header.Revision
+		}
+		page.Revision = revision
+		for i, kv := range res.Kvs {
+			var host core.Host
+			if err = json.Unmarshal(kv.Value, &host); err != nil {
+				return page, err
+			}
+			host.Revision = kv.ModRevision
+			var ok bool
+			ok, err = core.MatchHost(host, opts.Predicates)
+			if err != nil {
+				return page, err
+			}
+			if !ok {
+				continue
+			}
+			page.Hosts = append(page.Hosts, host)
+			if opts.Limit > 0 && int64(len(page.Hosts)) >= opts.Limit {
+				if i+1 < len(res.Kvs) || res.More {
+					page.Continue = core.EncodeContinue(revision, strings.TrimPrefix(string(kv.Key), prefix))
+				}
+				return page, nil
+			}
+		}
+		if !res.More || len(res.Kvs) == 0 {
+			return page, nil
+		}
+		start = string(res.Kvs[len(res.Kvs)-1].Key) + "\x00"
+	}
+}
+
+// rangeOnce applies the storage timeout on each range request rather than
+// on the whole listing, which may take a number of requests.
+func (c *conn) rangeOnce(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	return c.db.Get(ctx, key, opts...)
+}
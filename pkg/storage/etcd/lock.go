@@ -0,0 +1,159 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	clientv3 "github.com/coreos/etcd/clientv3"
+	concurrency "github.com/coreos/etcd/clientv3/concurrency"
+	rpctypes "github.com/coreos/etcd/etcdserver/api/v3rpc/rpctypes"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	lockPrefix     = "lock"
+	lockInfoPrefix = "lockinfo"
+)
+
+// LockHost acquires the mutex of host within a session, whose lease is kept
+// alive until unlock is called. The holder is described by a key attached to
+// the same lease, thus both are removed once the lease expires or is revoked.
+func (c *conn) LockHost(ctx context.Context, lock core.HostLock, ttl time.Duration) (unlock func() error, err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during locking host '%s' due to: %v", lock.Hostname, err)
+		} else {
+			c.logger.Debugf("Locked host '%s' for '%s'", lock.Hostname, lock.Holder)
+		}
+	}()
+	seconds := int(ttl / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	// The session follows ctx until the lock is acquired, so that granting
+	// the lease gives up once ctx is done. Afterwards it is kept alive until
+	// unlock, while ctx is usually bounded by the time to wait for the lock.
+	sessionCtx, cancelSession := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	acquired := false
+	go func() {
+		select {
+		case <-ctx.Done():
+			mu.Lock()
+			if !acquired {
+				cancelSession()
+			}
+			mu.Unlock()
+		case <-sessionCtx.Done():
+		}
+	}()
+	session, err := concurrency.NewSession(c.db, concurrency.WithTTL(seconds), concurrency.WithContext(sessionCtx))
+	if err != nil {
+		cancelSession()
+		if ctx.Err() != nil {
+			return nil, core.ErrHostLocked
+		}
+		return nil, err
+	}
+	release := func() error {
+		defer cancelSession()
+		return session.Close()
+	}
+	mutex := concurrency.NewMutex(session, canonicalID(lockPrefix, lock.Hostname))
+	if err = mutex.Lock(ctx); err != nil {
+		release()
+		if ctx.Err() != nil {
+			return nil, core.ErrHostLocked
+		}
+		return nil, err
+	}
+	lock.AcquiredAt = time.Now().UTC()
+	lock.ExpiresAt = time.Time{}
+	b, err := json.Marshal(lock)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	putCtx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	_, err = c.db.Put(putCtx, canonicalID(lockInfoPrefix, lock.Hostname), string(b), clientv3.WithLease(session.Lease()))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	mu.Lock()
+	acquired = sessionCtx.Err() == nil
+	mu.Unlock()
+	if !acquired {
+		// ctx was done right before the lock was acquired
+		release()
+		return nil, core.ErrHostLocked
+	}
+	return func() error {
+		// revoking the lease releases the mutex as well
+		err := release()
+		if err == rpctypes.ErrLeaseNotFound {
+			// forcibly unlocked
+			return nil
+		}
+		return err
+	}, nil
+}
+
+// ListHostLocks returns current locks, whose expiry is when their leases
+// expire unless they are kept alive.
+func (c *conn) ListHostLocks(ctx context.Context) ([]core.HostLock, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	res, err := c.db.Get(ctx, lockInfoPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	var locks []core.HostLock
+	for _, kv := range res.Kvs {
+		var lock core.HostLock
+		if err = json.Unmarshal(kv.Value, &lock); err != nil {
+			return nil, err
+		}
+		ttl, err := c.db.TimeToLive(ctx, clientv3.LeaseID(kv.Lease))
+		if err != nil {
+			return nil, err
+		}
+		if ttl.TTL > 0 {
+			lock.ExpiresAt = time.Now().UTC().Add(time.Duration(ttl.TTL) * time.Second)
+		}
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
+
+// ForceUnlockHost revokes the lease of the lock holder, which releases the
+// mutex of host for the next waiter.
+func (c *conn) ForceUnlockHost(ctx context.Context, id string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	key := canonicalID(lockInfoPrefix, id)
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during unlocking host '%s' due to: %v", id, err)
+		} else {
+			c.logger.Debugf("Forcibly unlocked host '%s'", id)
+		}
+	}()
+	res, err := c.db.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if res.Count == 0 {
+		return core.ErrResourceNotFound
+	}
+	_, err = c.db.Revoke(ctx, clientv3.LeaseID(res.Kvs[0].Lease))
+	if err == rpctypes.ErrLeaseNotFound {
+		return core.ErrResourceNotFound
+	}
+	return err
+}
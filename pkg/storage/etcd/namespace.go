@@ -0,0 +1,144 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	clientv3 "github.com/coreos/etcd/clientv3"
+	tablewriter "github.com/olekukonko/tablewriter"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	// DefaultNamespace is the namespace used if none is configured, which
+	// always exists.
+	DefaultNamespace = "default"
+
+	// legacyNamespacePrefix is the prefix of the default namespace, which
+	// is kept as is so that existing inventories remain in place.
+	legacyNamespacePrefix = "/cn.ivyent/ivy-utils"
+	// namedNamespacePrefix is followed by the name of namespace. Keys of the
+	// default namespace never start with a slash, thus never collide with it.
+	namedNamespacePrefix = "/cn.ivyent/ivy-utils/"
+	// namespaceRegistryPrefix is followed by names of created namespaces.
+	namespaceRegistryPrefix = "/cn.ivyent/ivy-utils.namespaces/"
+)
+
+var namespaceNamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9_.-]{0,61}[a-z0-9])?$`)
+
+// Namespace isolates an inventory from others within the same etcd cluster.
+type Namespace struct {
+	Name      string    `json:"name" yaml:"name"`
+	Prefix    string    `json:"prefix" yaml:"prefix"`
+	CreatedAt time.Time `json:"created_at,omitempty" yaml:"created_at,omitempty"`
+}
+
+type NamespaceList []Namespace
+
+func (in NamespaceList) CanonicalString() string {
+	var buf bytes.Buffer
+	table := tablewriter.NewWriter(&buf)
+	table.SetHeader([]string{"Name", "Prefix", "Created At"})
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	for _, each := range in {
+		var createdAt string
+		if !each.CreatedAt.IsZero() {
+			createdAt = each.CreatedAt.Local().Format(time.RFC3339)
+		}
+		table.Append([]string{each.Name, each.Prefix, createdAt})
+	}
+	table.Render()
+	return buf.String()
+}
+
+// namespacePrefix returns the key prefix of namespace.
+func namespacePrefix(name string) (string, error) {
+	if name == "" || name == DefaultNamespace {
+		return legacyNamespacePrefix, nil
+	}
+	if !namespaceNamePattern.MatchString(name) {
+		return "", fmt.Errorf("Invalid namespace '%s': only lowercase letters, digits, '_', '.' and '-' are allowed", name)
+	}
+	return namedNamespacePrefix + name + "/", nil
+}
+
+// ListNamespaces returns the default namespace followed by created namespaces
+// sorted by name. The configured namespace is ignored.
+func (in *Etcd) ListNamespaces(ctx context.Context) (NamespaceList, error) {
+	db, ctx, cancel, err := in.dialRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	defer cancel()
+	res, err := db.Get(ctx, namespaceRegistryPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	list := NamespaceList{{Name: DefaultNamespace, Prefix: legacyNamespacePrefix}}
+	for _, kv := range res.Kvs {
+		var each Namespace
+		if err := json.Unmarshal(kv.Value, &each); err != nil {
+			return nil, err
+		}
+		list = append(list, each)
+	}
+	sort.Slice(list[1:], func(i, j int) bool { return list[i+1].Name < list[j+1].Name })
+	return list, nil
+}
+
+// CreateNamespace registers a namespace of given name, which fails with
+// core.ErrResourceAlreadyExists if it exists. The configured namespace is
+// ignored.
+func (in *Etcd) CreateNamespace(ctx context.Context, name string) error {
+	prefix, err := namespacePrefix(name)
+	if err != nil {
+		return err
+	}
+	if prefix == legacyNamespacePrefix {
+		return core.ErrResourceAlreadyExists
+	}
+	db, ctx, cancel, err := in.dialRaw(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	defer cancel()
+	b, err := json.Marshal(Namespace{Name: name, Prefix: prefix, CreatedAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	key := namespaceRegistryPrefix + name
+	res, err := db.Txn(ctx).If(
+		clientv3.Compare(clientv3.CreateRevision(key), "=", 0),
+	).Then(
+		clientv3.OpPut(key, string(b)),
+	).Commit()
+	if err != nil {
+		return err
+	}
+	if !res.Succeeded {
+		return core.ErrResourceAlreadyExists
+	}
+	return nil
+}
+
+// dialRaw connects to the cluster without any namespace, and bounds ctx by the
+// operation timeout.
+func (in *Etcd) dialRaw(ctx context.Context) (*clientv3.Client, context.Context, context.CancelFunc, error) {
+	operationTimeout, err := parseTimeout(in.OperationTimeout, defaultOperationTimeout)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Invalid operation timeout '%s': %v", in.OperationTimeout, err)
+	}
+	db, err := in.dial()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, operationTimeout)
+	return db, ctx, cancel, nil
+}
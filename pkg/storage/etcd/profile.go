@@ -0,0 +1,75 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	clientv3 "github.com/coreos/etcd/clientv3"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	profilePrefix = "profile"
+)
+
+func (c *conn) CreateCredentialProfile(ctx context.Context, profile core.CredentialProfile) error {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	profile.UpdatedAt = time.Now().UTC()
+	return c.txnCreate(ctx, canonicalID(profilePrefix, profile.Name), profile, nil)
+}
+
+func (c *conn) GetCredentialProfile(ctx context.Context, name string) (profile core.CredentialProfile, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	if _, err = c.getKey(ctx, canonicalID(profilePrefix, name), &profile); err != nil {
+		return
+	}
+	return profile, nil
+}
+
+func (c *conn) ListCredentialProfiles(ctx context.Context) (profiles []core.CredentialProfile, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	res, err := c.db.Get(ctx, profilePrefix+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range res.Kvs {
+		var profile core.CredentialProfile
+		if err = json.Unmarshal(v.Value, &profile); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+func (c *conn) UpdateCredentialProfile(ctx context.Context, name string, updater func(profile core.CredentialProfile) (core.CredentialProfile, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	return c.retry.Do(ctx, func() error {
+		return c.txnUpdate(ctx, canonicalID(profilePrefix, name), func(currentValue []byte, revision int64) ([]byte, error) {
+			var current core.CredentialProfile
+			if len(currentValue) > 0 {
+				if err := json.Unmarshal(currentValue, &current); err != nil {
+					return nil, err
+				}
+			}
+			updated, err := updater(current)
+			if err != nil {
+				return nil, err
+			}
+			updated.UpdatedAt = time.Now().UTC()
+			updated.Name = name
+			return json.Marshal(updated)
+		}, nil)
+	})
+}
+
+func (c *conn) DeleteCredentialProfile(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	return c.deleteKey(ctx, canonicalID(profilePrefix, name), nil)
+}
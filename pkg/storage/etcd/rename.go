@@ -0,0 +1,88 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	clientv3 "github.com/coreos/etcd/clientv3"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// RenameHost moves the host key together with its indexes within a single
+// transaction, keeping the GUID and everything else of the host.
+func (c *conn) RenameHost(ctx context.Context, id, newID string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	return c.retry.Do(ctx, func() error {
+		return c.renameHost(ctx, id, newID)
+	})
+}
+
+func (c *conn) renameHost(ctx context.Context, id, newID string) (err error) {
+	key := canonicalID(hostPrefix, id)
+	newKey := canonicalID(hostPrefix, newID)
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during renaming data entity '%s' to '%s' due to: %v", key, newKey, err)
+		} else {
+			c.logger.Debugf("Moved key '%s' to '%s'", key, newKey)
+		}
+	}()
+	if newID == "" {
+		return fmt.Errorf("New hostname must not be empty")
+	}
+	if key == newKey {
+		return core.ErrResourceAlreadyExists
+	}
+	var getResp *clientv3.GetResponse
+	getResp, err = c.db.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if getResp.Count == 0 {
+		return core.ErrResourceNotFound
+	}
+	var host core.Host
+	if err = json.Unmarshal(getResp.Kvs[0].Value, &host); err != nil {
+		return err
+	}
+	host.Hostname = newID
+	host.UpdatedAt = time.Now().UTC()
+	var b []byte
+	b, err = json.Marshal(host)
+	if err != nil {
+		return err
+	}
+	var idx indexTxn
+	if idx, err = hostIndexes(getResp.Kvs[0].Value, b); err != nil {
+		return err
+	}
+	var res *clientv3.TxnResponse
+	res, err = c.db.Txn(ctx).
+		If(append([]clientv3.Cmp{
+			clientv3.Compare(clientv3.ModRevision(key), "=", getResp.Kvs[0].ModRevision),
+			clientv3.Compare(clientv3.CreateRevision(newKey), "=", 0),
+		}, idx.cmps...)...).
+		Then(append([]clientv3.Op{
+			clientv3.OpDelete(key),
+			clientv3.OpPut(newKey, string(b)),
+		}, idx.ops...)...).
+		Else(append([]clientv3.Op{clientv3.OpGet(newKey, clientv3.WithCountOnly())}, idx.checkOps()...)...).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !res.Succeeded {
+		if res.Responses[0].GetResponseRange().Count > 0 {
+			return core.ErrResourceAlreadyExists
+		}
+		if err = idx.conflict(res.Responses[1:]); err != nil {
+			return err
+		}
+		return core.ErrConcurrentUpdate
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	clientv3 "github.com/coreos/etcd/clientv3"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	trashPrefix = "trash"
+)
+
+// TrashHost moves the host key into trash within a single transaction. The
+// trashed key is attached to a lease whose TTL equals to the retention
+// period, so that etcd purges it automatically.
+func (c *conn) TrashHost(ctx context.Context, id, reason string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	return c.retry.Do(ctx, func() error {
+		return c.trashHost(ctx, id, reason)
+	})
+}
+
+func (c *conn) trashHost(ctx context.Context, id, reason string) (err error) {
+	key := canonicalID(hostPrefix, id)
+	trashKey := canonicalID(trashPrefix, id)
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during trashing data entity '%s' due to: %v", key, err)
+		} else {
+			c.logger.Debugf("Moved key '%s' to '%s'", key, trashKey)
+		}
+	}()
+	var getResp *clientv3.GetResponse
+	getResp, err = c.db.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if getResp.Count == 0 {
+		return core.ErrResourceNotFound
+	}
+	trashed := core.TrashedHost{
+		DeletedAt: time.Now().UTC(),
+		Reason:    reason,
+	}
+	if err = json.Unmarshal(getResp.Kvs[0].Value, &trashed.Host); err != nil {
+		return err
+	}
+	var b []byte
+	b, err = json.Marshal(trashed)
+	if err != nil {
+		return err
+	}
+	ttl := int64(c.trashRetention / time.Second)
+	if ttl < 1 {
+		ttl = 1
+	}
+	var idx indexTxn
+	if idx, err = hostIndexes(getResp.Kvs[0].Value, nil); err != nil {
+		return err
+	}
+	var lease *clientv3.LeaseGrantResponse
+	lease, err = c.db.Grant(ctx, ttl)
+	if err != nil {
+		return err
+	}
+	var res *clientv3.TxnResponse
+	res, err = c.db.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", getResp.Kvs[0].ModRevision)).
+		Then(append([]clientv3.Op{
+			clientv3.OpDelete(key),
+			clientv3.OpPut(trashKey, string(b), clientv3.WithLease(lease.ID)),
+		}, idx.ops...)...).
+		Commit()
+	if err == nil && !res.Succeeded {
+		err = core.ErrConcurrentUpdate
+	}
+	if err != nil {
+		c.db.Revoke(ctx, lease.ID)
+		return err
+	}
+	return nil
+}
+
+func (c *conn) ListTrash(ctx context.Context) (trashed []core.TrashedHost, err error) {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	res, err := c.db.Get(ctx, trashPrefix+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range res.Kvs {
+		var each core.TrashedHost
+		if err = json.Unmarshal(v.Value, &each); err != nil {
+			return nil, err
+		}
+		trashed = append(trashed, each)
+	}
+	return trashed, nil
+}
+
+func (c *conn) RestoreHost(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+	return c.retry.Do(ctx, func() error {
+		return c.restoreHost(ctx, id)
+	})
+}
+
+func (c *conn) restoreHost(ctx context.Context, id string) (err error) {
+	key := canonicalID(hostPrefix, id)
+	trashKey := canonicalID(trashPrefix, id)
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during restoring data entity '%s' due to: %v", key, err)
+		} else {
+			c.logger.Debugf("Moved key '%s' to '%s'", trashKey, key)
+		}
+	}()
+	var getResp *clientv3.GetResponse
+	getResp, err = c.db.Get(ctx, trashKey)
+	if err != nil {
+		return err
+	}
+	if getResp.Count == 0 {
+		return core.ErrResourceNotFound
+	}
+	var trashed core.TrashedHost
+	if err = json.Unmarshal(getResp.Kvs[0].Value, &trashed); err != nil {
+		return err
+	}
+	host := trashed.Host
+	host.UpdatedAt = time.Now().UTC()
+	var b []byte
+	b, err = json.Marshal(host)
+	if err != nil {
+		return err
+	}
+	var idx indexTxn
+	if idx, err = hostIndexes(nil, b); err != nil {
+		return err
+	}
+	var res *clientv3.TxnResponse
+	res, err = c.db.Txn(ctx).
+		If(append([]clientv3.Cmp{
+			clientv3.Compare(clientv3.CreateRevision(key), "=", 0),
+			clientv3.Compare(clientv3.ModRevision(trashKey), "=", getResp.Kvs[0].ModRevision),
+		}, idx.cmps...)...).
+		Then(append([]clientv3.Op{
+			clientv3.OpPut(key, string(b)),
+			clientv3.OpDelete(trashKey),
+		}, idx.ops...)...).
+		Else(append([]clientv3.Op{clientv3.OpGet(key, clientv3.WithCountOnly())}, idx.checkOps()...)...).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !res.Succeeded {
+		if res.Responses[0].GetResponseRange().Count > 0 {
+			return core.ErrResourceAlreadyExists
+		}
+		if err = idx.conflict(res.Responses[1:]); err != nil {
+			return err
+		}
+		return core.ErrConcurrentUpdate
+	}
+	// The trashed key has gone, so its lease is no longer needed.
+	if lease := getResp.Kvs[0].Lease; lease != 0 {
+		c.db.Revoke(ctx, clientv3.LeaseID(lease))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	clientv3 "github.com/coreos/etcd/clientv3"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+func (c *conn) WatchHosts(ctx context.Context, fromRevision int64) (<-chan core.HostEvent, error) {
+	opts := []clientv3.OpOption{clientv3.WithPrefix(), clientv3.WithPrevKV()}
+	if fromRevision > 0 {
+		opts = append(opts, clientv3.WithRev(fromRevision))
+	}
+	wch := c.db.Watch(clientv3.WithRequireLeader(ctx), hostPrefix, opts...)
+	events := make(chan core.HostEvent)
+	go func() {
+		defer close(events)
+		defer c.logger.Sync()
+		send := func(event core.HostEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for resp := range wch {
+			if err := resp.Err(); err != nil {
+				c.logger.Errorf("Error occurred during watching hosts due to: %v", err)
+				send(core.HostEvent{Err: err})
+				return
+			}
+			for _, ev := range resp.Events {
+				event, err := hostEventOf(ev)
+				if err != nil {
+					c.logger.Errorf("Error occurred during decoding event of key '%s' due to: %v", ev.Kv.Key, err)
+					send(core.HostEvent{Err: err})
+					return
+				}
+				c.logger.Debugf("Observed %s event of key '%s' at revision %d", event.Type, ev.Kv.Key, event.Revision)
+				if !send(event) {
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func hostEventOf(ev *clientv3.Event) (event core.HostEvent, err error) {
+	event.Revision = ev.Kv.ModRevision
+	switch {
+	case ev.Type == clientv3.EventTypeDelete:
+		event.Type = core.EventDeleted
+		if ev.PrevKv != nil {
+			err = json.Unmarshal(ev.PrevKv.Value, &event.Host)
+			event.Host.Revision = ev.PrevKv.ModRevision
+		} else {
+			event.Host.Hostname = strings.TrimPrefix(string(ev.Kv.Key), hostPrefix+"/")
+		}
+		return
+	case ev.IsCreate():
+		event.Type = core.EventCreated
+	default:
+		event.Type = core.EventUpdated
+	}
+	err = json.Unmarshal(ev.Kv.Value, &event.Host)
+	event.Host.Revision = ev.Kv.ModRevision
+	return
+}
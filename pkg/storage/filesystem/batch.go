@@ -0,0 +1,66 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// ApplyBatch plans the batch with the lock held and applies it only if every
+// operation would succeed. Files are replaced one by one though, so a crash in
+// the middle could leave the batch partially applied.
+func (c *conn) ApplyBatch(ctx context.Context, ops []core.BatchOp) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during applying batch of %d operations due to: %v", len(ops), err)
+		} else {
+			c.logger.Debugf("Applied batch of %d operations", len(ops))
+		}
+	}()
+	paths := make([]string, len(ops))
+	for i, op := range ops {
+		if paths[i], err = c.path(hostPrefix, op.Host.Hostname); err != nil {
+			return err
+		}
+	}
+	var release func()
+	release, err = c.acquireLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	hosts, err := c.ListHost(ctx)
+	if err != nil {
+		return err
+	}
+	byHostname := make(map[string]*core.Host, len(hosts))
+	for i := range hosts {
+		byHostname[hosts[i].Hostname] = &hosts[i]
+	}
+	current := func(hostname string) (*core.Host, error) {
+		return byHostname[hostname], nil
+	}
+	owners := func(entry core.IndexEntry) (hostnames []string, err error) {
+		for _, each := range core.SelectHostsBy(hosts, entry.Field, entry.Value) {
+			hostnames = append(hostnames, each.Hostname)
+		}
+		return hostnames, nil
+	}
+	changes, err := core.PlanBatch(ops, current, owners)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if change.Updated == nil {
+			err = os.Remove(paths[change.Index])
+		} else {
+			err = c.writeFile(paths[change.Index], change.Updated)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
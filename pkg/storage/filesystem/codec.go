@@ -0,0 +1,98 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystem
+
+import (
+	"encoding/json"
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// codec encodes entities into file contents and vice versa.
+type codec interface {
+	Ext() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Ext() string { return ".json" }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type yamlCodec struct{}
+
+func (yamlCodec) Ext() string { return ".yaml" }
+
+func (yamlCodec) Marshal(v interface{}) ([]byte, error) { return yaml.Marshal(v) }
+
+// Unmarshal decodes YAML data through JSON, because YAML decodes nested
+// mappings as map[interface{}]interface{}, which could not be serialized by
+// other storages or reports later.
+func (yamlCodec) Unmarshal(data []byte, v interface{}) error {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	normalized, err := normalizeYAML(raw)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(normalized)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func normalizeYAML(in interface{}) (interface{}, error) {
+	switch v := in.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			k, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("Unsupported non-string key: %v", key)
+			}
+			normalized, err := normalizeYAML(value)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = normalized
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			normalized, err := normalizeYAML(v[i])
+			if err != nil {
+				return nil, err
+			}
+			out[i] = normalized
+		}
+		return out, nil
+	}
+	return in, nil
+}
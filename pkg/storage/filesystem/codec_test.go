@@ -0,0 +1,46 @@
+package filesystem
+
+import (
+	"bytes"
+	"testing"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	host := core.Host{
+		GUID:         "5f0b7a0e-4c43-4a6d-9a3e-0d3c5b3c2a11",
+		Hostname:     "node-1",
+		SSHAddress:   "10.0.0.1",
+		SSHPort:      2222,
+		SSHUser:      "root",
+		IPMIAddress:  "10.0.1.1",
+		IPMIUser:     "admin",
+		IPMIPassword: "secret",
+		ExtraInfo: core.ExtendableFields{
+			"rack": "a1",
+			"tags": []interface{}{"gpu", "ssd"},
+			"location": map[string]interface{}{
+				"room": "b2",
+			},
+		},
+	}
+	for _, c := range []codec{yamlCodec{}, jsonCodec{}} {
+		b, err := c.Marshal(host)
+		if err != nil {
+			t.Fatalf("Could not marshal host as %s due to: %v", c.Ext(), err)
+		}
+		var decoded core.Host
+		if err = c.Unmarshal(b, &decoded); err != nil {
+			t.Fatalf("Could not unmarshal host from %s due to: %v", c.Ext(), err)
+		}
+		// fields filled in by encoding are compared as well
+		again, err := c.Marshal(decoded)
+		if err != nil {
+			t.Fatalf("Could not marshal decoded host as %s due to: %v", c.Ext(), err)
+		}
+		if !bytes.Equal(again, b) {
+			t.Errorf("Host did not survive %s round trip, encoded as:\n%s\nthen as:\n%s", c.Ext(), b, again)
+		}
+	}
+}
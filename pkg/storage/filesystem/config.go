@@ -0,0 +1,95 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystem
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	zap "go.uber.org/zap"
+)
+
+const (
+	defaultFormat      = "yaml"
+	defaultLockTimeout = 3 * time.Second
+)
+
+// Filesystem persists every entity as a single YAML or JSON file under the
+// given directory, so that the directory can be kept in a git repository and
+// changes of hosts can be reviewed as ordinary file changes.
+type Filesystem struct {
+	// Directory is the root directory of storage, which will be created if
+	// absent.
+	Directory string `json:"directory,omitempty" yaml:"directory,omitempty"`
+	// Format is the file format of entities, either "yaml" or "json".
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// LockTimeout is the amount of time to wait for the lock file held by
+	// another writer, e.g. "3s".
+	LockTimeout string `json:"lock_timeout,omitempty" yaml:"lock_timeout,omitempty"`
+	// TrashRetention is the period a trashed host is kept before it is
+	// purged, e.g. "720h".
+	TrashRetention string `json:"trash_retention,omitempty" yaml:"trash_retention,omitempty"`
+}
+
+func (in *Filesystem) Open(logger *zap.SugaredLogger) (core.Storage, error) {
+	if in.Directory == "" {
+		return nil, fmt.Errorf("Directory of filesystem storage was not specified")
+	}
+	format := in.Format
+	if format == "" {
+		format = defaultFormat
+	}
+	var codec codec
+	switch format {
+	case "yaml", "yml":
+		codec = yamlCodec{}
+	case "json":
+		codec = jsonCodec{}
+	default:
+		return nil, fmt.Errorf("Unsupported file format: %s", format)
+	}
+	timeout := defaultLockTimeout
+	if in.LockTimeout != "" {
+		d, err := time.ParseDuration(in.LockTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid lock timeout '%s': %v", in.LockTimeout, err)
+		}
+		timeout = d
+	}
+	trashRetention, err := core.ParseTrashRetention(in.TrashRetention)
+	if err != nil {
+		return nil, err
+	}
+	for _, kind := range []string{hostPrefix, trashPrefix, profilePrefix} {
+		if err := os.MkdirAll(filepath.Join(in.Directory, kind), 0755); err != nil {
+			return nil, err
+		}
+	}
+	c := &conn{
+		dir:            in.Directory,
+		codec:          codec,
+		lockTimeout:    timeout,
+		trashRetention: trashRetention,
+		logger:         logger,
+	}
+	return c, nil
+}
+
+func New() *Filesystem {
+	return &Filesystem{}
+}
@@ -0,0 +1,36 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	storagetest "github.com/universonic/ivy-utils/pkg/storage/storagetest"
+	zap "go.uber.org/zap"
+)
+
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ivy-filesystem-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	suite := &storagetest.Suite{
+		Open: func(t *testing.T) core.Storage {
+			adapter := New()
+			adapter.Directory = filepath.Join(dir, strings.Replace(t.Name(), "/", "_", -1))
+			storage, err := adapter.Open(zap.NewNop().Sugar())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return storage
+		},
+		// every write rewrites a file under the lock file, which makes
+		// creating the default number of hosts slow
+		LargeListSize: 150,
+	}
+	suite.Run(t)
+}
@@ -0,0 +1,310 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	zap "go.uber.org/zap"
+)
+
+const (
+	// hostPrefix is the sub-directory which holds host entities, where each
+	// host is saved as '<hostname>.<ext>'.
+	hostPrefix = "hosts"
+)
+
+type conn struct {
+	// mu serializes writers of the same process, while lock file takes care
+	// of writers from other processes.
+	mu             sync.Mutex
+	dir            string
+	codec          codec
+	lockTimeout    time.Duration
+	trashRetention time.Duration
+	logger         *zap.SugaredLogger
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) CreateHost(ctx context.Context, host core.Host) error {
+	if _, err := uuid.FromString(host.GUID); err != nil {
+		host.GUID = uuid.NewV4().String()
+	}
+	host.UpdatedAt = time.Now().UTC()
+	// NOTE: we are currently using hostname as host's primary unique identifier
+	return c.createFile(ctx, hostPrefix, host.Hostname, host, func() error {
+		return c.checkUniqueIndexes(ctx, host)
+	})
+}
+
+func (c *conn) GetHost(ctx context.Context, id string) (host core.Host, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if err = c.readFile(hostPrefix, id, &host); err != nil {
+		return
+	}
+	return host, nil
+}
+
+func (c *conn) UpdateHost(ctx context.Context, id string, updater func(host core.Host) (core.Host, error)) error {
+	return c.updateFile(ctx, hostPrefix, id, func(exists bool) (interface{}, error) {
+		current := core.NewHost()
+		if exists {
+			if err := c.readFile(hostPrefix, id, current); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := updater(*current)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := uuid.FromString(updated.GUID); err != nil {
+			updated.GUID = uuid.NewV4().String()
+		}
+		updated.UpdatedAt = time.Now().UTC()
+		updated.Hostname = id
+		if err := c.checkUniqueIndexes(ctx, updated); err != nil {
+			return nil, err
+		}
+		return updated, nil
+	})
+}
+
+func (c *conn) DeleteHost(ctx context.Context, id string) error {
+	return c.deleteFile(ctx, hostPrefix, id)
+}
+
+func (c *conn) ListHost(ctx context.Context) (hosts []core.Host, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	ids, err := c.listIDs(hostPrefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		var host core.Host
+		if err = c.readFile(hostPrefix, id, &host); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// listIDs returns identifiers of all entities of given kind in lexical order,
+// skipping hidden and temporary files.
+func (c *conn) listIDs(kind string) (ids []string, err error) {
+	files, err := ioutil.ReadDir(filepath.Join(c.dir, kind))
+	if err != nil {
+		return nil, err
+	}
+	for _, each := range files {
+		name := each.Name()
+		if each.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != c.codec.Ext() {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, c.codec.Ext()))
+	}
+	// Files are sorted by their names including the extension, which does
+	// not always agree with the order of identifiers.
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (c *conn) path(kind, id string) (string, error) {
+	if id == "" || strings.HasPrefix(id, ".") || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("Invalid identifier '%s' for filesystem storage", id)
+	}
+	return filepath.Join(c.dir, kind, id+c.codec.Ext()), nil
+}
+
+// createFile writes value if no entity of id exists, and validate is invoked
+// with the lock held right before writing, unless it is nil.
+func (c *conn) createFile(ctx context.Context, kind, id string, value interface{}, validate func() error) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during creating data entity '%s/%s' due to: %v", kind, id, err)
+		} else {
+			c.logger.Debugf("Created file '%s/%s': %v", kind, id, value)
+		}
+	}()
+	var fp string
+	fp, err = c.path(kind, id)
+	if err != nil {
+		return err
+	}
+	var release func()
+	release, err = c.acquireLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if _, err = os.Stat(fp); err == nil {
+		return core.ErrResourceAlreadyExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if validate != nil {
+		if err = validate(); err != nil {
+			return err
+		}
+	}
+	return c.writeFile(fp, value)
+}
+
+func (c *conn) readFile(kind, id string, value interface{}) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during reading data entity '%s/%s' due to: %v", kind, id, err)
+		} else {
+			c.logger.Debugf("Retrieved file '%s/%s': %v", kind, id, value)
+		}
+	}()
+	var fp string
+	fp, err = c.path(kind, id)
+	if err != nil {
+		return err
+	}
+	var b []byte
+	b, err = ioutil.ReadFile(fp)
+	if os.IsNotExist(err) {
+		return core.ErrResourceNotFound
+	} else if err != nil {
+		return err
+	}
+	return c.codec.Unmarshal(b, value)
+}
+
+// updateFile holds the lock during the whole read-modify-write cycle, so that
+// concurrent updates from other processes could never clobber each other.
+func (c *conn) updateFile(ctx context.Context, kind, id string, update func(exists bool) (interface{}, error)) (err error) {
+	var updatedValue interface{}
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during updating data entity '%s/%s' due to: %v", kind, id, err)
+		} else {
+			c.logger.Debugf("Updated file '%s/%s': %v", kind, id, updatedValue)
+		}
+	}()
+	var fp string
+	fp, err = c.path(kind, id)
+	if err != nil {
+		return err
+	}
+	var release func()
+	release, err = c.acquireLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	exists := true
+	if _, err = os.Stat(fp); os.IsNotExist(err) {
+		exists = false
+	} else if err != nil {
+		return err
+	}
+	updatedValue, err = update(exists)
+	if err != nil {
+		return err
+	}
+	return c.writeFile(fp, updatedValue)
+}
+
+func (c *conn) deleteFile(ctx context.Context, kind, id string) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during deleting data entity '%s/%s' due to: %v", kind, id, err)
+		} else {
+			c.logger.Debugf("Deleted file '%s/%s'", kind, id)
+		}
+	}()
+	var fp string
+	fp, err = c.path(kind, id)
+	if err != nil {
+		return err
+	}
+	var release func()
+	release, err = c.acquireLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	err = os.Remove(fp)
+	if os.IsNotExist(err) {
+		return core.ErrResourceNotFound
+	}
+	return err
+}
+
+// writeFile writes into a temporary file beside the target and renames it
+// afterwards, so that readers will never see a partially written file.
+func (c *conn) writeFile(fp string, value interface{}) error {
+	b, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(fp), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fp)
+}
+
+func (c *conn) WatchHosts(ctx context.Context, fromRevision int64) (<-chan core.HostEvent, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) HostHistory(ctx context.Context, id string) ([]core.HostRevision, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) ListHostAt(ctx context.Context, revision int64) ([]core.Host, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) LockHost(ctx context.Context, lock core.HostLock, ttl time.Duration) (func() error, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) ListHostLocks(ctx context.Context) ([]core.HostLock, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) ForceUnlockHost(ctx context.Context, id string) error {
+	return core.ErrNotSupported
+}
@@ -0,0 +1,41 @@
+package filesystem
+
+import (
+	"context"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// Files could be edited by hand, so no index is kept aside. Instead, hosts
+// are scanned on lookups and on writes of unique fields, which is fine for a
+// fleet whose definitions are reviewed as files.
+
+func (c *conn) GetHostBy(ctx context.Context, field core.IndexField, value string) (host core.Host, err error) {
+	hosts, err := c.ListHost(ctx)
+	if err != nil {
+		return host, err
+	}
+	selected := core.SelectHostsBy(hosts, field, value)
+	switch len(selected) {
+	case 0:
+		return host, core.ErrResourceNotFound
+	case 1:
+		return selected[0], nil
+	}
+	return host, core.ErrAmbiguousIndex
+}
+
+// checkUniqueIndexes returns core.ErrIndexConflict if a unique field of host
+// is taken by another host. Caller must hold the lock.
+func (c *conn) checkUniqueIndexes(ctx context.Context, host core.Host) error {
+	hosts, err := c.ListHost(ctx)
+	if err != nil {
+		return err
+	}
+	return core.CheckUniqueIndexes(hosts, host)
+}
+
+// BuildIndexes does nothing, as no index is kept.
+func (c *conn) BuildIndexes(ctx context.Context) error {
+	return ctx.Err()
+}
@@ -0,0 +1,57 @@
+package filesystem
+
+import (
+	"context"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// ListHosts reads files after the last returned hostname in lexical order,
+// and stops as soon as the page is full. Revisions are not tracked by
+// filesystem storage, thus each page is read from the latest state.
+func (c *conn) ListHosts(ctx context.Context, opts core.ListOptions) (page core.HostPage, err error) {
+	if opts.Revision != 0 {
+		return page, core.ErrNotSupported
+	}
+	var after string
+	if opts.Continue != "" {
+		if _, after, err = core.DecodeContinue(opts.Continue); err != nil {
+			return page, err
+		}
+	}
+	ids, err := c.listIDs(hostPrefix)
+	if err != nil {
+		return page, err
+	}
+	for i, id := range ids {
+		if id <= after {
+			continue
+		}
+		if err = ctx.Err(); err != nil {
+			return core.HostPage{}, err
+		}
+		var host core.Host
+		if err = c.readFile(hostPrefix, id, &host); err == core.ErrResourceNotFound {
+			// removed since the directory was read
+			continue
+		} else if err != nil {
+			return core.HostPage{}, err
+		}
+		var ok bool
+		ok, err = core.MatchHost(host, opts.Predicates)
+		if err != nil {
+			return core.HostPage{}, err
+		}
+		if !ok {
+			continue
+		}
+		page.Hosts = append(page.Hosts, host)
+		if opts.Limit > 0 && int64(len(page.Hosts)) >= opts.Limit {
+			if i+1 < len(ids) {
+				page.Continue = core.EncodeContinue(0, id)
+			}
+			break
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,67 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	lockFileName      = ".lock"
+	lockRetryInterval = 50 * time.Millisecond
+)
+
+// acquireLock creates the lock file exclusively, which works across
+// processes and machines sharing the same checkout. It retries until the
+// timeout is reached or ctx is done, and returns a function to release the
+// lock.
+func (c *conn) acquireLock(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	lockFile := filepath.Join(c.dir, lockFileName)
+	deadline := time.Now().Add(c.lockTimeout)
+	for {
+		fi, err := os.OpenFile(lockFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fi.WriteString(strconv.Itoa(os.Getpid()))
+			fi.Close()
+			return func() {
+				os.Remove(lockFile)
+				c.mu.Unlock()
+			}, nil
+		}
+		if !os.IsExist(err) {
+			c.mu.Unlock()
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			c.mu.Unlock()
+			return nil, fmt.Errorf("Could not acquire lock file '%s' within %v, remove it manually if no other writer is running", lockFile, c.lockTimeout)
+		}
+		select {
+		case <-ctx.Done():
+			c.mu.Unlock()
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
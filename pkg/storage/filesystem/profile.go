@@ -0,0 +1,72 @@
+package filesystem
+
+import (
+	"context"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	// profilePrefix is the sub-directory which holds credential profiles,
+	// where each profile is saved as '<name>.<ext>'.
+	profilePrefix = "profiles"
+)
+
+func (c *conn) CreateCredentialProfile(ctx context.Context, profile core.CredentialProfile) error {
+	profile.UpdatedAt = time.Now().UTC()
+	return c.createFile(ctx, profilePrefix, profile.Name, profile, nil)
+}
+
+func (c *conn) GetCredentialProfile(ctx context.Context, name string) (profile core.CredentialProfile, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if err = c.readFile(profilePrefix, name, &profile); err != nil {
+		return
+	}
+	return profile, nil
+}
+
+func (c *conn) ListCredentialProfiles(ctx context.Context) (profiles []core.CredentialProfile, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	names, err := c.listIDs(profilePrefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		var profile core.CredentialProfile
+		if err = c.readFile(profilePrefix, name, &profile); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+func (c *conn) UpdateCredentialProfile(ctx context.Context, name string, updater func(profile core.CredentialProfile) (core.CredentialProfile, error)) error {
+	return c.updateFile(ctx, profilePrefix, name, func(exists bool) (interface{}, error) {
+		var current core.CredentialProfile
+		if exists {
+			if err := c.readFile(profilePrefix, name, &current); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := updater(current)
+		if err != nil {
+			return nil, err
+		}
+		updated.UpdatedAt = time.Now().UTC()
+		updated.Name = name
+		return updated, nil
+	})
+}
+
+func (c *conn) DeleteCredentialProfile(ctx context.Context, name string) error {
+	return c.deleteFile(ctx, profilePrefix, name)
+}
@@ -0,0 +1,54 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// RenameHost writes the new file before removing the old one, so that the
+// host is never lost even if we were interrupted in between.
+func (c *conn) RenameHost(ctx context.Context, id, newID string) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during renaming data entity '%s/%s' to '%s/%s' due to: %v", hostPrefix, id, hostPrefix, newID, err)
+		} else {
+			c.logger.Debugf("Moved file '%s/%s' to '%s/%s'", hostPrefix, id, hostPrefix, newID)
+		}
+	}()
+	if newID == "" {
+		return fmt.Errorf("New hostname must not be empty")
+	}
+	var fp, newFp string
+	if fp, err = c.path(hostPrefix, id); err != nil {
+		return err
+	}
+	if newFp, err = c.path(hostPrefix, newID); err != nil {
+		return err
+	}
+	var release func()
+	release, err = c.acquireLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	var host core.Host
+	if err = c.readFile(hostPrefix, id, &host); err != nil {
+		return err
+	}
+	if _, err = os.Stat(newFp); err == nil {
+		return core.ErrResourceAlreadyExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	host.Hostname = newID
+	host.UpdatedAt = time.Now().UTC()
+	if err = c.writeFile(newFp, host); err != nil {
+		return err
+	}
+	return os.Remove(fp)
+}
@@ -0,0 +1,152 @@
+package filesystem
+
+import (
+	"context"
+	"os"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	// trashPrefix is the sub-directory which holds trashed hosts, where each
+	// one is saved as '<hostname>.<ext>'.
+	trashPrefix = "trash"
+)
+
+func (c *conn) TrashHost(ctx context.Context, id, reason string) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during trashing data entity '%s/%s' due to: %v", hostPrefix, id, err)
+		} else {
+			c.logger.Debugf("Moved file '%s/%s' to '%s/%s'", hostPrefix, id, trashPrefix, id)
+		}
+	}()
+	var fp, trashFp string
+	if fp, err = c.path(hostPrefix, id); err != nil {
+		return err
+	}
+	if trashFp, err = c.path(trashPrefix, id); err != nil {
+		return err
+	}
+	var release func()
+	release, err = c.acquireLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err = c.purgeTrash(); err != nil {
+		return err
+	}
+	trashed := core.TrashedHost{
+		DeletedAt: time.Now().UTC(),
+		Reason:    reason,
+	}
+	if err = c.readFile(hostPrefix, id, &trashed.Host); err != nil {
+		return err
+	}
+	// The trash file is written first, so that the host is never lost even if
+	// we were interrupted in between.
+	if err = c.writeFile(trashFp, trashed); err != nil {
+		return err
+	}
+	return os.Remove(fp)
+}
+
+func (c *conn) ListTrash(ctx context.Context) (trashed []core.TrashedHost, err error) {
+	var release func()
+	release, err = c.acquireLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err = c.purgeTrash(); err != nil {
+		return nil, err
+	}
+	return c.listTrash()
+}
+
+func (c *conn) RestoreHost(ctx context.Context, id string) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during restoring data entity '%s/%s' due to: %v", hostPrefix, id, err)
+		} else {
+			c.logger.Debugf("Moved file '%s/%s' to '%s/%s'", trashPrefix, id, hostPrefix, id)
+		}
+	}()
+	var fp, trashFp string
+	if fp, err = c.path(hostPrefix, id); err != nil {
+		return err
+	}
+	if trashFp, err = c.path(trashPrefix, id); err != nil {
+		return err
+	}
+	var release func()
+	release, err = c.acquireLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err = c.purgeTrash(); err != nil {
+		return err
+	}
+	var trashed core.TrashedHost
+	if err = c.readFile(trashPrefix, id, &trashed); err != nil {
+		return err
+	}
+	if _, err = os.Stat(fp); err == nil {
+		return core.ErrResourceAlreadyExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	host := trashed.Host
+	host.UpdatedAt = time.Now().UTC()
+	if err = c.checkUniqueIndexes(ctx, host); err != nil {
+		return err
+	}
+	if err = c.writeFile(fp, host); err != nil {
+		return err
+	}
+	return os.Remove(trashFp)
+}
+
+// purgeTrash removes trashed hosts which have exceeded the retention period.
+// Caller must hold the lock.
+func (c *conn) purgeTrash() error {
+	trashed, err := c.listTrash()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, each := range trashed {
+		if !each.Expired(c.trashRetention, now) {
+			continue
+		}
+		fp, err := c.path(trashPrefix, each.Host.Hostname)
+		if err != nil {
+			return err
+		}
+		if err = os.Remove(fp); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		c.logger.Debugf("Purged expired file '%s/%s'", trashPrefix, each.Host.Hostname)
+	}
+	return nil
+}
+
+func (c *conn) listTrash() (trashed []core.TrashedHost, err error) {
+	ids, err := c.listIDs(trashPrefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		var each core.TrashedHost
+		if err = c.readFile(trashPrefix, id, &each); err != nil {
+			return nil, err
+		}
+		trashed = append(trashed, each)
+	}
+	return trashed, nil
+}
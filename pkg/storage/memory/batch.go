@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// ApplyBatch plans the batch and commits its changes with the write lock
+// held, thus watchers never see a partially applied batch.
+func (c *conn) ApplyBatch(ctx context.Context, ops []core.BatchOp) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during applying batch of %d operations due to: %v", len(ops), err)
+		} else {
+			c.logger.Debugf("Applied batch of %d operations", len(ops))
+		}
+	}()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current := func(hostname string) (*core.Host, error) {
+		key := canonicalID(hostPrefix, hostname)
+		b, ok := c.data[key]
+		if !ok {
+			return nil, nil
+		}
+		host := &core.Host{Revision: c.modRevs[key]}
+		return host, json.Unmarshal(b, host)
+	}
+	owners := func(entry core.IndexEntry) ([]string, error) {
+		if owner, ok := c.index[entry.Key()]; ok {
+			return []string{owner}, nil
+		}
+		return nil, nil
+	}
+	changes, err := core.PlanBatch(ops, current, owners)
+	if err != nil {
+		return err
+	}
+	type write struct {
+		key            string
+		current, value []byte
+	}
+	writes := make([]write, len(changes))
+	for i, change := range changes {
+		key := canonicalID(hostPrefix, ops[change.Index].Host.Hostname)
+		writes[i] = write{key: key, current: c.data[key]}
+		if change.Updated != nil {
+			if writes[i].value, err = json.Marshal(change.Updated); err != nil {
+				return err
+			}
+		}
+	}
+	// Old entries are all released before new ones are claimed, which never
+	// conflicts as the plan has been checked.
+	for _, each := range writes {
+		if err = c.updateHostIndexes(each.current, nil); err != nil {
+			return err
+		}
+	}
+	for _, each := range writes {
+		if err = c.updateHostIndexes(nil, each.value); err != nil {
+			return err
+		}
+		c.commit(each.key, each.value)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package memory
+
+import (
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	zap "go.uber.org/zap"
+)
+
+// Memory is an in-process storage which keeps all data in memory. It is
+// mainly intended for testing and demonstration, as nothing will be persisted
+// once the storage is closed.
+type Memory struct {
+	// TrashRetention is the period a trashed host is kept before it is
+	// purged, e.g. "720h".
+	TrashRetention string `json:"trash_retention,omitempty" yaml:"trash_retention,omitempty"`
+}
+
+func (in *Memory) Open(logger *zap.SugaredLogger) (core.Storage, error) {
+	trashRetention, err := core.ParseTrashRetention(in.TrashRetention)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{
+		trashRetention: trashRetention,
+		data:           make(map[string][]byte),
+		modRevs:        make(map[string]int64),
+		index:          make(map[string]string),
+		locks:          make(map[string]*heldLock),
+		notify:         make(chan struct{}),
+		closed:         make(chan struct{}),
+		logger:         logger,
+	}
+	return c, nil
+}
+
+func New() *Memory {
+	return &Memory{}
+}
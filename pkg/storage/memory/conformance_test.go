@@ -0,0 +1,19 @@
+package memory
+
+import (
+	"testing"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	storagetest "github.com/universonic/ivy-utils/pkg/storage/storagetest"
+	zap "go.uber.org/zap"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) core.Storage {
+		storage, err := New().Open(zap.NewNop().Sugar())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return storage
+	})
+}
@@ -0,0 +1,271 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	zap "go.uber.org/zap"
+)
+
+const (
+	hostPrefix = "host"
+)
+
+// conn keeps every entity as its serialized form, so that callers can never
+// share or mutate stored data, which is the same as what etcd does. Like etcd,
+// every change bumps the revision and is appended to the change log, which is
+// never compacted.
+type conn struct {
+	mu  sync.RWMutex
+	rev int64
+	// data holds the current value of each key.
+	data map[string][]byte
+	// modRevs holds the revision at which each key of data was last changed.
+	modRevs map[string]int64
+	// index holds secondary index keys of hosts. They are derived from data,
+	// thus kept out of the change log to leave revisions untouched.
+	index map[string]string
+	// changes holds all changes in order, where changes[i] was made at
+	// revision i+1.
+	changes []change
+	// notify is closed and replaced whenever a change was made.
+	notify chan struct{}
+	closed chan struct{}
+	// locks holds host locks, which are only shared within the process.
+	lockMu         sync.Mutex
+	locks          map[string]*heldLock
+	trashRetention time.Duration
+	logger         *zap.SugaredLogger
+}
+
+type change struct {
+	key   string
+	value []byte
+	prev  []byte
+	// prevRev is the revision at which prev was written.
+	prevRev int64
+}
+
+func (c *conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.closed:
+	default:
+		close(c.closed)
+	}
+	c.data = make(map[string][]byte)
+	c.modRevs = make(map[string]int64)
+	c.index = make(map[string]string)
+	return nil
+}
+
+func (c *conn) CreateHost(ctx context.Context, host core.Host) error {
+	if _, err := uuid.FromString(host.GUID); err != nil {
+		host.GUID = uuid.NewV4().String()
+	}
+	host.UpdatedAt = time.Now().UTC()
+	// NOTE: we are currently using hostname as host's primary unique identifier
+	return c.createKey(ctx, canonicalID(hostPrefix, host.Hostname), host, c.updateHostIndexes)
+}
+
+func (c *conn) GetHost(ctx context.Context, id string) (host core.Host, err error) {
+	if host.Revision, err = c.getKey(ctx, canonicalID(hostPrefix, id), &host); err != nil {
+		return
+	}
+	return host, nil
+}
+
+func (c *conn) UpdateHost(ctx context.Context, id string, updater func(host core.Host) (core.Host, error)) error {
+	return c.updateKey(ctx, canonicalID(hostPrefix, id), func(currentValue []byte, revision int64) ([]byte, error) {
+		current := core.NewHost()
+		if len(currentValue) > 0 {
+			if err := json.Unmarshal(currentValue, current); err != nil {
+				return nil, err
+			}
+			current.Revision = revision
+		}
+		updated, err := updater(*current)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := uuid.FromString(updated.GUID); err != nil {
+			updated.GUID = uuid.NewV4().String()
+		}
+		updated.UpdatedAt = time.Now().UTC()
+		// hostname is the key of host, which is also referred by indexes
+		updated.Hostname = id
+		return json.Marshal(updated)
+	}, c.updateHostIndexes)
+}
+
+func (c *conn) DeleteHost(ctx context.Context, id string) error {
+	return c.deleteKey(ctx, canonicalID(hostPrefix, id), c.updateHostIndexes)
+}
+
+func (c *conn) ListHost(ctx context.Context) (hosts []core.Host, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, key := range c.sortedKeys(hostPrefix) {
+		var host core.Host
+		if err = json.Unmarshal(c.data[key], &host); err != nil {
+			return nil, err
+		}
+		host.Revision = c.modRevs[key]
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+func (c *conn) createKey(ctx context.Context, key string, value interface{}, index indexer) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during creating data entity '%s' due to: %v", key, err)
+		} else {
+			c.logger.Debugf("Created key '%s': %v", key, value)
+		}
+	}()
+	var b []byte
+	b, err = json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.data[key]; ok {
+		return core.ErrResourceAlreadyExists
+	}
+	if index != nil {
+		if err = index(nil, b); err != nil {
+			return err
+		}
+	}
+	c.commit(key, b)
+	return nil
+}
+
+// getKey decodes the value of key into value, and returns the revision at
+// which key was last changed.
+func (c *conn) getKey(ctx context.Context, key string, value interface{}) (revision int64, err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during getting data entity '%s' due to: %v", key, err)
+		} else {
+			c.logger.Debugf("Retrieved key '%s': %v", key, value)
+		}
+	}()
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
+	c.mu.RLock()
+	b, ok := c.data[key]
+	revision = c.modRevs[key]
+	c.mu.RUnlock()
+	if !ok {
+		return 0, core.ErrResourceNotFound
+	}
+	return revision, json.Unmarshal(b, value)
+}
+
+// updateKey holds the write lock during the whole read-modify-write cycle, so
+// unlike etcd, concurrent updates are serialized instead of being rejected.
+func (c *conn) updateKey(ctx context.Context, key string, update func(current []byte, revision int64) ([]byte, error), index indexer) (err error) {
+	var updatedValue []byte
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during updating data entity '%s' due to: %v", key, err)
+		} else {
+			c.logger.Debugf("Updated key '%s': %s", key, updatedValue)
+		}
+	}()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	updatedValue, err = update(c.data[key], c.modRevs[key])
+	if err != nil {
+		return err
+	}
+	if index != nil {
+		if err = index(c.data[key], updatedValue); err != nil {
+			return err
+		}
+	}
+	c.commit(key, updatedValue)
+	return nil
+}
+
+func (c *conn) deleteKey(ctx context.Context, key string, index indexer) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during deleting data entity '%s' due to: %v", key, err)
+		} else {
+			c.logger.Debugf("Deleted key '%s'", key)
+		}
+	}()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current, ok := c.data[key]
+	if !ok {
+		return core.ErrResourceNotFound
+	}
+	if index != nil {
+		if err = index(current, nil); err != nil {
+			return err
+		}
+	}
+	c.commit(key, nil)
+	return nil
+}
+
+// commit applies a change of key and notifies all watchers, a nil value
+// means deletion. Caller must hold the write lock.
+func (c *conn) commit(key string, value []byte) {
+	c.rev++
+	c.changes = append(c.changes, change{key: key, value: value, prev: c.data[key], prevRev: c.modRevs[key]})
+	if value == nil {
+		delete(c.data, key)
+		delete(c.modRevs, key)
+	} else {
+		c.data[key] = value
+		c.modRevs[key] = c.rev
+	}
+	close(c.notify)
+	c.notify = make(chan struct{})
+}
+
+// sortedKeys returns all keys with given prefix in lexical order, which is
+// the same order as an etcd range request. Caller must hold the lock.
+func (c *conn) sortedKeys(prefix string) []string {
+	var keys []string
+	for key := range c.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func canonicalID(prefix, id string) string { return filepath.Join(prefix, id) }
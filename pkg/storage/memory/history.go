@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// HostHistory returns all versions of the host since it was created, as the
+// change log of memory storage is never compacted. Renames are followed, so
+// versions under previous hostnames are returned as well.
+func (c *conn) HostHistory(ctx context.Context, id string) (history []core.HostRevision, err error) {
+	key := canonicalID(hostPrefix, id)
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if _, ok := c.data[key]; !ok {
+		return nil, core.ErrResourceNotFound
+	}
+	for i := len(c.changes) - 1; i >= 0; i-- {
+		each := c.changes[i]
+		// the deletion of a renamed key is skipped as well
+		if each.key != key || each.value == nil {
+			continue
+		}
+		rev := core.HostRevision{Revision: int64(i + 1)}
+		if err = json.Unmarshal(each.value, &rev.Host); err != nil {
+			return nil, err
+		}
+		rev.Host.Revision = rev.Revision
+		history = append(history, rev)
+		if each.prev != nil {
+			continue
+		}
+		previous, err := c.renamedFrom(i, rev.Host)
+		if err != nil {
+			return nil, err
+		}
+		if previous == "" {
+			break
+		}
+		key = previous
+	}
+	return history, nil
+}
+
+// renamedFrom returns the previous key if the host created by the i-th change
+// was renamed from another one, or an empty string otherwise. Caller must
+// hold the lock.
+func (c *conn) renamedFrom(i int, host core.Host) (string, error) {
+	if i == 0 || host.GUID == "" {
+		return "", nil
+	}
+	deleted := c.changes[i-1]
+	if deleted.value != nil || deleted.prev == nil || !strings.HasPrefix(deleted.key, hostPrefix+"/") {
+		return "", nil
+	}
+	var previous core.Host
+	if err := json.Unmarshal(deleted.prev, &previous); err != nil {
+		return "", err
+	}
+	if previous.GUID != host.GUID {
+		return "", nil
+	}
+	return deleted.key, nil
+}
+
+func (c *conn) ListHostAt(ctx context.Context, revision int64) (hosts []core.Host, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if revision > c.rev {
+		return nil, fmt.Errorf("Required revision %d is a future revision", revision)
+	} else if revision <= 0 {
+		revision = c.rev
+	}
+	values := make(map[string][]byte)
+	modRevs := make(map[string]int64)
+	for i, each := range c.changes[:revision] {
+		if !strings.HasPrefix(each.key, hostPrefix+"/") {
+			continue
+		}
+		if each.value == nil {
+			delete(values, each.key)
+		} else {
+			values[each.key] = each.value
+			modRevs[each.key] = int64(i + 1)
+		}
+	}
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var host core.Host
+		if err = json.Unmarshal(values[key], &host); err != nil {
+			return nil, err
+		}
+		host.Revision = modRevs[key]
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
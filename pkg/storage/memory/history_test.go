@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+func TestHostHistory(t *testing.T) {
+	ctx := context.Background()
+	storage := openMemory(t, New())
+	defer storage.Close()
+	createHost(t, storage, "node-01")
+	// versions before the host was deleted are not its history
+	if err := storage.DeleteHost(ctx, "node-01"); err != nil {
+		t.Fatal(err)
+	}
+	createHost(t, storage, "node-01")
+	err := storage.UpdateHost(ctx, "node-01", func(host core.Host) (core.Host, error) {
+		host.SSHUser = "ops"
+		host.ExtraInfo = core.ExtendableFields{"rack": "a1"}
+		return host, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.RenameHost(ctx, "node-01", "node-11"); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := storage.HostHistory(ctx, "node-11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 versions, got %d: %+v", len(history), history)
+	}
+	for i, want := range []string{"node-11", "node-01", "node-01"} {
+		if history[i].Host.Hostname != want {
+			t.Errorf("Expected version %d of host '%s', got '%s'", i, want, history[i].Host.Hostname)
+		}
+		if history[i].Host.Revision != history[i].Revision {
+			t.Errorf("Expected version %d at revision %d, got revision %d", i, history[i].Revision, history[i].Host.Revision)
+		}
+		if i > 0 && history[i].Revision >= history[i-1].Revision {
+			t.Errorf("Expected versions from newest to oldest, got revision %d after %d", history[i].Revision, history[i-1].Revision)
+		}
+	}
+
+	changes, err := core.DiffHost(history[2].Host, history[1].Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []core.FieldChange{
+		{Field: "extra_info.rack", New: "a1"},
+		{Field: "ssh_user", New: "ops"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Expected changes %+v, got %+v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("Expected change %+v, got %+v", want[i], changes[i])
+		}
+	}
+
+	if _, err = storage.HostHistory(ctx, "node-01"); err != core.ErrResourceNotFound {
+		t.Errorf("Expected ErrResourceNotFound for renamed host, got: %v", err)
+	}
+}
+
+func TestListHostAt(t *testing.T) {
+	ctx := context.Background()
+	storage := openMemory(t, New())
+	defer storage.Close()
+	createHost(t, storage, "node-01")
+	createHost(t, storage, "node-02")
+	before, err := storage.GetHost(ctx, "node-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.UpdateHost(ctx, "node-01", func(host core.Host) (core.Host, error) {
+		host.SSHUser = "ops"
+		return host, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.DeleteHost(ctx, "node-02"); err != nil {
+		t.Fatal(err)
+	}
+	createHost(t, storage, "node-03")
+
+	hosts, err := storage.ListHostAt(ctx, before.Revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 || hosts[0].Hostname != "node-01" || hosts[1].Hostname != "node-02" {
+		t.Fatalf("Expected 'node-01' and 'node-02' at revision %d, got: %+v", before.Revision, hosts)
+	}
+	if hosts[0].SSHUser != "" || hosts[0].Revision >= before.Revision {
+		t.Errorf("Expected 'node-01' as created, got: %+v", hosts[0])
+	}
+
+	current, err := storage.ListHostAt(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(current) != 2 || current[0].SSHUser != "ops" || current[1].Hostname != "node-03" {
+		t.Errorf("Expected current hosts 'node-01' and 'node-03', got: %+v", current)
+	}
+
+	last, err := storage.GetHost(ctx, "node-03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = storage.ListHostAt(ctx, last.Revision+1); err == nil {
+		t.Error("Expected listing at a future revision to fail")
+	}
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// indexer maintains secondary indexes while a key changes from current to
+// updated value, where nil means the key is absent. It is invoked with the
+// write lock held and before the key itself is committed, so that a conflict
+// leaves everything untouched.
+type indexer func(current, updated []byte) error
+
+// updateHostIndexes is the indexer of host keys.
+func (c *conn) updateHostIndexes(current, updated []byte) error {
+	var oldHost, newHost *core.Host
+	if len(current) > 0 {
+		oldHost = new(core.Host)
+		if err := json.Unmarshal(current, oldHost); err != nil {
+			return err
+		}
+	}
+	if len(updated) > 0 {
+		newHost = new(core.Host)
+		if err := json.Unmarshal(updated, newHost); err != nil {
+			return err
+		}
+	}
+	removed, added := core.DiffIndexEntries(oldHost, newHost)
+	for _, each := range added {
+		if !each.Field.Unique() {
+			continue
+		}
+		owner, ok := c.index[each.Key()]
+		if ok && owner != each.Hostname && (oldHost == nil || owner != oldHost.Hostname) {
+			return core.ErrIndexConflict
+		}
+	}
+	for _, each := range removed {
+		delete(c.index, each.Key())
+	}
+	for _, each := range added {
+		var value string
+		if each.Field.Unique() {
+			value = each.Hostname
+		}
+		c.index[each.Key()] = value
+	}
+	return nil
+}
+
+func (c *conn) GetHostBy(ctx context.Context, field core.IndexField, value string) (host core.Host, err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during looking up data entity by '%s=%s' due to: %v", field, value, err)
+		} else {
+			c.logger.Debugf("Retrieved host '%s' by '%s=%s'", host.Hostname, field, value)
+		}
+	}()
+	if value == "" {
+		return host, core.ErrResourceNotFound
+	}
+	if err = ctx.Err(); err != nil {
+		return host, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var hostname string
+	if field.Unique() {
+		var ok bool
+		if hostname, ok = c.index[core.IndexEntry{Field: field, Value: value}.Key()]; !ok {
+			return host, core.ErrResourceNotFound
+		}
+	} else {
+		prefix := core.ValuePrefix(field, value)
+		var keys []string
+		for key := range c.index {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		switch len(keys) {
+		case 0:
+			return host, core.ErrResourceNotFound
+		case 1:
+		default:
+			return host, core.ErrAmbiguousIndex
+		}
+		if hostname, err = url.PathUnescape(strings.TrimPrefix(keys[0], prefix)); err != nil {
+			return host, err
+		}
+	}
+	key := canonicalID(hostPrefix, hostname)
+	b, ok := c.data[key]
+	if !ok {
+		return host, core.ErrResourceNotFound
+	}
+	err = json.Unmarshal(b, &host)
+	host.Revision = c.modRevs[key]
+	return host, err
+}
+
+// BuildIndexes does nothing, as indexes are kept along with hosts since the
+// storage is created.
+func (c *conn) BuildIndexes(ctx context.Context) error {
+	return ctx.Err()
+}
@@ -0,0 +1,31 @@
+package memory
+
+import (
+	"context"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// ListHosts replays the change log up to the revision of listing, so that all
+// pages are consistent with each other.
+func (c *conn) ListHosts(ctx context.Context, opts core.ListOptions) (page core.HostPage, err error) {
+	revision := opts.Revision
+	if opts.Continue != "" {
+		if revision, _, err = core.DecodeContinue(opts.Continue); err != nil {
+			return page, err
+		}
+	}
+	if revision <= 0 {
+		c.mu.RLock()
+		revision = c.rev
+		c.mu.RUnlock()
+	}
+	if err = ctx.Err(); err != nil {
+		return page, err
+	}
+	hosts, err := c.ListHostAt(ctx, revision)
+	if err != nil {
+		return page, err
+	}
+	return core.PageHosts(hosts, opts, revision)
+}
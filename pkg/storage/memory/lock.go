@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// heldLock is a lock of host, whose released channel is closed once it is
+// released.
+type heldLock struct {
+	lock     core.HostLock
+	released chan struct{}
+}
+
+// LockHost acquires an in-process lock, which never expires while held, thus
+// ttl is ignored.
+func (c *conn) LockHost(ctx context.Context, lock core.HostLock, ttl time.Duration) (unlock func() error, err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during locking host '%s' due to: %v", lock.Hostname, err)
+		} else {
+			c.logger.Debugf("Locked host '%s' for '%s'", lock.Hostname, lock.Holder)
+		}
+	}()
+	for {
+		c.lockMu.Lock()
+		held, ok := c.locks[lock.Hostname]
+		if !ok {
+			lock.AcquiredAt = time.Now().UTC()
+			lock.ExpiresAt = time.Time{}
+			held = &heldLock{lock: lock, released: make(chan struct{})}
+			c.locks[lock.Hostname] = held
+			c.lockMu.Unlock()
+			return func() error {
+				c.release(lock.Hostname, held)
+				return nil
+			}, nil
+		}
+		c.lockMu.Unlock()
+		select {
+		case <-held.released:
+		case <-ctx.Done():
+			return nil, core.ErrHostLocked
+		}
+	}
+}
+
+// release removes held if it is still the lock of host.
+func (c *conn) release(hostname string, held *heldLock) bool {
+	c.lockMu.Lock()
+	defer c.lockMu.Unlock()
+	if c.locks[hostname] != held {
+		return false
+	}
+	delete(c.locks, hostname)
+	close(held.released)
+	return true
+}
+
+func (c *conn) ListHostLocks(ctx context.Context) ([]core.HostLock, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.lockMu.Lock()
+	defer c.lockMu.Unlock()
+	var locks []core.HostLock
+	for _, held := range c.locks {
+		locks = append(locks, held.lock)
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Hostname < locks[j].Hostname })
+	return locks, nil
+}
+
+func (c *conn) ForceUnlockHost(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.lockMu.Lock()
+	held, ok := c.locks[id]
+	c.lockMu.Unlock()
+	if !ok || !c.release(id, held) {
+		return core.ErrResourceNotFound
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	profilePrefix = "profile"
+)
+
+func (c *conn) CreateCredentialProfile(ctx context.Context, profile core.CredentialProfile) error {
+	profile.UpdatedAt = time.Now().UTC()
+	return c.createKey(ctx, canonicalID(profilePrefix, profile.Name), profile, nil)
+}
+
+func (c *conn) GetCredentialProfile(ctx context.Context, name string) (profile core.CredentialProfile, err error) {
+	if _, err = c.getKey(ctx, canonicalID(profilePrefix, name), &profile); err != nil {
+		return
+	}
+	return profile, nil
+}
+
+func (c *conn) ListCredentialProfiles(ctx context.Context) (profiles []core.CredentialProfile, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, key := range c.sortedKeys(profilePrefix + "/") {
+		var profile core.CredentialProfile
+		if err = json.Unmarshal(c.data[key], &profile); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+func (c *conn) UpdateCredentialProfile(ctx context.Context, name string, updater func(profile core.CredentialProfile) (core.CredentialProfile, error)) error {
+	return c.updateKey(ctx, canonicalID(profilePrefix, name), func(currentValue []byte, revision int64) ([]byte, error) {
+		var current core.CredentialProfile
+		if len(currentValue) > 0 {
+			if err := json.Unmarshal(currentValue, &current); err != nil {
+				return nil, err
+			}
+		}
+		updated, err := updater(current)
+		if err != nil {
+			return nil, err
+		}
+		updated.UpdatedAt = time.Now().UTC()
+		updated.Name = name
+		return json.Marshal(updated)
+	}, nil)
+}
+
+func (c *conn) DeleteCredentialProfile(ctx context.Context, name string) error {
+	return c.deleteKey(ctx, canonicalID(profilePrefix, name), nil)
+}
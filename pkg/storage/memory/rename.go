@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// RenameHost deletes the old key and creates the new one in consecutive
+// revisions, which is how HostHistory recognizes a rename.
+func (c *conn) RenameHost(ctx context.Context, id, newID string) (err error) {
+	key := canonicalID(hostPrefix, id)
+	newKey := canonicalID(hostPrefix, newID)
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during renaming data entity '%s' to '%s' due to: %v", key, newKey, err)
+		} else {
+			c.logger.Debugf("Moved key '%s' to '%s'", key, newKey)
+		}
+	}()
+	if newID == "" {
+		return fmt.Errorf("New hostname must not be empty")
+	}
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current, ok := c.data[key]
+	if !ok {
+		return core.ErrResourceNotFound
+	}
+	if _, ok = c.data[newKey]; ok {
+		return core.ErrResourceAlreadyExists
+	}
+	var host core.Host
+	if err = json.Unmarshal(current, &host); err != nil {
+		return err
+	}
+	host.Hostname = newID
+	host.UpdatedAt = time.Now().UTC()
+	var b []byte
+	b, err = json.Marshal(host)
+	if err != nil {
+		return err
+	}
+	if err = c.updateHostIndexes(current, b); err != nil {
+		return err
+	}
+	c.commit(key, nil)
+	c.commit(newKey, b)
+	return nil
+}
@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	trashPrefix = "trash"
+)
+
+func (c *conn) TrashHost(ctx context.Context, id, reason string) (err error) {
+	key := canonicalID(hostPrefix, id)
+	trashKey := canonicalID(trashPrefix, id)
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during trashing data entity '%s' due to: %v", key, err)
+		} else {
+			c.logger.Debugf("Moved key '%s' to '%s'", key, trashKey)
+		}
+	}()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.purgeTrash()
+	current, ok := c.data[key]
+	if !ok {
+		return core.ErrResourceNotFound
+	}
+	trashed := core.TrashedHost{
+		DeletedAt: time.Now().UTC(),
+		Reason:    reason,
+	}
+	if err = json.Unmarshal(current, &trashed.Host); err != nil {
+		return err
+	}
+	var b []byte
+	b, err = json.Marshal(trashed)
+	if err != nil {
+		return err
+	}
+	if err = c.updateHostIndexes(current, nil); err != nil {
+		return err
+	}
+	c.commit(key, nil)
+	c.commit(trashKey, b)
+	return nil
+}
+
+func (c *conn) ListTrash(ctx context.Context) (trashed []core.TrashedHost, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.purgeTrash()
+	for _, key := range c.sortedKeys(trashPrefix + "/") {
+		var each core.TrashedHost
+		if err = json.Unmarshal(c.data[key], &each); err != nil {
+			return nil, err
+		}
+		trashed = append(trashed, each)
+	}
+	return trashed, nil
+}
+
+func (c *conn) RestoreHost(ctx context.Context, id string) (err error) {
+	key := canonicalID(hostPrefix, id)
+	trashKey := canonicalID(trashPrefix, id)
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during restoring data entity '%s' due to: %v", key, err)
+		} else {
+			c.logger.Debugf("Moved key '%s' to '%s'", trashKey, key)
+		}
+	}()
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.purgeTrash()
+	current, ok := c.data[trashKey]
+	if !ok {
+		return core.ErrResourceNotFound
+	}
+	if _, ok = c.data[key]; ok {
+		return core.ErrResourceAlreadyExists
+	}
+	var trashed core.TrashedHost
+	if err = json.Unmarshal(current, &trashed); err != nil {
+		return err
+	}
+	host := trashed.Host
+	host.UpdatedAt = time.Now().UTC()
+	var b []byte
+	b, err = json.Marshal(host)
+	if err != nil {
+		return err
+	}
+	if err = c.updateHostIndexes(nil, b); err != nil {
+		return err
+	}
+	c.commit(trashKey, nil)
+	c.commit(key, b)
+	return nil
+}
+
+// purgeTrash removes trashed hosts which have exceeded the retention period.
+// Caller must hold the write lock.
+func (c *conn) purgeTrash() {
+	now := time.Now()
+	for _, key := range c.sortedKeys(trashPrefix + "/") {
+		var each core.TrashedHost
+		if err := json.Unmarshal(c.data[key], &each); err != nil {
+			continue
+		}
+		if each.Expired(c.trashRetention, now) {
+			c.logger.Debugf("Purged expired key '%s'", key)
+			c.commit(key, nil)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+func TestTrashAndRestoreHost(t *testing.T) {
+	ctx := context.Background()
+	storage := openMemory(t, New())
+	defer storage.Close()
+	createHost(t, storage, "node-01")
+	createHost(t, storage, "node-02")
+
+	if err := storage.TrashHost(ctx, "node-03", "Decommissioned"); err != core.ErrResourceNotFound {
+		t.Errorf("Expected ErrResourceNotFound for absent host, got: %v", err)
+	}
+	if err := storage.TrashHost(ctx, "node-01", "Decommissioned"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.GetHost(ctx, "node-01"); err != core.ErrResourceNotFound {
+		t.Errorf("Expected trashed host to be absent, got error: %v", err)
+	}
+	hosts, err := storage.ListHost(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].Hostname != "node-02" {
+		t.Errorf("Expected only 'node-02' listed, got: %+v", hosts)
+	}
+	trashed, err := storage.ListTrash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trashed) != 1 || trashed[0].Host.Hostname != "node-01" || trashed[0].Reason != "Decommissioned" || trashed[0].DeletedAt.IsZero() {
+		t.Fatalf("Expected 'node-01' in trash bin, got: %+v", trashed)
+	}
+
+	// a host of the same name blocks restoring
+	createHost(t, storage, "node-01")
+	if err = storage.RestoreHost(ctx, "node-01"); err != core.ErrResourceAlreadyExists {
+		t.Errorf("Expected ErrResourceAlreadyExists, got: %v", err)
+	}
+	if err = storage.DeleteHost(ctx, "node-01"); err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.RestoreHost(ctx, "node-01"); err != nil {
+		t.Fatal(err)
+	}
+	host, err := storage.GetHost(ctx, "node-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if host.GUID != trashed[0].Host.GUID {
+		t.Errorf("Expected restored host to keep GUID '%s', got '%s'", trashed[0].Host.GUID, host.GUID)
+	}
+	if trashed, err = storage.ListTrash(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(trashed) != 0 {
+		t.Errorf("Expected empty trash bin, got: %+v", trashed)
+	}
+	if err = storage.RestoreHost(ctx, "node-01"); err != core.ErrResourceNotFound {
+		t.Errorf("Expected ErrResourceNotFound once restored, got: %v", err)
+	}
+}
+
+func TestTrashPurgedAfterRetention(t *testing.T) {
+	ctx := context.Background()
+	config := New()
+	config.TrashRetention = "50ms"
+	storage := openMemory(t, config)
+	defer storage.Close()
+	createHost(t, storage, "node-01")
+	createHost(t, storage, "node-02")
+	if err := storage.TrashHost(ctx, "node-01", "Decommissioned"); err != nil {
+		t.Fatal(err)
+	}
+	trashed, err := storage.ListTrash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trashed) != 1 {
+		t.Fatalf("Expected 'node-01' kept within retention, got: %+v", trashed)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if err = storage.TrashHost(ctx, "node-02", "Decommissioned"); err != nil {
+		t.Fatal(err)
+	}
+	if trashed, err = storage.ListTrash(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(trashed) != 1 || trashed[0].Host.Hostname != "node-02" {
+		t.Errorf("Expected only 'node-02' kept, got: %+v", trashed)
+	}
+	if err = storage.RestoreHost(ctx, "node-01"); err != core.ErrResourceNotFound {
+		t.Errorf("Expected ErrResourceNotFound for purged host, got: %v", err)
+	}
+
+	config.TrashRetention = "forever"
+	if _, err = config.Open(nil); err == nil {
+		t.Error("Expected invalid retention to be rejected")
+	}
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+func (c *conn) WatchHosts(ctx context.Context, fromRevision int64) (<-chan core.HostEvent, error) {
+	c.mu.RLock()
+	cursor := c.rev
+	if fromRevision > 0 {
+		cursor = fromRevision - 1
+	}
+	c.mu.RUnlock()
+	events := make(chan core.HostEvent)
+	go func() {
+		defer close(events)
+		for {
+			c.mu.RLock()
+			// Existing changes are never modified, so it is safe to read them
+			// after the lock is released.
+			var pending []change
+			if cursor < int64(len(c.changes)) {
+				pending = c.changes[cursor:]
+			}
+			notify := c.notify
+			c.mu.RUnlock()
+			for i, each := range pending {
+				if !strings.HasPrefix(each.key, hostPrefix+"/") {
+					continue
+				}
+				event, err := hostEventOf(each, cursor+int64(i)+1)
+				if err != nil {
+					event = core.HostEvent{Err: err}
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				case <-c.closed:
+					return
+				}
+				if err != nil {
+					return
+				}
+			}
+			cursor += int64(len(pending))
+			select {
+			case <-notify:
+			case <-ctx.Done():
+				return
+			case <-c.closed:
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func hostEventOf(ch change, rev int64) (event core.HostEvent, err error) {
+	event.Revision = rev
+	switch {
+	case ch.value == nil:
+		event.Type = core.EventDeleted
+		err = json.Unmarshal(ch.prev, &event.Host)
+		event.Host.Revision = ch.prevRev
+		return
+	case ch.prev == nil:
+		event.Type = core.EventCreated
+	default:
+		event.Type = core.EventUpdated
+	}
+	err = json.Unmarshal(ch.value, &event.Host)
+	event.Host.Revision = rev
+	return
+}
@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	zap "go.uber.org/zap"
+)
+
+func openMemory(t *testing.T, config *Memory) core.Storage {
+	storage, err := config.Open(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+func createHost(t *testing.T, storage core.Storage, hostname string) {
+	host := core.NewHost()
+	host.Hostname = hostname
+	if err := storage.CreateHost(context.Background(), *host); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func nextEvent(t *testing.T, events <-chan core.HostEvent) core.HostEvent {
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("Watch was closed unexpectedly")
+		}
+		if event.Err != nil {
+			t.Fatal(event.Err)
+		}
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("No event arrived in time")
+	}
+	return core.HostEvent{}
+}
+
+func expectClosed(t *testing.T, events <-chan core.HostEvent) {
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Fatal("Expected watch to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch was not closed in time")
+	}
+}
+
+func TestWatchHosts(t *testing.T) {
+	storage := openMemory(t, New())
+	defer storage.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := storage.WatchHosts(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createHost(t, storage, "node-01")
+	err = storage.UpdateHost(context.Background(), "node-01", func(host core.Host) (core.Host, error) {
+		host.SSHUser = "ops"
+		return host, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// changes of other entities are not reported
+	if err = storage.CreateCredentialProfile(context.Background(), core.CredentialProfile{Name: "dell", User: "root"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = storage.DeleteHost(context.Background(), "node-01"); err != nil {
+		t.Fatal(err)
+	}
+
+	created := nextEvent(t, events)
+	if created.Type != core.EventCreated || created.Host.Hostname != "node-01" {
+		t.Errorf("Expected creation of 'node-01', got: %+v", created)
+	}
+	if created.Host.Revision != created.Revision {
+		t.Errorf("Expected host at revision %d, got revision %d", created.Revision, created.Host.Revision)
+	}
+	updated := nextEvent(t, events)
+	if updated.Type != core.EventUpdated || updated.Host.SSHUser != "ops" || updated.Revision <= created.Revision {
+		t.Errorf("Expected update of 'node-01' after revision %d, got: %+v", created.Revision, updated)
+	}
+	deleted := nextEvent(t, events)
+	if deleted.Type != core.EventDeleted || deleted.Revision <= updated.Revision {
+		t.Errorf("Expected deletion of 'node-01' after revision %d, got: %+v", updated.Revision, deleted)
+	}
+	// deleted hosts carry their last known value
+	if deleted.Host.Hostname != "node-01" || deleted.Host.SSHUser != "ops" || deleted.Host.Revision != updated.Revision {
+		t.Errorf("Expected last known value of 'node-01', got: %+v", deleted.Host)
+	}
+
+	cancel()
+	expectClosed(t, events)
+}
+
+func TestWatchHostsFromRevision(t *testing.T) {
+	storage := openMemory(t, New())
+	defer storage.Close()
+	createHost(t, storage, "node-01")
+	createHost(t, storage, "node-02")
+	host, err := storage.GetHost(context.Background(), "node-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// changes since the revision are replayed, including it
+	events, err := storage.WatchHosts(ctx, host.Revision)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := nextEvent(t, events)
+	if event.Type != core.EventCreated || event.Host.Hostname != "node-02" || event.Revision != host.Revision {
+		t.Errorf("Expected creation of 'node-02' at revision %d, got: %+v", host.Revision, event)
+	}
+	createHost(t, storage, "node-03")
+	if event = nextEvent(t, events); event.Host.Hostname != "node-03" {
+		t.Errorf("Expected creation of 'node-03', got: %+v", event)
+	}
+
+	// the watch ends once the storage is closed
+	storage.Close()
+	expectClosed(t, events)
+}
@@ -0,0 +1,115 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	prometheus "github.com/prometheus/client_golang/prometheus"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	namespace = "ivy_utils"
+	subsystem = "storage"
+)
+
+// Kinds of errors, which are values of the 'kind' label.
+const (
+	KindNotFound      = "not_found"
+	KindAlreadyExists = "already_exists"
+	KindConflict      = "conflict"
+	KindTimeout       = "timeout"
+	KindCanceled      = "canceled"
+	KindNotSupported  = "not_supported"
+	KindOther         = "other"
+)
+
+// Collectors are Prometheus collectors of storage operations, which are
+// labeled by adapter and could be shared by multiple storages.
+type Collectors struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	inFlight *prometheus.GaugeVec
+}
+
+// NewCollectors creates collectors and registers them with registerer.
+func NewCollectors(registerer prometheus.Registerer) (*Collectors, error) {
+	in := &Collectors{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of storage operations, including failed ones.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+		}, []string{"adapter", "operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "operation_errors_total",
+			Help:      "Number of failed storage operations by kind of error.",
+		}, []string{"adapter", "operation", "kind"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "operations_in_flight",
+			Help:      "Number of storage operations in progress.",
+		}, []string{"adapter", "operation"}),
+	}
+	for _, each := range []prometheus.Collector{in.duration, in.errors, in.inFlight} {
+		if err := registerer.Register(each); err != nil {
+			return nil, err
+		}
+	}
+	return in, nil
+}
+
+var (
+	defaultCollectors     *Collectors
+	defaultCollectorsErr  error
+	defaultCollectorsOnce sync.Once
+)
+
+// DefaultCollectors returns collectors registered with
+// prometheus.DefaultRegisterer, which are created on the first call.
+func DefaultCollectors() (*Collectors, error) {
+	defaultCollectorsOnce.Do(func() {
+		defaultCollectors, defaultCollectorsErr = NewCollectors(prometheus.DefaultRegisterer)
+	})
+	return defaultCollectors, defaultCollectorsErr
+}
+
+// ErrorKind classifies err for the 'kind' label.
+func ErrorKind(err error) string {
+	switch err {
+	case core.ErrResourceNotFound:
+		return KindNotFound
+	case core.ErrResourceAlreadyExists:
+		return KindAlreadyExists
+	case core.ErrIndexConflict, core.ErrConcurrentUpdate, core.ErrRevisionMismatch, core.ErrHostLocked:
+		return KindConflict
+	case context.DeadlineExceeded:
+		return KindTimeout
+	case context.Canceled:
+		return KindCanceled
+	case core.ErrNotSupported:
+		return KindNotSupported
+	}
+	if _, ok := err.(*core.BatchError); ok {
+		return KindConflict
+	}
+	return KindOther
+}
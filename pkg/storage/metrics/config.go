@@ -0,0 +1,24 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+// Config enables metrics of storage operations, which are registered with
+// prometheus.DefaultRegisterer.
+type Config struct {
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	// Tracing also records a span of each operation with the tracer set by
+	// SetTracer, e.g. an adapted OpenTelemetry tracer.
+	Tracing bool `json:"tracing,omitempty" yaml:"tracing,omitempty"`
+}
@@ -0,0 +1,217 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// Storage records latency, errors and in-flight operations of the wrapped
+// storage, and optionally a span of each operation.
+type Storage struct {
+	core.Storage
+	adapter    string
+	collectors *Collectors
+	tracer     Tracer
+}
+
+// Wrap decorates storage of given adapter with collectors. Spans are only
+// recorded if tracer is not nil.
+func Wrap(storage core.Storage, adapter string, collectors *Collectors, tracer Tracer) *Storage {
+	return &Storage{
+		Storage:    storage,
+		adapter:    adapter,
+		collectors: collectors,
+		tracer:     tracer,
+	}
+}
+
+// observe starts recording operation, and returns ctx carrying its span and a
+// func which finishes it with the error of operation.
+func (in *Storage) observe(ctx context.Context, operation string, attrs ...Attribute) (context.Context, func(err error)) {
+	inFlight := in.collectors.inFlight.WithLabelValues(in.adapter, operation)
+	inFlight.Inc()
+	start := time.Now()
+	var endSpan func(err error)
+	if in.tracer != nil {
+		attrs = append(attrs, Attribute{Key: "storage.adapter", Value: in.adapter})
+		ctx, endSpan = in.tracer.Start(ctx, operation, attrs)
+	}
+	return ctx, func(err error) {
+		inFlight.Dec()
+		in.collectors.duration.WithLabelValues(in.adapter, operation).Observe(time.Since(start).Seconds())
+		if err != nil {
+			in.collectors.errors.WithLabelValues(in.adapter, operation, ErrorKind(err)).Inc()
+		}
+		if endSpan != nil {
+			endSpan(err)
+		}
+	}
+}
+
+func hostname(id string) Attribute {
+	return Attribute{Key: "ivy.hostname", Value: id}
+}
+
+func (in *Storage) CreateHost(ctx context.Context, host core.Host) (err error) {
+	ctx, done := in.observe(ctx, "create_host", hostname(host.Hostname))
+	defer func() { done(err) }()
+	return in.Storage.CreateHost(ctx, host)
+}
+
+func (in *Storage) GetHost(ctx context.Context, id string) (host core.Host, err error) {
+	ctx, done := in.observe(ctx, "get_host", hostname(id))
+	defer func() { done(err) }()
+	return in.Storage.GetHost(ctx, id)
+}
+
+func (in *Storage) ListHost(ctx context.Context) (hosts []core.Host, err error) {
+	ctx, done := in.observe(ctx, "list_host")
+	defer func() { done(err) }()
+	return in.Storage.ListHost(ctx)
+}
+
+func (in *Storage) ListHosts(ctx context.Context, opts core.ListOptions) (page core.HostPage, err error) {
+	ctx, done := in.observe(ctx, "list_hosts")
+	defer func() { done(err) }()
+	return in.Storage.ListHosts(ctx, opts)
+}
+
+func (in *Storage) GetHostBy(ctx context.Context, field core.IndexField, value string) (host core.Host, err error) {
+	ctx, done := in.observe(ctx, "get_host_by", Attribute{Key: "ivy.index_field", Value: string(field)})
+	defer func() { done(err) }()
+	return in.Storage.GetHostBy(ctx, field, value)
+}
+
+func (in *Storage) UpdateHost(ctx context.Context, id string, updater func(host core.Host) (core.Host, error)) (err error) {
+	ctx, done := in.observe(ctx, "update_host", hostname(id))
+	defer func() { done(err) }()
+	return in.Storage.UpdateHost(ctx, id, updater)
+}
+
+func (in *Storage) DeleteHost(ctx context.Context, id string) (err error) {
+	ctx, done := in.observe(ctx, "delete_host", hostname(id))
+	defer func() { done(err) }()
+	return in.Storage.DeleteHost(ctx, id)
+}
+
+func (in *Storage) ApplyBatch(ctx context.Context, ops []core.BatchOp) (err error) {
+	ctx, done := in.observe(ctx, "apply_batch", Attribute{Key: "ivy.batch_size", Value: len(ops)})
+	defer func() { done(err) }()
+	return in.Storage.ApplyBatch(ctx, ops)
+}
+
+func (in *Storage) RenameHost(ctx context.Context, id, newID string) (err error) {
+	ctx, done := in.observe(ctx, "rename_host", hostname(id))
+	defer func() { done(err) }()
+	return in.Storage.RenameHost(ctx, id, newID)
+}
+
+func (in *Storage) TrashHost(ctx context.Context, id, reason string) (err error) {
+	ctx, done := in.observe(ctx, "trash_host", hostname(id))
+	defer func() { done(err) }()
+	return in.Storage.TrashHost(ctx, id, reason)
+}
+
+func (in *Storage) ListTrash(ctx context.Context) (trashed []core.TrashedHost, err error) {
+	ctx, done := in.observe(ctx, "list_trash")
+	defer func() { done(err) }()
+	return in.Storage.ListTrash(ctx)
+}
+
+func (in *Storage) RestoreHost(ctx context.Context, id string) (err error) {
+	ctx, done := in.observe(ctx, "restore_host", hostname(id))
+	defer func() { done(err) }()
+	return in.Storage.RestoreHost(ctx, id)
+}
+
+// WatchHosts only records opening the watch, not the lifetime of it.
+func (in *Storage) WatchHosts(ctx context.Context, fromRevision int64) (events <-chan core.HostEvent, err error) {
+	// the span must not carry the context of watch, which outlives it
+	_, done := in.observe(ctx, "watch_hosts")
+	defer func() { done(err) }()
+	return in.Storage.WatchHosts(ctx, fromRevision)
+}
+
+func (in *Storage) HostHistory(ctx context.Context, id string) (history []core.HostRevision, err error) {
+	ctx, done := in.observe(ctx, "host_history", hostname(id))
+	defer func() { done(err) }()
+	return in.Storage.HostHistory(ctx, id)
+}
+
+func (in *Storage) ListHostAt(ctx context.Context, revision int64) (hosts []core.Host, err error) {
+	ctx, done := in.observe(ctx, "list_host_at")
+	defer func() { done(err) }()
+	return in.Storage.ListHostAt(ctx, revision)
+}
+
+func (in *Storage) CreateCredentialProfile(ctx context.Context, profile core.CredentialProfile) (err error) {
+	ctx, done := in.observe(ctx, "create_credential_profile")
+	defer func() { done(err) }()
+	return in.Storage.CreateCredentialProfile(ctx, profile)
+}
+
+func (in *Storage) GetCredentialProfile(ctx context.Context, name string) (profile core.CredentialProfile, err error) {
+	ctx, done := in.observe(ctx, "get_credential_profile")
+	defer func() { done(err) }()
+	return in.Storage.GetCredentialProfile(ctx, name)
+}
+
+func (in *Storage) ListCredentialProfiles(ctx context.Context) (profiles []core.CredentialProfile, err error) {
+	ctx, done := in.observe(ctx, "list_credential_profiles")
+	defer func() { done(err) }()
+	return in.Storage.ListCredentialProfiles(ctx)
+}
+
+func (in *Storage) UpdateCredentialProfile(ctx context.Context, name string, updater func(profile core.CredentialProfile) (core.CredentialProfile, error)) (err error) {
+	ctx, done := in.observe(ctx, "update_credential_profile")
+	defer func() { done(err) }()
+	return in.Storage.UpdateCredentialProfile(ctx, name, updater)
+}
+
+func (in *Storage) DeleteCredentialProfile(ctx context.Context, name string) (err error) {
+	ctx, done := in.observe(ctx, "delete_credential_profile")
+	defer func() { done(err) }()
+	return in.Storage.DeleteCredentialProfile(ctx, name)
+}
+
+// LockHost records the time until the lock is acquired, which includes
+// waiting for other holders.
+func (in *Storage) LockHost(ctx context.Context, lock core.HostLock, ttl time.Duration) (unlock func() error, err error) {
+	_, done := in.observe(ctx, "lock_host", hostname(lock.Hostname))
+	defer func() { done(err) }()
+	return in.Storage.LockHost(ctx, lock, ttl)
+}
+
+func (in *Storage) ListHostLocks(ctx context.Context) (locks []core.HostLock, err error) {
+	ctx, done := in.observe(ctx, "list_host_locks")
+	defer func() { done(err) }()
+	return in.Storage.ListHostLocks(ctx)
+}
+
+func (in *Storage) ForceUnlockHost(ctx context.Context, id string) (err error) {
+	ctx, done := in.observe(ctx, "force_unlock_host", hostname(id))
+	defer func() { done(err) }()
+	return in.Storage.ForceUnlockHost(ctx, id)
+}
+
+func (in *Storage) BuildIndexes(ctx context.Context) (err error) {
+	ctx, done := in.observe(ctx, "build_indexes")
+	defer func() { done(err) }()
+	return in.Storage.BuildIndexes(ctx)
+}
@@ -0,0 +1,69 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"sync"
+)
+
+// Attribute is a key-value pair describing a traced operation.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Tracer records a span of each storage operation. It lets a tracing system,
+// e.g. OpenTelemetry, be plugged in by the program, so that storage does not
+// depend on any of them. An OpenTelemetry tracer could be adapted like
+//
+//	func (in otelTracer) Start(ctx context.Context, operation string, attrs []metrics.Attribute) (context.Context, func(error)) {
+//		ctx, span := in.tracer.Start(ctx, "storage."+operation, trace.WithSpanKind(trace.SpanKindClient))
+//		for _, each := range attrs {
+//			span.SetAttributes(attribute.String(each.Key, fmt.Sprint(each.Value)))
+//		}
+//		return ctx, func(err error) {
+//			if err != nil {
+//				span.RecordError(err)
+//				span.SetStatus(codes.Error, metrics.ErrorKind(err))
+//			}
+//			span.End()
+//		}
+//	}
+type Tracer interface {
+	// Start starts a span of operation, and returns ctx carrying the span and
+	// a func which ends it with the error of operation.
+	Start(ctx context.Context, operation string, attrs []Attribute) (context.Context, func(err error))
+}
+
+var (
+	tracerMu     sync.RWMutex
+	globalTracer Tracer
+)
+
+// SetTracer sets the tracer of storages whose tracing is enabled by Config.
+// It has to be called before such storages are created.
+func SetTracer(tracer Tracer) {
+	tracerMu.Lock()
+	defer tracerMu.Unlock()
+	globalTracer = tracer
+}
+
+// GlobalTracer returns the tracer set by SetTracer, or nil if there is none.
+func GlobalTracer() Tracer {
+	tracerMu.RLock()
+	defer tracerMu.RUnlock()
+	return globalTracer
+}
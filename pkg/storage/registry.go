@@ -0,0 +1,146 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	zap "go.uber.org/zap"
+)
+
+// Factory decodes adapter specific configuration, which is the 'config'
+// section of StorageConfig, and opens a storage with it.
+type Factory func(config map[string]interface{}, logger *zap.SugaredLogger) (core.Storage, error)
+
+// ConfigOption describes a configuration key accepted by an adapter.
+type ConfigOption struct {
+	Name        string `json:"name" yaml:"name"`
+	Type        string `json:"type" yaml:"type"`
+	Default     string `json:"default,omitempty" yaml:"default,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+// Adapter is a registered storage adapter.
+type Adapter struct {
+	Name    string         `json:"name" yaml:"name"`
+	Schema  []ConfigOption `json:"schema,omitempty" yaml:"schema,omitempty"`
+	Factory Factory        `json:"-" yaml:"-"`
+}
+
+var (
+	adaptersMu sync.RWMutex
+	adapters   = make(map[string]Adapter)
+)
+
+// Register makes a storage adapter available by the provided name. It is
+// meant to be called from the init function of the package implementing the
+// adapter, so that the adapter can be enabled by import side effect. If
+// Register is called twice with the same name or if factory is nil, it
+// panics.
+func Register(name string, factory Factory, schema ...ConfigOption) {
+	adaptersMu.Lock()
+	defer adaptersMu.Unlock()
+	if factory == nil {
+		panic("storage: Register factory is nil")
+	}
+	if _, dup := adapters[name]; dup {
+		panic("storage: Register called twice for adapter " + name)
+	}
+	adapters[name] = Adapter{
+		Name:    name,
+		Schema:  schema,
+		Factory: factory,
+	}
+}
+
+// LookupAdapter returns the adapter registered with given name.
+func LookupAdapter(name string) (Adapter, bool) {
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+	adapter, ok := adapters[name]
+	return adapter, ok
+}
+
+// Adapters returns all registered adapters sorted by name.
+func Adapters() []Adapter {
+	adaptersMu.RLock()
+	defer adaptersMu.RUnlock()
+	var list []Adapter
+	for _, adapter := range adapters {
+		list = append(list, adapter)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// DecodeConfig decodes adapter specific configuration into v according to
+// its json tags. It is a helper for implementing Factory.
+func DecodeConfig(config map[string]interface{}, v interface{}) error {
+	normalized, err := normalizeConfig(config)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(normalized)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// normalizeConfig converts nested mappings decoded from YAML, which are of
+// type map[interface{}]interface{}, into ones that could be encoded as JSON.
+func normalizeConfig(in interface{}) (interface{}, error) {
+	switch v := in.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			normalized, err := normalizeConfig(value)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = normalized
+		}
+		return out, nil
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			k, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("Unsupported non-string configuration key: %v", key)
+			}
+			normalized, err := normalizeConfig(value)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = normalized
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			normalized, err := normalizeConfig(v[i])
+			if err != nil {
+				return nil, err
+			}
+			out[i] = normalized
+		}
+		return out, nil
+	}
+	return in, nil
+}
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// ApplyBatch plans and applies the batch within a single transaction, which
+// is rolled back on any error.
+func (c *conn) ApplyBatch(ctx context.Context, ops []core.BatchOp) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during applying batch of %d operations due to: %v", len(ops), err)
+		} else {
+			c.logger.Debugf("Applied batch of %d operations", len(ops))
+		}
+	}()
+	return c.withTx(ctx, func(tx *sql.Tx) error {
+		current := func(hostname string) (*core.Host, error) {
+			host, err := getHost(ctx, tx, hostname)
+			if err == core.ErrResourceNotFound {
+				return nil, nil
+			}
+			return &host, err
+		}
+		owners := func(entry core.IndexEntry) (hostnames []string, err error) {
+			rows, err := tx.Query("SELECT hostname FROM hosts WHERE "+indexColumns[entry.Field]+" = ?", entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var hostname string
+				if err = rows.Scan(&hostname); err != nil {
+					return nil, err
+				}
+				hostnames = append(hostnames, hostname)
+			}
+			return hostnames, rows.Err()
+		}
+		changes, err := core.PlanBatch(ops, current, owners)
+		if err != nil {
+			return err
+		}
+		// Unique fields are checked by the plan against the state after the
+		// whole batch, while checking row by row depends on the order.
+		for _, change := range changes {
+			switch {
+			case change.Updated == nil:
+				_, err = tx.Exec("DELETE FROM hosts WHERE hostname = ?", change.Current.Hostname)
+			case change.Current == nil:
+				err = writeHost(tx, *change.Updated, false)
+			default:
+				err = writeHost(tx, *change.Updated, true)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
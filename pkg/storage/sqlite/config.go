@@ -0,0 +1,85 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	zap "go.uber.org/zap"
+)
+
+const (
+	defaultBusyTimeout = 5 * time.Second
+)
+
+// SQLite persists host entities in relational tables of a local SQLite
+// database, which makes it possible to run ad-hoc SQL reports over CMDB.
+type SQLite struct {
+	// Path is the location of database file, which will be created if absent.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// BusyTimeout is the amount of time to wait for a lock held by another
+	// connection, e.g. "5s".
+	BusyTimeout string `json:"busy_timeout,omitempty" yaml:"busy_timeout,omitempty"`
+	// TrashRetention is the period a trashed host is kept before it is
+	// purged, e.g. "720h".
+	TrashRetention string `json:"trash_retention,omitempty" yaml:"trash_retention,omitempty"`
+}
+
+func (in *SQLite) Open(logger *zap.SugaredLogger) (core.Storage, error) {
+	if in.Path == "" {
+		return nil, fmt.Errorf("Database file path of sqlite storage was not specified")
+	}
+	timeout := defaultBusyTimeout
+	if in.BusyTimeout != "" {
+		d, err := time.ParseDuration(in.BusyTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid busy timeout '%s': %v", in.BusyTimeout, err)
+		}
+		timeout = d
+	}
+	trashRetention, err := core.ParseTrashRetention(in.TrashRetention)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("_busy_timeout", fmt.Sprintf("%d", timeout/time.Millisecond))
+	params.Set("_foreign_keys", "1")
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?%s", in.Path, params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows only one writer at a time, so we serialize all accesses
+	// through a single connection instead of failing with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+	if err = migrate(db, logger); err != nil {
+		db.Close()
+		return nil, err
+	}
+	c := &conn{
+		db:             db,
+		trashRetention: trashRetention,
+		logger:         logger,
+	}
+	return c, nil
+}
+
+func New() *SQLite {
+	return &SQLite{}
+}
@@ -0,0 +1,305 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	zap "go.uber.org/zap"
+)
+
+const (
+	hostColumns = "guid, hostname, ssh_addr, ssh_port, ssh_user, ipmi_addr, ipmi_user, ipmi_pass, ipmi_secret, credential_profile, extra_info, updated_at"
+)
+
+type conn struct {
+	db             *sql.DB
+	trashRetention time.Duration
+	logger         *zap.SugaredLogger
+}
+
+func (c *conn) Close() error {
+	return c.db.Close()
+}
+
+func (c *conn) CreateHost(ctx context.Context, host core.Host) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during creating host '%s' due to: %v", host.Hostname, err)
+		} else {
+			c.logger.Debugf("Created host '%s': %v", host.Hostname, host)
+		}
+	}()
+	if _, err := uuid.FromString(host.GUID); err != nil {
+		host.GUID = uuid.NewV4().String()
+	}
+	host.UpdatedAt = time.Now().UTC()
+	return c.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getHost(ctx, tx, host.Hostname); err != core.ErrResourceNotFound {
+			if err == nil {
+				return core.ErrResourceAlreadyExists
+			}
+			return err
+		}
+		return insertHost(tx, host)
+	})
+}
+
+func (c *conn) GetHost(ctx context.Context, id string) (host core.Host, err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during getting host '%s' due to: %v", id, err)
+		} else {
+			c.logger.Debugf("Retrieved host '%s': %v", id, host)
+		}
+	}()
+	return getHost(ctx, c.db, id)
+}
+
+// UpdateHost runs the updater inside a transaction. The hostname column is
+// the primary unique identifier, so it always equals to given id.
+func (c *conn) UpdateHost(ctx context.Context, id string, updater func(host core.Host) (core.Host, error)) (err error) {
+	var updated core.Host
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during updating host '%s' due to: %v", id, err)
+		} else {
+			c.logger.Debugf("Updated host '%s': %v", id, updated)
+		}
+	}()
+	return c.withTx(ctx, func(tx *sql.Tx) error {
+		current, err := getHost(ctx, tx, id)
+		exists := err == nil
+		if err == core.ErrResourceNotFound {
+			current = *core.NewHost()
+		} else if err != nil {
+			return err
+		}
+		updated, err = updater(current)
+		if err != nil {
+			return err
+		}
+		if _, err := uuid.FromString(updated.GUID); err != nil {
+			updated.GUID = uuid.NewV4().String()
+		}
+		updated.UpdatedAt = time.Now().UTC()
+		updated.Hostname = id
+		if !exists {
+			return insertHost(tx, updated)
+		}
+		return updateHost(tx, updated)
+	})
+}
+
+func (c *conn) DeleteHost(ctx context.Context, id string) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during deleting host '%s' due to: %v", id, err)
+		} else {
+			c.logger.Debugf("Deleted host '%s'", id)
+		}
+	}()
+	res, err := c.db.ExecContext(ctx, "DELETE FROM hosts WHERE hostname = ?", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return core.ErrResourceNotFound
+	}
+	return nil
+}
+
+func (c *conn) ListHost(ctx context.Context) (hosts []core.Host, err error) {
+	rows, err := c.db.QueryContext(ctx, "SELECT "+hostColumns+" FROM hosts ORDER BY hostname")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		host, err := scanHost(rows)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
+
+// withTx runs fn within a transaction, which is rolled back if ctx is done
+// before it is committed.
+func (c *conn) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func getHost(ctx context.Context, q queryer, hostname string) (core.Host, error) {
+	host, err := scanHost(q.QueryRowContext(ctx, "SELECT "+hostColumns+" FROM hosts WHERE hostname = ?", hostname))
+	if err == sql.ErrNoRows {
+		return host, core.ErrResourceNotFound
+	}
+	return host, err
+}
+
+func scanHost(s scanner) (host core.Host, err error) {
+	var extraInfo, updatedAt string
+	err = s.Scan(
+		&host.GUID,
+		&host.Hostname,
+		&host.SSHAddress,
+		&host.SSHPort,
+		&host.SSHUser,
+		&host.IPMIAddress,
+		&host.IPMIUser,
+		&host.IPMIPassword,
+		&host.IPMISecret,
+		&host.CredentialProfile,
+		&extraInfo,
+		&updatedAt,
+	)
+	if err != nil {
+		return
+	}
+	if updatedAt != "" {
+		if host.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt); err != nil {
+			return
+		}
+	}
+	// rows are upgraded along with the database schema
+	host.SchemaVersion = core.HostSchemaVersion
+	err = json.Unmarshal([]byte(extraInfo), &host.ExtraInfo)
+	return
+}
+
+func insertHost(tx *sql.Tx, host core.Host) error {
+	if err := checkUniqueIndexes(tx, host); err != nil {
+		return err
+	}
+	return writeHost(tx, host, false)
+}
+
+func updateHost(tx *sql.Tx, host core.Host) error {
+	if err := checkUniqueIndexes(tx, host); err != nil {
+		return err
+	}
+	return writeHost(tx, host, true)
+}
+
+// writeHost inserts or updates the row of host without checking its unique
+// fields.
+func writeHost(tx *sql.Tx, host core.Host, exists bool) error {
+	extraInfo, err := marshalExtraInfo(host.ExtraInfo)
+	if err != nil {
+		return err
+	}
+	if exists {
+		_, err = tx.Exec(
+			`UPDATE hosts SET guid = ?, ssh_addr = ?, ssh_port = ?, ssh_user = ?,
+				ipmi_addr = ?, ipmi_user = ?, ipmi_pass = ?, ipmi_secret = ?, credential_profile = ?, extra_info = ?, updated_at = ?, serial = ?
+			WHERE hostname = ?`,
+			host.GUID,
+			host.SSHAddress,
+			host.SSHPort,
+			host.SSHUser,
+			host.IPMIAddress,
+			host.IPMIUser,
+			host.IPMIPassword,
+			host.IPMISecret,
+			host.CredentialProfile,
+			extraInfo,
+			formatTime(host.UpdatedAt),
+			core.IndexSerial.ValueOf(host),
+			host.Hostname,
+		)
+		return err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO hosts ("+hostColumns+", serial) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		host.GUID,
+		host.Hostname,
+		host.SSHAddress,
+		host.SSHPort,
+		host.SSHUser,
+		host.IPMIAddress,
+		host.IPMIUser,
+		host.IPMIPassword,
+		host.IPMISecret,
+		host.CredentialProfile,
+		extraInfo,
+		formatTime(host.UpdatedAt),
+		core.IndexSerial.ValueOf(host),
+	)
+	return err
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func marshalExtraInfo(info core.ExtendableFields) (string, error) {
+	if info == nil {
+		info = make(core.ExtendableFields)
+	}
+	b, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (c *conn) WatchHosts(ctx context.Context, fromRevision int64) (<-chan core.HostEvent, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) HostHistory(ctx context.Context, id string) ([]core.HostRevision, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) ListHostAt(ctx context.Context, revision int64) ([]core.Host, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) LockHost(ctx context.Context, lock core.HostLock, ttl time.Duration) (func() error, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) ListHostLocks(ctx context.Context) ([]core.HostLock, error) {
+	return nil, core.ErrNotSupported
+}
+
+func (c *conn) ForceUnlockHost(ctx context.Context, id string) error {
+	return core.ErrNotSupported
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// indexColumns maps indexed fields to their columns, which are indexed by
+// migration 4.
+var indexColumns = map[core.IndexField]string{
+	core.IndexGUID:        "guid",
+	core.IndexIPMIAddress: "ipmi_addr",
+	core.IndexSSHAddress:  "ssh_addr",
+	core.IndexSerial:      "serial",
+}
+
+// checkUniqueIndexes is enforced by queries rather than UNIQUE constraints,
+// so that existing databases with duplicated values could still be migrated.
+func checkUniqueIndexes(tx *sql.Tx, host core.Host) error {
+	for _, field := range core.IndexFields {
+		value := field.ValueOf(host)
+		if !field.Unique() || value == "" {
+			continue
+		}
+		var owner string
+		err := tx.QueryRow("SELECT hostname FROM hosts WHERE "+indexColumns[field]+" = ? AND hostname != ? LIMIT 1", value, host.Hostname).Scan(&owner)
+		if err == nil {
+			return core.ErrIndexConflict
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *conn) GetHostBy(ctx context.Context, field core.IndexField, value string) (host core.Host, err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during looking up host by '%s=%s' due to: %v", field, value, err)
+		} else {
+			c.logger.Debugf("Retrieved host '%s' by '%s=%s'", host.Hostname, field, value)
+		}
+	}()
+	column, ok := indexColumns[field]
+	if !ok || value == "" {
+		return host, core.ErrResourceNotFound
+	}
+	rows, err := c.db.QueryContext(ctx, "SELECT "+hostColumns+" FROM hosts WHERE "+column+" = ? ORDER BY hostname LIMIT 2", value)
+	if err != nil {
+		return host, err
+	}
+	defer rows.Close()
+	var hosts []core.Host
+	for rows.Next() {
+		each, err := scanHost(rows)
+		if err != nil {
+			return host, err
+		}
+		hosts = append(hosts, each)
+	}
+	if err = rows.Err(); err != nil {
+		return host, err
+	}
+	switch len(hosts) {
+	case 0:
+		return host, core.ErrResourceNotFound
+	case 1:
+		return hosts[0], nil
+	}
+	return host, core.ErrAmbiguousIndex
+}
+
+// BuildIndexes does nothing, as indexed columns are maintained by the
+// database itself.
+func (c *conn) BuildIndexes(ctx context.Context) error {
+	return ctx.Err()
+}
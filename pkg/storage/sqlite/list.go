@@ -0,0 +1,51 @@
+package sqlite
+
+import (
+	"context"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// ListHosts streams rows after the last returned hostname, and stops reading
+// as soon as the page is full. Revisions are not tracked by SQLite storage,
+// thus each page is read from the latest state.
+func (c *conn) ListHosts(ctx context.Context, opts core.ListOptions) (page core.HostPage, err error) {
+	if opts.Revision != 0 {
+		return page, core.ErrNotSupported
+	}
+	var after string
+	if opts.Continue != "" {
+		if _, after, err = core.DecodeContinue(opts.Continue); err != nil {
+			return page, err
+		}
+	}
+	rows, err := c.db.QueryContext(ctx, "SELECT "+hostColumns+" FROM hosts WHERE hostname > ? ORDER BY hostname", after)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		host, err := scanHost(rows)
+		if err != nil {
+			return core.HostPage{}, err
+		}
+		ok, err := core.MatchHost(host, opts.Predicates)
+		if err != nil {
+			return core.HostPage{}, err
+		}
+		if !ok {
+			continue
+		}
+		page.Hosts = append(page.Hosts, host)
+		if opts.Limit > 0 && int64(len(page.Hosts)) >= opts.Limit {
+			if rows.Next() {
+				page.Continue = core.EncodeContinue(0, host.Hostname)
+			}
+			break
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return core.HostPage{}, err
+	}
+	return page, nil
+}
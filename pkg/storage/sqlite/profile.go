@@ -0,0 +1,150 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	profileColumns = "name, type, user, pass, secret, updated_at"
+)
+
+func (c *conn) CreateCredentialProfile(ctx context.Context, profile core.CredentialProfile) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during creating credential profile '%s' due to: %v", profile.Name, err)
+		} else {
+			c.logger.Debugf("Created credential profile '%s'", profile.Name)
+		}
+	}()
+	profile.UpdatedAt = time.Now().UTC()
+	return c.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getProfile(ctx, tx, profile.Name); err != core.ErrResourceNotFound {
+			if err == nil {
+				return core.ErrResourceAlreadyExists
+			}
+			return err
+		}
+		return putProfile(tx, profile)
+	})
+}
+
+func (c *conn) GetCredentialProfile(ctx context.Context, name string) (profile core.CredentialProfile, err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during getting credential profile '%s' due to: %v", name, err)
+		} else {
+			c.logger.Debugf("Retrieved credential profile '%s'", name)
+		}
+	}()
+	return getProfile(ctx, c.db, name)
+}
+
+func (c *conn) ListCredentialProfiles(ctx context.Context) (profiles []core.CredentialProfile, err error) {
+	rows, err := c.db.QueryContext(ctx, "SELECT "+profileColumns+" FROM credential_profiles ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+func (c *conn) UpdateCredentialProfile(ctx context.Context, name string, updater func(profile core.CredentialProfile) (core.CredentialProfile, error)) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during updating credential profile '%s' due to: %v", name, err)
+		} else {
+			c.logger.Debugf("Updated credential profile '%s'", name)
+		}
+	}()
+	return c.withTx(ctx, func(tx *sql.Tx) error {
+		current, err := getProfile(ctx, tx, name)
+		if err != nil && err != core.ErrResourceNotFound {
+			return err
+		}
+		updated, err := updater(current)
+		if err != nil {
+			return err
+		}
+		updated.UpdatedAt = time.Now().UTC()
+		updated.Name = name
+		return putProfile(tx, updated)
+	})
+}
+
+func (c *conn) DeleteCredentialProfile(ctx context.Context, name string) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during deleting credential profile '%s' due to: %v", name, err)
+		} else {
+			c.logger.Debugf("Deleted credential profile '%s'", name)
+		}
+	}()
+	res, err := c.db.ExecContext(ctx, "DELETE FROM credential_profiles WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return core.ErrResourceNotFound
+	}
+	return nil
+}
+
+func getProfile(ctx context.Context, q queryer, name string) (core.CredentialProfile, error) {
+	profile, err := scanProfile(q.QueryRowContext(ctx, "SELECT "+profileColumns+" FROM credential_profiles WHERE name = ?", name))
+	if err == sql.ErrNoRows {
+		return profile, core.ErrResourceNotFound
+	}
+	return profile, err
+}
+
+func scanProfile(s scanner) (profile core.CredentialProfile, err error) {
+	var updatedAt string
+	err = s.Scan(
+		&profile.Name,
+		&profile.Type,
+		&profile.User,
+		&profile.Password,
+		&profile.Secret,
+		&updatedAt,
+	)
+	if err != nil || updatedAt == "" {
+		return
+	}
+	profile.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt)
+	return
+}
+
+func putProfile(tx *sql.Tx, profile core.CredentialProfile) error {
+	_, err := tx.Exec(
+		"INSERT OR REPLACE INTO credential_profiles ("+profileColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+		profile.Name,
+		profile.Type,
+		profile.User,
+		profile.Password,
+		profile.Secret,
+		formatTime(profile.UpdatedAt),
+	)
+	return err
+}
@@ -0,0 +1,39 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// RenameHost updates the hostname column in place, so that the row keeps its
+// id and GUID.
+func (c *conn) RenameHost(ctx context.Context, id, newID string) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during renaming host '%s' to '%s' due to: %v", id, newID, err)
+		} else {
+			c.logger.Debugf("Renamed host '%s' to '%s'", id, newID)
+		}
+	}()
+	if newID == "" {
+		return fmt.Errorf("New hostname must not be empty")
+	}
+	return c.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := getHost(ctx, tx, id); err != nil {
+			return err
+		}
+		if _, err := getHost(ctx, tx, newID); err != core.ErrResourceNotFound {
+			if err == nil {
+				return core.ErrResourceAlreadyExists
+			}
+			return err
+		}
+		_, err := tx.Exec("UPDATE hosts SET hostname = ?, updated_at = ? WHERE hostname = ?", newID, formatTime(time.Now().UTC()), id)
+		return err
+	})
+}
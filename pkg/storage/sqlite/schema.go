@@ -0,0 +1,153 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	zap "go.uber.org/zap"
+)
+
+// migration applies a single schema change within given transaction.
+type migration func(tx *sql.Tx) error
+
+// statement is a migration consisting of plain SQL statements.
+func statement(stmt string) migration {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(stmt)
+		return err
+	}
+}
+
+// migrations holds all schema changes in the order they have to be applied.
+// The schema version of a database is tracked by 'PRAGMA user_version', which
+// equals to the number of applied migrations. Existing migrations must never
+// be modified, append a new one instead.
+var migrations = []migration{
+	// 1: initial schema
+	statement(`CREATE TABLE hosts (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		guid       TEXT NOT NULL UNIQUE,
+		hostname   TEXT NOT NULL UNIQUE,
+		ssh_addr   TEXT NOT NULL DEFAULT '',
+		ssh_port   INTEGER NOT NULL DEFAULT 0,
+		ssh_user   TEXT NOT NULL DEFAULT '',
+		ipmi_addr  TEXT NOT NULL DEFAULT '',
+		ipmi_user  TEXT NOT NULL DEFAULT '',
+		ipmi_pass  TEXT NOT NULL DEFAULT '',
+		extra_info TEXT NOT NULL DEFAULT '{}'
+	)`),
+	// 2: modification time of hosts in RFC3339 format
+	statement(`ALTER TABLE hosts ADD COLUMN updated_at TEXT NOT NULL DEFAULT ''`),
+	// 3: trash bin of soft deleted hosts, which are kept as JSON documents
+	statement(`CREATE TABLE trash (
+		hostname   TEXT PRIMARY KEY,
+		host       TEXT NOT NULL,
+		deleted_at TEXT NOT NULL,
+		reason     TEXT NOT NULL DEFAULT ''
+	)`),
+	// 4: secondary indexes, where serial is copied out of extra_info
+	addSerialColumn,
+	// 5: references to IPMI passwords kept outside of the database
+	statement(`ALTER TABLE hosts ADD COLUMN ipmi_secret TEXT NOT NULL DEFAULT ''`),
+	// 6: credential profiles shared by hosts
+	statement(`
+		CREATE TABLE credential_profiles (
+			name       TEXT PRIMARY KEY,
+			type       TEXT NOT NULL DEFAULT '',
+			user       TEXT NOT NULL DEFAULT '',
+			pass       TEXT NOT NULL DEFAULT '',
+			secret     TEXT NOT NULL DEFAULT '',
+			updated_at TEXT NOT NULL DEFAULT ''
+		);
+		ALTER TABLE hosts ADD COLUMN credential_profile TEXT NOT NULL DEFAULT '';`),
+}
+
+func addSerialColumn(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		ALTER TABLE hosts ADD COLUMN serial TEXT NOT NULL DEFAULT '';
+		CREATE INDEX hosts_ipmi_addr ON hosts (ipmi_addr);
+		CREATE INDEX hosts_ssh_addr ON hosts (ssh_addr);
+		CREATE INDEX hosts_serial ON hosts (serial);`)
+	if err != nil {
+		return err
+	}
+	rows, err := tx.Query("SELECT hostname, extra_info FROM hosts")
+	if err != nil {
+		return err
+	}
+	serials := make(map[string]string)
+	for rows.Next() {
+		var hostname, extraInfo string
+		if err = rows.Scan(&hostname, &extraInfo); err != nil {
+			rows.Close()
+			return err
+		}
+		var host core.Host
+		if err = json.Unmarshal([]byte(extraInfo), &host.ExtraInfo); err != nil {
+			rows.Close()
+			return err
+		}
+		if serial := core.IndexSerial.ValueOf(host); serial != "" {
+			serials[hostname] = serial
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for hostname, serial := range serials {
+		if _, err = tx.Exec("UPDATE hosts SET serial = ? WHERE hostname = ?", serial, hostname); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrate brings the schema of given database up to date. Each migration is
+// applied in its own transaction together with the version bump.
+func migrate(db *sql.DB, logger *zap.SugaredLogger) error {
+	defer logger.Sync()
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("Database schema version %d is newer than the supported version %d", version, len(migrations))
+	}
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err = migrations[i](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Could not apply schema migration %d due to: %v", i+1, err)
+		}
+		// PRAGMA does not accept bind parameters
+		if _, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+		logger.Debugf("Applied schema migration %d", i+1)
+	}
+	return nil
+}
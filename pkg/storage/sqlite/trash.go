@@ -0,0 +1,146 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+func (c *conn) TrashHost(ctx context.Context, id, reason string) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during trashing host '%s' due to: %v", id, err)
+		} else {
+			c.logger.Debugf("Moved host '%s' to trash", id)
+		}
+	}()
+	return c.withTx(ctx, func(tx *sql.Tx) error {
+		if err := c.purgeTrash(tx); err != nil {
+			return err
+		}
+		host, err := getHost(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(host)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec("DELETE FROM hosts WHERE hostname = ?", id); err != nil {
+			return err
+		}
+		// A previously trashed host with the same name is superseded.
+		_, err = tx.Exec(
+			"INSERT OR REPLACE INTO trash (hostname, host, deleted_at, reason) VALUES (?, ?, ?, ?)",
+			id,
+			string(b),
+			formatTime(time.Now().UTC()),
+			reason,
+		)
+		return err
+	})
+}
+
+func (c *conn) ListTrash(ctx context.Context) (trashed []core.TrashedHost, err error) {
+	err = c.withTx(ctx, func(tx *sql.Tx) error {
+		if err := c.purgeTrash(tx); err != nil {
+			return err
+		}
+		trashed, err = listTrash(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return trashed, nil
+}
+
+func (c *conn) RestoreHost(ctx context.Context, id string) (err error) {
+	defer func() {
+		defer c.logger.Sync()
+		if err != nil {
+			c.logger.Errorf("Error occurred during restoring host '%s' due to: %v", id, err)
+		} else {
+			c.logger.Debugf("Restored host '%s' from trash", id)
+		}
+	}()
+	return c.withTx(ctx, func(tx *sql.Tx) error {
+		if err := c.purgeTrash(tx); err != nil {
+			return err
+		}
+		trashed, err := scanTrashedHost(tx.QueryRow("SELECT host, deleted_at, reason FROM trash WHERE hostname = ?", id))
+		if err == sql.ErrNoRows {
+			return core.ErrResourceNotFound
+		} else if err != nil {
+			return err
+		}
+		if _, err = getHost(ctx, tx, id); err != core.ErrResourceNotFound {
+			if err == nil {
+				return core.ErrResourceAlreadyExists
+			}
+			return err
+		}
+		host := trashed.Host
+		host.UpdatedAt = time.Now().UTC()
+		if err = insertHost(tx, host); err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM trash WHERE hostname = ?", id)
+		return err
+	})
+}
+
+// purgeTrash removes trashed hosts which have exceeded the retention period
+// within given transaction.
+func (c *conn) purgeTrash(tx *sql.Tx) error {
+	trashed, err := listTrash(tx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, each := range trashed {
+		if !each.Expired(c.trashRetention, now) {
+			continue
+		}
+		if _, err = tx.Exec("DELETE FROM trash WHERE hostname = ?", each.Host.Hostname); err != nil {
+			return err
+		}
+		c.logger.Debugf("Purged expired host '%s' from trash", each.Host.Hostname)
+	}
+	return nil
+}
+
+func listTrash(tx *sql.Tx) (trashed []core.TrashedHost, err error) {
+	rows, err := tx.Query("SELECT host, deleted_at, reason FROM trash ORDER BY hostname")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		each, err := scanTrashedHost(rows)
+		if err != nil {
+			return nil, err
+		}
+		trashed = append(trashed, each)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return trashed, nil
+}
+
+func scanTrashedHost(s scanner) (trashed core.TrashedHost, err error) {
+	var host, deletedAt string
+	if err = s.Scan(&host, &deletedAt, &trashed.Reason); err != nil {
+		return
+	}
+	if trashed.DeletedAt, err = time.Parse(time.RFC3339Nano, deletedAt); err != nil {
+		return
+	}
+	err = json.Unmarshal([]byte(host), &trashed.Host)
+	return
+}
@@ -22,8 +22,10 @@ import (
 	"os"
 	"path/filepath"
 
+	cache "github.com/universonic/ivy-utils/pkg/storage/cache"
 	core "github.com/universonic/ivy-utils/pkg/storage/core"
-	etcd "github.com/universonic/ivy-utils/pkg/storage/etcd"
+	encryption "github.com/universonic/ivy-utils/pkg/storage/encryption"
+	metrics "github.com/universonic/ivy-utils/pkg/storage/metrics"
 	zap "go.uber.org/zap"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -31,6 +33,13 @@ import (
 type StorageConfig struct {
 	Adapter string                 `json:"adapter,omitempty" yaml:"adapter"`
 	Config  map[string]interface{} `json:"config,omitempty" yaml:"config"`
+	// Encryption enables encryption of sensitive fields at rest.
+	Encryption *encryption.Config `json:"encryption,omitempty" yaml:"encryption,omitempty"`
+	// Cache serves hosts from memory, which suits long-running processes.
+	Cache *cache.Config `json:"cache,omitempty" yaml:"cache,omitempty"`
+	// Metrics records operations of the adapter, which excludes reads
+	// served by the cache.
+	Metrics *metrics.Config `json:"metrics,omitempty" yaml:"metrics,omitempty"`
 }
 
 func NewStorageConfig() *StorageConfig {
@@ -39,18 +48,19 @@ func NewStorageConfig() *StorageConfig {
 	}
 }
 
-// NewStorageFromConfigBytes spawn storage with config from given json bytes
-func NewStorageFromConfigBytes(config []byte, logger *zap.SugaredLogger) (core.Storage, error) {
+// NewStorageConfigFromBytes parses storage configuration from given json bytes
+func NewStorageConfigFromBytes(config []byte) (*StorageConfig, error) {
 	cfg := NewStorageConfig()
 	err := json.Unmarshal(config, cfg)
 	if err != nil {
 		return nil, err
 	}
-	return NewStorage(cfg, logger)
+	return cfg, nil
 }
 
-// NewStorageFromConfigFile is a shorthand of NewStorage
-func NewStorageFromConfigFile(fp string, logger *zap.SugaredLogger) (core.Storage, error) {
+// NewStorageConfigFromFile parses storage configuration from given file in
+// YAML or JSON format
+func NewStorageConfigFromFile(fp string) (*StorageConfig, error) {
 	fi, err := os.Open(fp)
 	if err != nil {
 		return nil, err
@@ -75,28 +85,69 @@ func NewStorageFromConfigFile(fp string, logger *zap.SugaredLogger) (core.Storag
 	if err != nil {
 		return nil, err
 	}
-	return NewStorage(qualifiedConfig, logger)
+	return qualifiedConfig, nil
+}
+
+// NewStorageFromConfigBytes spawn storage with config from given json bytes
+func NewStorageFromConfigBytes(config []byte, logger *zap.SugaredLogger) (core.Storage, error) {
+	cfg, err := NewStorageConfigFromBytes(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewStorage(cfg, logger)
+}
+
+// NewStorageFromConfigFile is a shorthand of NewStorage
+func NewStorageFromConfigFile(fp string, logger *zap.SugaredLogger) (core.Storage, error) {
+	cfg, err := NewStorageConfigFromFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	return NewStorage(cfg, logger)
 }
 
 // NewStorage is a helper which creates a database connection instance and returns
 // any encountered error.
 func NewStorage(config *StorageConfig, logger *zap.SugaredLogger) (core.Storage, error) {
-	switch config.Adapter {
-	case "etcd":
-		etcd := etcd.New()
-		b, err := json.Marshal(config.Config)
-		if err != nil {
+	adapter, ok := LookupAdapter(config.Adapter)
+	if !ok {
+		return nil, ErrUnknownStorageAdapter
+	}
+	// Keys are loaded ahead, so that a missing key fails fast without
+	// touching the database.
+	var keyring *encryption.Keyring
+	if config.Encryption != nil {
+		var err error
+		if keyring, err = config.Encryption.Keyring(); err != nil {
 			return nil, err
 		}
-		err = json.Unmarshal(b, etcd)
+	}
+	storage, err := adapter.Factory(config.Config, logger)
+	if err != nil {
+		return nil, err
+	}
+	if config.Metrics != nil && config.Metrics.Enabled {
+		collectors, err := metrics.DefaultCollectors()
 		if err != nil {
+			storage.Close()
 			return nil, err
 		}
-		storage, err := etcd.Open(logger)
+		var tracer metrics.Tracer
+		if config.Metrics.Tracing {
+			tracer = metrics.GlobalTracer()
+		}
+		storage = metrics.Wrap(storage, config.Adapter, collectors, tracer)
+	}
+	// Cached hosts stay encrypted, since the cache is wrapped by encryption.
+	if config.Cache != nil && config.Cache.Enabled {
+		cached, err := cache.Wrap(storage, config.Cache, logger)
 		if err != nil {
+			storage.Close()
 			return nil, err
 		}
-		return storage, nil
+		storage = cached
 	}
-	return nil, ErrUnknownStorageAdapter
+	// Storage is always wrapped, so that encrypted values are reported
+	// clearly even if encryption is not configured.
+	return encryption.Wrap(storage, keyring), nil
 }
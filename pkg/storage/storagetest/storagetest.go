@@ -0,0 +1,492 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storagetest is a conformance suite of core.Storage, which adapters
+// run from their own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) core.Storage {
+//			storage, err := New().Open(zap.NewNop().Sugar())
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return storage
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	defaultLargeListSize = 500
+	defaultConcurrency   = 8
+	defaultPageSize      = 7
+)
+
+// Factory opens an empty storage for a single case, which is closed by the
+// suite afterwards.
+type Factory func(t *testing.T) core.Storage
+
+// Suite checks semantics shared by all storages.
+type Suite struct {
+	Open Factory
+	// LargeListSize is the number of hosts listed by the large list case.
+	LargeListSize int
+	// Concurrency is the number of goroutines updating the same host.
+	Concurrency int
+}
+
+// Run runs the suite against storages opened by open with default options.
+func Run(t *testing.T, open Factory) {
+	(&Suite{Open: open}).Run(t)
+}
+
+// Run runs each case of the suite as a subtest of t.
+func (in *Suite) Run(t *testing.T) {
+	cases := []struct {
+		name string
+		fn   func(t *testing.T, storage core.Storage)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"CreateKeepsValidGUID", testCreateKeepsValidGUID},
+		{"CreateDuplicate", testCreateDuplicate},
+		{"CreateIndexConflict", testCreateIndexConflict},
+		{"AbsentHost", testAbsentHost},
+		{"ListSorted", testListSorted},
+		{"Update", testUpdate},
+		{"UpdaterError", testUpdaterError},
+		{"UpdateAbsent", testUpdateAbsent},
+		{"Delete", testDelete},
+		{"GetHostBy", testGetHostBy},
+		{"Revision", testRevision},
+		{"CanceledContext", testCanceledContext},
+		{"ConcurrentUpdate", in.testConcurrentUpdate},
+		{"LargeList", in.testLargeList},
+	}
+	for _, each := range cases {
+		fn := each.fn
+		t.Run(each.name, func(t *testing.T) {
+			storage := in.Open(t)
+			defer storage.Close()
+			fn(t, storage)
+		})
+	}
+}
+
+func newHost(hostname, ipmiAddress string) core.Host {
+	host := core.NewHost()
+	host.Hostname = hostname
+	host.SSHAddress = "192.168.0.1"
+	host.SSHUser = "root"
+	host.IPMIAddress = ipmiAddress
+	host.IPMIUser = "admin"
+	host.ExtraInfo["comment"] = "conformance"
+	return *host
+}
+
+func mustCreate(t *testing.T, storage core.Storage, host core.Host) {
+	if err := storage.CreateHost(context.Background(), host); err != nil {
+		t.Fatalf("Could not create host '%s' due to: %v", host.Hostname, err)
+	}
+}
+
+func mustGet(t *testing.T, storage core.Storage, hostname string) core.Host {
+	host, err := storage.GetHost(context.Background(), hostname)
+	if err != nil {
+		t.Fatalf("Could not get host '%s' due to: %v", hostname, err)
+	}
+	return host
+}
+
+func expectErr(t *testing.T, op string, got, want error) {
+	if got != want {
+		t.Fatalf("%s returned error '%v', expected '%v'", op, got, want)
+	}
+}
+
+// assertFields compares fields that storages must keep as they are.
+func assertFields(t *testing.T, got, want core.Host) {
+	pairs := [][3]string{
+		{"hostname", got.Hostname, want.Hostname},
+		{"ssh address", got.SSHAddress, want.SSHAddress},
+		{"ssh user", got.SSHUser, want.SSHUser},
+		{"ipmi address", got.IPMIAddress, want.IPMIAddress},
+		{"ipmi user", got.IPMIUser, want.IPMIUser},
+		{"comment", fmt.Sprint(got.ExtraInfo["comment"]), fmt.Sprint(want.ExtraInfo["comment"])},
+	}
+	for _, each := range pairs {
+		if each[1] != each[2] {
+			t.Fatalf("Host has %s '%s', expected '%s'", each[0], each[1], each[2])
+		}
+	}
+	if got.SSHPort != want.SSHPort {
+		t.Fatalf("Host has ssh port %d, expected %d", got.SSHPort, want.SSHPort)
+	}
+}
+
+func testCreateAndGet(t *testing.T, storage core.Storage) {
+	want := newHost("node-01", "10.0.0.1")
+	mustCreate(t, storage, want)
+	got := mustGet(t, storage, "node-01")
+	assertFields(t, got, want)
+	if _, err := uuid.FromString(got.GUID); err != nil {
+		t.Fatalf("Host was assigned an invalid GUID '%s'", got.GUID)
+	}
+	if got.UpdatedAt.IsZero() {
+		t.Fatalf("Host was not stamped with the update time")
+	}
+}
+
+func testCreateKeepsValidGUID(t *testing.T, storage core.Storage) {
+	host := newHost("node-01", "10.0.0.1")
+	host.GUID = uuid.NewV4().String()
+	mustCreate(t, storage, host)
+	if got := mustGet(t, storage, "node-01"); got.GUID != host.GUID {
+		t.Fatalf("Host has GUID '%s', expected the given '%s'", got.GUID, host.GUID)
+	}
+	invalid := newHost("node-02", "10.0.0.2")
+	invalid.GUID = "not-a-uuid"
+	mustCreate(t, storage, invalid)
+	if got := mustGet(t, storage, "node-02"); got.GUID == invalid.GUID {
+		t.Fatalf("Host kept the invalid GUID '%s'", got.GUID)
+	}
+}
+
+func testCreateDuplicate(t *testing.T, storage core.Storage) {
+	mustCreate(t, storage, newHost("node-01", "10.0.0.1"))
+	err := storage.CreateHost(context.Background(), newHost("node-01", "10.0.0.2"))
+	expectErr(t, "Creating a taken hostname", err, core.ErrResourceAlreadyExists)
+	if got := mustGet(t, storage, "node-01"); got.IPMIAddress != "10.0.0.1" {
+		t.Fatalf("Existing host was overwritten by a duplicate")
+	}
+}
+
+func testCreateIndexConflict(t *testing.T, storage core.Storage) {
+	first := newHost("node-01", "10.0.0.1")
+	first.GUID = uuid.NewV4().String()
+	mustCreate(t, storage, first)
+	err := storage.CreateHost(context.Background(), newHost("node-02", "10.0.0.1"))
+	expectErr(t, "Creating a host with a taken IPMI address", err, core.ErrIndexConflict)
+	second := newHost("node-03", "10.0.0.3")
+	second.GUID = first.GUID
+	err = storage.CreateHost(context.Background(), second)
+	expectErr(t, "Creating a host with a taken GUID", err, core.ErrIndexConflict)
+	for _, each := range []string{"node-02", "node-03"} {
+		_, err = storage.GetHost(context.Background(), each)
+		expectErr(t, "Getting a host rejected by conflict", err, core.ErrResourceNotFound)
+	}
+}
+
+func testAbsentHost(t *testing.T, storage core.Storage) {
+	_, err := storage.GetHost(context.Background(), "absent")
+	expectErr(t, "Getting an absent host", err, core.ErrResourceNotFound)
+	err = storage.DeleteHost(context.Background(), "absent")
+	expectErr(t, "Deleting an absent host", err, core.ErrResourceNotFound)
+}
+
+func testListSorted(t *testing.T, storage core.Storage) {
+	hosts, err := storage.ListHost(context.Background())
+	if err != nil {
+		t.Fatalf("Could not list hosts due to: %v", err)
+	}
+	if len(hosts) != 0 {
+		t.Fatalf("Empty storage listed %d hosts", len(hosts))
+	}
+	for i, each := range []string{"node-03", "node-01", "node-02"} {
+		mustCreate(t, storage, newHost(each, fmt.Sprintf("10.0.0.%d", i+1)))
+	}
+	if hosts, err = storage.ListHost(context.Background()); err != nil {
+		t.Fatalf("Could not list hosts due to: %v", err)
+	}
+	assertHostnames(t, hosts, []string{"node-01", "node-02", "node-03"})
+}
+
+func assertHostnames(t *testing.T, hosts []core.Host, want []string) {
+	if len(hosts) != len(want) {
+		t.Fatalf("Listed %d hosts, expected %d", len(hosts), len(want))
+	}
+	for i := range want {
+		if hosts[i].Hostname != want[i] {
+			t.Fatalf("Listed host '%s' at %d, expected '%s'", hosts[i].Hostname, i, want[i])
+		}
+	}
+}
+
+func testUpdate(t *testing.T, storage core.Storage) {
+	mustCreate(t, storage, newHost("node-01", "10.0.0.1"))
+	created := mustGet(t, storage, "node-01")
+	var seen core.Host
+	err := storage.UpdateHost(context.Background(), "node-01", func(current core.Host) (core.Host, error) {
+		seen = current
+		current.IPMIAddress = "10.0.0.9"
+		current.SSHPort = 2222
+		// storages keep the hostname regardless
+		current.Hostname = "renamed"
+		return current, nil
+	})
+	if err != nil {
+		t.Fatalf("Could not update host due to: %v", err)
+	}
+	assertFields(t, seen, created)
+	got := mustGet(t, storage, "node-01")
+	want := created
+	want.IPMIAddress = "10.0.0.9"
+	want.SSHPort = 2222
+	assertFields(t, got, want)
+	if got.GUID != created.GUID {
+		t.Fatalf("Update changed GUID from '%s' to '%s'", created.GUID, got.GUID)
+	}
+	if got.UpdatedAt.Before(created.UpdatedAt) {
+		t.Fatalf("Update time went back from %v to %v", created.UpdatedAt, got.UpdatedAt)
+	}
+	_, err = storage.GetHost(context.Background(), "renamed")
+	expectErr(t, "Getting a hostname set by updater", err, core.ErrResourceNotFound)
+	// an invalid GUID given by updater is replaced
+	err = storage.UpdateHost(context.Background(), "node-01", func(current core.Host) (core.Host, error) {
+		current.GUID = "not-a-uuid"
+		return current, nil
+	})
+	if err != nil {
+		t.Fatalf("Could not update host due to: %v", err)
+	}
+	if got = mustGet(t, storage, "node-01"); got.GUID == "not-a-uuid" {
+		t.Fatalf("Host kept the invalid GUID given by updater")
+	} else if _, err = uuid.FromString(got.GUID); err != nil {
+		t.Fatalf("Host was assigned an invalid GUID '%s'", got.GUID)
+	}
+}
+
+func testUpdaterError(t *testing.T, storage core.Storage) {
+	mustCreate(t, storage, newHost("node-01", "10.0.0.1"))
+	want := fmt.Errorf("updater failed")
+	err := storage.UpdateHost(context.Background(), "node-01", func(current core.Host) (core.Host, error) {
+		current.IPMIAddress = "10.0.0.9"
+		return current, want
+	})
+	expectErr(t, "Updating with a failing updater", err, want)
+	if got := mustGet(t, storage, "node-01"); got.IPMIAddress != "10.0.0.1" {
+		t.Fatalf("Failed update was applied")
+	}
+}
+
+// testUpdateAbsent checks that updater of an absent host receives a host
+// without hostname, and that an error of updater leaves it absent.
+func testUpdateAbsent(t *testing.T, storage core.Storage) {
+	err := storage.UpdateHost(context.Background(), "absent", func(current core.Host) (core.Host, error) {
+		if current.Hostname != "" {
+			t.Errorf("Updater of an absent host received hostname '%s'", current.Hostname)
+		}
+		return current, core.ErrResourceNotFound
+	})
+	expectErr(t, "Updating an absent host", err, core.ErrResourceNotFound)
+	_, err = storage.GetHost(context.Background(), "absent")
+	expectErr(t, "Getting an absent host after update", err, core.ErrResourceNotFound)
+}
+
+func testDelete(t *testing.T, storage core.Storage) {
+	mustCreate(t, storage, newHost("node-01", "10.0.0.1"))
+	mustCreate(t, storage, newHost("node-02", "10.0.0.2"))
+	if err := storage.DeleteHost(context.Background(), "node-01"); err != nil {
+		t.Fatalf("Could not delete host due to: %v", err)
+	}
+	_, err := storage.GetHost(context.Background(), "node-01")
+	expectErr(t, "Getting a deleted host", err, core.ErrResourceNotFound)
+	hosts, err := storage.ListHost(context.Background())
+	if err != nil {
+		t.Fatalf("Could not list hosts due to: %v", err)
+	}
+	assertHostnames(t, hosts, []string{"node-02"})
+	// unique values of a deleted host are released
+	mustCreate(t, storage, newHost("node-03", "10.0.0.1"))
+	mustCreate(t, storage, newHost("node-01", "10.0.0.4"))
+}
+
+func testGetHostBy(t *testing.T, storage core.Storage) {
+	mustCreate(t, storage, newHost("node-01", "10.0.0.1"))
+	mustCreate(t, storage, newHost("node-02", "10.0.0.2"))
+	host, err := storage.GetHostBy(context.Background(), core.IndexIPMIAddress, "10.0.0.2")
+	if err != nil {
+		t.Fatalf("Could not get host by IPMI address due to: %v", err)
+	}
+	if host.Hostname != "node-02" {
+		t.Fatalf("Got host '%s' by IPMI address, expected 'node-02'", host.Hostname)
+	}
+	created := mustGet(t, storage, "node-01")
+	if host, err = storage.GetHostBy(context.Background(), core.IndexGUID, created.GUID); err != nil || host.Hostname != "node-01" {
+		t.Fatalf("Could not get host by GUID, got '%s' with error: %v", host.Hostname, err)
+	}
+	_, err = storage.GetHostBy(context.Background(), core.IndexIPMIAddress, "10.0.0.9")
+	expectErr(t, "Getting host by an absent IPMI address", err, core.ErrResourceNotFound)
+	_, err = storage.GetHostBy(context.Background(), core.IndexSSHAddress, "192.168.0.1")
+	expectErr(t, "Getting host by a shared SSH address", err, core.ErrAmbiguousIndex)
+	err = storage.UpdateHost(context.Background(), "node-02", func(current core.Host) (core.Host, error) {
+		current.IPMIAddress = "10.0.0.3"
+		return current, nil
+	})
+	if err != nil {
+		t.Fatalf("Could not update host due to: %v", err)
+	}
+	_, err = storage.GetHostBy(context.Background(), core.IndexIPMIAddress, "10.0.0.2")
+	expectErr(t, "Getting host by a replaced IPMI address", err, core.ErrResourceNotFound)
+	if host, err = storage.GetHostBy(context.Background(), core.IndexIPMIAddress, "10.0.0.3"); err != nil || host.Hostname != "node-02" {
+		t.Fatalf("Could not get host by updated IPMI address, got '%s' with error: %v", host.Hostname, err)
+	}
+	// building indexes of hosts which are indexed already changes nothing
+	if err = storage.BuildIndexes(context.Background()); err != nil {
+		t.Fatalf("Could not build indexes due to: %v", err)
+	}
+	if host, err = storage.GetHostBy(context.Background(), core.IndexIPMIAddress, "10.0.0.3"); err != nil || host.Hostname != "node-02" {
+		t.Fatalf("Could not get host by IPMI address after building indexes, got '%s' with error: %v", host.Hostname, err)
+	}
+}
+
+// testRevision checks that revisions grow with updates, for storages which
+// track them.
+func testRevision(t *testing.T, storage core.Storage) {
+	mustCreate(t, storage, newHost("node-01", "10.0.0.1"))
+	created := mustGet(t, storage, "node-01")
+	if created.Revision == 0 {
+		t.Skip("Revisions are not tracked")
+	}
+	err := storage.UpdateHost(context.Background(), "node-01", func(current core.Host) (core.Host, error) {
+		if current.Revision != created.Revision {
+			t.Errorf("Updater received revision %d, expected %d", current.Revision, created.Revision)
+		}
+		current.SSHPort = 2222
+		return current, nil
+	})
+	if err != nil {
+		t.Fatalf("Could not update host due to: %v", err)
+	}
+	updated := mustGet(t, storage, "node-01")
+	if updated.Revision <= created.Revision {
+		t.Fatalf("Revision did not grow from %d after update, got %d", created.Revision, updated.Revision)
+	}
+	hosts, err := storage.ListHost(context.Background())
+	if err != nil {
+		t.Fatalf("Could not list hosts due to: %v", err)
+	}
+	if hosts[0].Revision != updated.Revision {
+		t.Fatalf("Listed revision %d, expected %d", hosts[0].Revision, updated.Revision)
+	}
+}
+
+func testCanceledContext(t *testing.T, storage core.Storage) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := storage.CreateHost(ctx, newHost("node-01", "10.0.0.1")); err == nil {
+		t.Fatalf("Host was created with a canceled context")
+	}
+	_, err := storage.GetHost(context.Background(), "node-01")
+	expectErr(t, "Getting a host created with a canceled context", err, core.ErrResourceNotFound)
+	if _, err = storage.ListHost(ctx); err == nil {
+		t.Fatalf("Hosts were listed with a canceled context")
+	}
+}
+
+// testConcurrentUpdate checks that no update is lost. Storages with
+// optimistic concurrency may give up with core.ErrConcurrentUpdate, in which
+// case the update must not be applied.
+func (in *Suite) testConcurrentUpdate(t *testing.T, storage core.Storage) {
+	concurrency := in.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	mustCreate(t, storage, newHost("node-01", "10.0.0.1"))
+	const rounds = 5
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		failures  []error
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				err := storage.UpdateHost(context.Background(), "node-01", func(current core.Host) (core.Host, error) {
+					current.SSHPort++
+					return current, nil
+				})
+				mu.Lock()
+				if err == nil {
+					succeeded++
+				} else if err != core.ErrConcurrentUpdate {
+					failures = append(failures, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(failures) > 0 {
+		t.Fatalf("Concurrent updates failed due to: %v", failures[0])
+	}
+	want := newHost("node-01", "10.0.0.1").SSHPort + uint16(succeeded)
+	if got := mustGet(t, storage, "node-01"); got.SSHPort != want {
+		t.Fatalf("Lost updates: ssh port is %d after %d updates, expected %d", got.SSHPort, succeeded, want)
+	}
+}
+
+func (in *Suite) testLargeList(t *testing.T, storage core.Storage) {
+	size := in.LargeListSize
+	if size <= 0 {
+		size = defaultLargeListSize
+	}
+	var want []string
+	for i := 0; i < size; i++ {
+		host := newHost(fmt.Sprintf("node-%05d", i), fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff))
+		mustCreate(t, storage, host)
+		want = append(want, host.Hostname)
+	}
+	sort.Strings(want)
+	start := time.Now()
+	hosts, err := storage.ListHost(context.Background())
+	if err != nil {
+		t.Fatalf("Could not list hosts due to: %v", err)
+	}
+	t.Logf("Listed %d hosts in %v", len(hosts), time.Since(start))
+	assertHostnames(t, hosts, want)
+	var paged []core.Host
+	opts := core.ListOptions{Limit: defaultPageSize}
+	for pages := 0; ; pages++ {
+		if pages > size {
+			t.Fatalf("Listing by pages did not end after %d pages", pages)
+		}
+		page, err := storage.ListHosts(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Could not list hosts by pages due to: %v", err)
+		}
+		if int64(len(page.Hosts)) > opts.Limit {
+			t.Fatalf("Page has %d hosts beyond the limit %d", len(page.Hosts), opts.Limit)
+		}
+		paged = append(paged, page.Hosts...)
+		if page.Continue == "" {
+			break
+		}
+		opts.Continue = page.Continue
+	}
+	assertHostnames(t, paged, want)
+}
@@ -0,0 +1,207 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	backupFormatVersion = 2
+	backupManifestFile  = "manifest.json"
+	backupHostsFile     = "hosts.json"
+	backupTrashFile     = "trash.json"
+	backupProfilesFile  = "profiles.json"
+)
+
+// BackupManifest describes the content of a backup archive, where Checksums
+// maps each file of the archive to its SHA-256 digest. Archives of format
+// version 1 have no trashed hosts.
+type BackupManifest struct {
+	FormatVersion      int               `json:"format_version"`
+	CreatedAt          time.Time         `json:"created_at"`
+	HostSchemaVersion  int               `json:"host_schema_version"`
+	Hosts              int               `json:"hosts"`
+	TrashedHosts       int               `json:"trashed_hosts"`
+	CredentialProfiles int               `json:"credential_profiles"`
+	Checksums          map[string]string `json:"checksums"`
+}
+
+// Backup is the verified content of a backup archive.
+type Backup struct {
+	Manifest           BackupManifest
+	Hosts              []core.Host
+	TrashedHosts       []core.TrashedHost
+	CredentialProfiles []core.CredentialProfile
+}
+
+// Backup writes all hosts, trashed hosts and credential profiles into w as a
+// gzipped tar archive. History is not included. Sensitive fields are written
+// as they are returned by storage, thus they are in plain text if the storage
+// decrypts them.
+func (in *Inventory) Backup(ctx context.Context, w io.Writer) (*BackupManifest, error) {
+	hosts, err := in.Select(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	trashed, err := in.Storage.ListTrash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := in.Storage.ListCredentialProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if hosts == nil {
+		hosts = []core.Host{}
+	}
+	if trashed == nil {
+		trashed = []core.TrashedHost{}
+	}
+	if profiles == nil {
+		profiles = []core.CredentialProfile{}
+	}
+	manifest := &BackupManifest{
+		FormatVersion:      backupFormatVersion,
+		CreatedAt:          time.Now().UTC(),
+		HostSchemaVersion:  core.HostSchemaVersion,
+		Hosts:              len(hosts),
+		TrashedHosts:       len(trashed),
+		CredentialProfiles: len(profiles),
+		Checksums:          make(map[string]string),
+	}
+	files := make(map[string][]byte)
+	if files[backupHostsFile], err = json.MarshalIndent(hosts, "", "  "); err != nil {
+		return nil, err
+	}
+	if files[backupTrashFile], err = json.MarshalIndent(trashed, "", "  "); err != nil {
+		return nil, err
+	}
+	if files[backupProfilesFile], err = json.MarshalIndent(profiles, "", "  "); err != nil {
+		return nil, err
+	}
+	for name, b := range files {
+		manifest.Checksums[name] = checksum(b)
+	}
+	if files[backupManifestFile], err = json.MarshalIndent(manifest, "", "  "); err != nil {
+		return nil, err
+	}
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	// manifest comes first, so that it could be inspected cheaply
+	for _, name := range []string{backupManifestFile, backupHostsFile, backupTrashFile, backupProfilesFile} {
+		err = tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(files[name])),
+			ModTime: manifest.CreatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err = tw.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+	if err = gw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ReadBackup reads a backup archive written by Backup, and verifies it
+// against its manifest.
+func ReadBackup(r io.Reader) (*Backup, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid backup archive: %v", err)
+	}
+	defer gr.Close()
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Invalid backup archive: %v", err)
+		}
+		if files[header.Name], err = ioutil.ReadAll(tr); err != nil {
+			return nil, fmt.Errorf("Invalid backup archive: %v", err)
+		}
+	}
+	b, ok := files[backupManifestFile]
+	if !ok {
+		return nil, fmt.Errorf("Invalid backup archive: %s is missing", backupManifestFile)
+	}
+	backup := new(Backup)
+	if err = json.Unmarshal(b, &backup.Manifest); err != nil {
+		return nil, fmt.Errorf("Invalid backup manifest: %v", err)
+	}
+	manifest := backup.Manifest
+	if manifest.FormatVersion > backupFormatVersion {
+		return nil, fmt.Errorf("Backup format version %d is newer than the supported version %d", manifest.FormatVersion, backupFormatVersion)
+	}
+	if manifest.HostSchemaVersion > core.HostSchemaVersion {
+		return nil, fmt.Errorf("Host schema version %d of backup is newer than the supported version %d", manifest.HostSchemaVersion, core.HostSchemaVersion)
+	}
+	names := []string{backupHostsFile, backupProfilesFile}
+	if manifest.FormatVersion > 1 {
+		names = append(names, backupTrashFile)
+	}
+	for _, name := range names {
+		b, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("Invalid backup archive: %s is missing", name)
+		}
+		if sum := checksum(b); sum != manifest.Checksums[name] {
+			return nil, fmt.Errorf("Checksum of %s mismatches: expected %s, got %s", name, manifest.Checksums[name], sum)
+		}
+	}
+	if err = json.Unmarshal(files[backupHostsFile], &backup.Hosts); err != nil {
+		return nil, fmt.Errorf("Invalid hosts of backup: %v", err)
+	}
+	if b, ok := files[backupTrashFile]; ok && manifest.FormatVersion > 1 {
+		if err = json.Unmarshal(b, &backup.TrashedHosts); err != nil {
+			return nil, fmt.Errorf("Invalid trashed hosts of backup: %v", err)
+		}
+	}
+	if err = json.Unmarshal(files[backupProfilesFile], &backup.CredentialProfiles); err != nil {
+		return nil, fmt.Errorf("Invalid credential profiles of backup: %v", err)
+	}
+	if len(backup.Hosts) != manifest.Hosts || len(backup.TrashedHosts) != manifest.TrashedHosts || len(backup.CredentialProfiles) != manifest.CredentialProfiles {
+		return nil, fmt.Errorf("Backup has %d hosts, %d trashed hosts and %d credential profiles, but its manifest records %d, %d and %d", len(backup.Hosts), len(backup.TrashedHosts), len(backup.CredentialProfiles), manifest.Hosts, manifest.TrashedHosts, manifest.CredentialProfiles)
+	}
+	return backup, nil
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,265 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"testing"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// seedBackup fills storage with two hosts, a trashed host and a credential
+// profile, and returns its backup as read back from the archive.
+func seedBackup(t *testing.T, storage core.Storage) *Backup {
+	ctx := context.Background()
+	for _, host := range []core.Host{
+		{Hostname: "node-01", IPMIAddress: "10.0.0.1", CredentialProfile: "dell"},
+		{Hostname: "node-02", IPMIAddress: "10.0.0.2"},
+		{Hostname: "node-03", IPMIAddress: "10.0.0.3"},
+	} {
+		if err := storage.CreateHost(ctx, host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.TrashHost(ctx, "node-03", "Decommissioned"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.CreateCredentialProfile(ctx, core.CredentialProfile{Name: "dell", User: "root", Password: "calvin"}); err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	manifest, err := NewInventoryFromStorage(storage).Backup(ctx, &archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Hosts != 2 || manifest.TrashedHosts != 1 || manifest.CredentialProfiles != 1 {
+		t.Fatalf("Expected 2 hosts, 1 trashed host and 1 credential profile in manifest, got: %+v", manifest)
+	}
+	backup, err := ReadBackup(&archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backup
+}
+
+// changesOf formats changes of report as 'action kind name', sorted.
+func changesOf(report *RestoreReport) []string {
+	var changes []string
+	for _, each := range report.Changes {
+		changes = append(changes, each.Action+" "+each.Kind+" "+each.Name)
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+func assertChanges(t *testing.T, report *RestoreReport, want ...string) {
+	got := changesOf(report)
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Fatalf("Expected changes %q, got %q", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected changes %q, got %q", want, got)
+		}
+	}
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := openMemory(t)
+	defer source.Close()
+	backup := seedBackup(t, source)
+	storage := openMemory(t)
+	defer storage.Close()
+	inventory := NewInventoryFromStorage(storage)
+
+	// a dry run reports the same changes, while nothing is written
+	report, err := inventory.RestoreBackup(ctx, backup, RestoreMerge, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := []string{
+		"create credential profile dell",
+		"create host node-01",
+		"create host node-02",
+		"create trashed host node-03",
+	}
+	assertChanges(t, report, created...)
+	if hosts, err := storage.ListHost(ctx); err != nil || len(hosts) != 0 {
+		t.Fatalf("Expected nothing written by dry run, got %d hosts with error: %v", len(hosts), err)
+	}
+
+	if report, err = inventory.RestoreBackup(ctx, backup, RestoreMerge, false); err != nil {
+		t.Fatal(err)
+	}
+	assertChanges(t, report, created...)
+	if len(report.Conflicts) != 0 {
+		t.Errorf("Expected no conflict, got: %+v", report.Conflicts)
+	}
+	for _, want := range backup.Hosts {
+		host, err := storage.GetHost(ctx, want.Hostname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if host.GUID != want.GUID || host.IPMIAddress != want.IPMIAddress || host.CredentialProfile != want.CredentialProfile {
+			t.Errorf("Expected host %+v restored, got %+v", want, host)
+		}
+	}
+	trashed, err := storage.ListTrash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trashed) != 1 || trashed[0].Host.Hostname != "node-03" || trashed[0].Reason != "Decommissioned" {
+		t.Errorf("Expected 'node-03' restored into trash bin, got: %+v", trashed)
+	}
+	profile, err := storage.GetCredentialProfile(ctx, "dell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.User != "root" || profile.Password != "calvin" {
+		t.Errorf("Expected credential profile restored, got: %+v", profile)
+	}
+
+	// restoring again changes nothing
+	if report, err = inventory.RestoreBackup(ctx, backup, RestoreReplace, false); err != nil {
+		t.Fatal(err)
+	}
+	assertChanges(t, report)
+	if report.Unchanged != 4 {
+		t.Errorf("Expected 4 entities unchanged, got %d", report.Unchanged)
+	}
+}
+
+func TestRestoreBackupModes(t *testing.T) {
+	ctx := context.Background()
+	source := openMemory(t)
+	defer source.Close()
+	backup := seedBackup(t, source)
+
+	// diverge opens storage holding the backup, which has changed since
+	diverge := func(t *testing.T) core.Storage {
+		storage := openMemory(t)
+		if _, err := NewInventoryFromStorage(storage).RestoreBackup(ctx, backup, RestoreMerge, false); err != nil {
+			t.Fatal(err)
+		}
+		err := storage.UpdateHost(ctx, "node-01", func(host core.Host) (core.Host, error) {
+			host.SSHUser = "ops"
+			return host, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = storage.DeleteHost(ctx, "node-02"); err != nil {
+			t.Fatal(err)
+		}
+		if err = storage.CreateHost(ctx, core.Host{Hostname: "node-09", IPMIAddress: "10.0.0.9"}); err != nil {
+			t.Fatal(err)
+		}
+		if err = storage.CreateCredentialProfile(ctx, core.CredentialProfile{Name: "hp", User: "admin"}); err != nil {
+			t.Fatal(err)
+		}
+		return storage
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		storage := diverge(t)
+		defer storage.Close()
+		report, err := NewInventoryFromStorage(storage).RestoreBackup(ctx, backup, RestoreMerge, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertChanges(t, report, "create host node-02")
+		if len(report.Conflicts) != 1 || report.Conflicts[0].Name != "node-01" {
+			t.Errorf("Expected conflict of 'node-01', got: %+v", report.Conflicts)
+		}
+		host, err := storage.GetHost(ctx, "node-01")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if host.SSHUser != "ops" {
+			t.Error("Expected existing host to be kept")
+		}
+		if _, err = storage.GetHost(ctx, "node-09"); err != nil {
+			t.Errorf("Expected host absent from backup to be kept, got error: %v", err)
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		want := []string{
+			"create host node-02",
+			"delete credential profile hp",
+			"trash host node-09",
+			"update host node-01",
+		}
+		storage := diverge(t)
+		defer storage.Close()
+		inventory := NewInventoryFromStorage(storage)
+		report, err := inventory.RestoreBackup(ctx, backup, RestoreReplace, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertChanges(t, report, want...)
+		if _, err = storage.GetHost(ctx, "node-09"); err != nil {
+			t.Errorf("Expected nothing written by dry run, got error: %v", err)
+		}
+
+		if report, err = inventory.RestoreBackup(ctx, backup, RestoreReplace, false); err != nil {
+			t.Fatal(err)
+		}
+		assertChanges(t, report, want...)
+		if len(report.Conflicts) != 0 {
+			t.Errorf("Expected no conflict, got: %+v", report.Conflicts)
+		}
+		host, err := storage.GetHost(ctx, "node-01")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if host.SSHUser != "" {
+			t.Error("Expected host to be replaced by backup")
+		}
+		trashed, err := storage.ListTrash(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(trashed) != 2 || trashed[1].Host.Hostname != "node-09" || trashed[1].Reason != restoreTrashReason {
+			t.Errorf("Expected host absent from backup to be trashed, got: %+v", trashed)
+		}
+		if _, err = storage.GetCredentialProfile(ctx, "hp"); err != core.ErrResourceNotFound {
+			t.Errorf("Expected credential profile absent from backup to be deleted, got error: %v", err)
+		}
+	})
+}
+
+func TestReadBackupRejectsCorruptedArchive(t *testing.T) {
+	source := openMemory(t)
+	defer source.Close()
+	var archive bytes.Buffer
+	if _, err := NewInventoryFromStorage(source).Backup(context.Background(), &archive); err != nil {
+		t.Fatal(err)
+	}
+	b := archive.Bytes()
+	for name, corrupted := range map[string][]byte{
+		"not gzipped": []byte("hosts"),
+		"truncated":   b[:len(b)/2],
+	} {
+		if _, err := ReadBackup(bytes.NewReader(corrupted)); err == nil {
+			t.Errorf("Expected %s archive to be rejected", name)
+		}
+	}
+}
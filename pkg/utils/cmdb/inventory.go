@@ -15,22 +15,33 @@
 package cmdb
 
 import (
+	"context"
 	"fmt"
 	"net"
 
 	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	secret "github.com/universonic/ivy-utils/pkg/utils/secret"
+)
+
+const (
+	selectPageSize = 500
 )
 
 type Inventory struct {
 	Storage core.Storage
 }
 
-func (in *Inventory) Add(host core.Host) error {
-	if host.SSHAddress != "" {
-		ip := net.ParseIP(host.SSHAddress)
-		if ip == nil {
-			return fmt.Errorf("Invalid IP address: %s", host.SSHAddress)
-		}
+func (in *Inventory) Add(ctx context.Context, host core.Host) error {
+	if err := in.validate(ctx, host); err != nil {
+		return err
+	}
+	return in.Storage.CreateHost(ctx, withDefaultExtraInfo(host))
+}
+
+// withDefaultExtraInfo ensures that host has comment and department.
+func withDefaultExtraInfo(host core.Host) core.Host {
+	if host.ExtraInfo == nil {
+		host.ExtraInfo = make(core.ExtendableFields)
 	}
 	_, ok := host.ExtraInfo["comment"]
 	if !ok {
@@ -40,25 +51,118 @@ func (in *Inventory) Add(host core.Host) error {
 	if !ok {
 		host.ExtraInfo["department"] = ""
 	}
-	return in.Storage.CreateHost(host)
-}
-
-func (in *Inventory) Get(hostID string) (core.Host, error) {
-	return in.Storage.GetHost(hostID)
+	return host
 }
 
-func (in *Inventory) List() ([]core.Host, error) {
-	return in.Storage.ListHost()
-}
-
-func (in *Inventory) Update(host core.Host) error {
+// validate checks the SSH address and IPMI credential of host.
+func (in *Inventory) validate(ctx context.Context, host core.Host) error {
 	if host.SSHAddress != "" {
 		ip := net.ParseIP(host.SSHAddress)
 		if ip == nil {
 			return fmt.Errorf("Invalid IP address: %s", host.SSHAddress)
 		}
 	}
-	return in.Storage.UpdateHost(host.Hostname, func(h core.Host) (core.Host, error) {
+	return in.validateCredential(ctx, host)
+}
+
+// validateCredential checks the secret reference of host, and whether the
+// credential profile of host exists.
+func (in *Inventory) validateCredential(ctx context.Context, host core.Host) error {
+	if host.IPMISecret != "" {
+		if _, err := secret.Parse(host.IPMISecret); err != nil {
+			return err
+		}
+	}
+	if host.CredentialProfile != "" {
+		_, err := in.Storage.GetCredentialProfile(ctx, host.CredentialProfile)
+		if err == core.ErrResourceNotFound {
+			return fmt.Errorf("Credential profile '%s' does not exist", host.CredentialProfile)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (in *Inventory) Get(ctx context.Context, hostID string) (core.Host, error) {
+	return in.Storage.GetHost(ctx, hostID)
+}
+
+// GetBy looks host up by an indexed field other than hostname.
+func (in *Inventory) GetBy(ctx context.Context, field core.IndexField, value string) (core.Host, error) {
+	return in.Storage.GetHostBy(ctx, field, value)
+}
+
+func (in *Inventory) List(ctx context.Context) ([]core.Host, error) {
+	return in.Storage.ListHost(ctx)
+}
+
+// ListPage returns a single page of hosts, see core.ListOptions.
+func (in *Inventory) ListPage(ctx context.Context, opts core.ListOptions) (core.HostPage, error) {
+	return in.Storage.ListHosts(ctx, opts)
+}
+
+// Select returns all hosts satisfying given predicates, which are fetched
+// page by page at a consistent revision.
+func (in *Inventory) Select(ctx context.Context, predicates []core.Predicate) (hosts []core.Host, err error) {
+	opts := core.ListOptions{
+		Limit:      selectPageSize,
+		Predicates: predicates,
+	}
+	for {
+		page, err := in.Storage.ListHosts(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, page.Hosts...)
+		if page.Continue == "" {
+			return hosts, nil
+		}
+		opts.Continue = page.Continue
+	}
+}
+
+func (in *Inventory) Update(ctx context.Context, host core.Host) error {
+	if err := in.validate(ctx, host); err != nil {
+		return err
+	}
+	return in.Storage.UpdateHost(ctx, host.Hostname, mergeInto(host))
+}
+
+// CompareAndUpdate updates host the same as Update, only if it has not been
+// changed since revision, see core.Host.Revision. Otherwise it fails with
+// core.ErrRevisionMismatch, even after the update is retried due to a
+// concurrent update. Adapters which do not track revisions are refused with
+// core.ErrNotSupported.
+func (in *Inventory) CompareAndUpdate(ctx context.Context, host core.Host, revision int64) error {
+	if err := in.validate(ctx, host); err != nil {
+		return err
+	}
+	merge := mergeInto(host)
+	return in.Storage.UpdateHost(ctx, host.Hostname, func(current core.Host) (core.Host, error) {
+		switch {
+		case current.Hostname == "":
+			return current, core.ErrResourceNotFound
+		case current.Revision == 0:
+			return current, core.ErrNotSupported
+		case current.Revision != revision:
+			return current, core.ErrRevisionMismatch
+		}
+		return merge(current)
+	})
+}
+
+// mergeInto returns an updater which keeps fields of the current host that
+// are not given.
+func mergeInto(given core.Host) func(h core.Host) (core.Host, error) {
+	return func(h core.Host) (core.Host, error) {
+		// Storages run the updater again after losing the race against a
+		// concurrent update, thus given must be kept intact.
+		host := given
+		host.ExtraInfo = make(core.ExtendableFields, len(given.ExtraInfo))
+		for k, v := range given.ExtraInfo {
+			host.ExtraInfo[k] = v
+		}
 		host.GUID = h.GUID
 		if host.SSHAddress == "" {
 			host.SSHAddress = h.SSHAddress
@@ -75,8 +179,14 @@ func (in *Inventory) Update(host core.Host) error {
 		if host.IPMIUser == "" {
 			host.IPMIUser = h.IPMIUser
 		}
-		if host.IPMIPassword == "" {
+		// Either kind of IPMI credential replaces the other one, so that
+		// switching to a secret reference drops the inline password.
+		if host.IPMIPassword == "" && host.IPMISecret == "" {
 			host.IPMIPassword = h.IPMIPassword
+			host.IPMISecret = h.IPMISecret
+		}
+		if host.CredentialProfile == "" {
+			host.CredentialProfile = h.CredentialProfile
 		}
 		if _, ok := h.ExtraInfo["comment"]; !ok {
 			h.ExtraInfo["comment"] = ""
@@ -95,11 +205,61 @@ func (in *Inventory) Update(host core.Host) error {
 			}
 		}
 		return host, nil
-	})
+	}
+}
+
+// ApplyBatch validates hosts of ops the same way as Add and Update, and applies
+// them atomically. Hosts to update only need fields that should be changed.
+func (in *Inventory) ApplyBatch(ctx context.Context, ops []core.BatchOp) error {
+	failures := new(core.BatchError)
+	for i := range ops {
+		op := &ops[i]
+		if op.Action == core.BatchDelete {
+			continue
+		}
+		if err := in.validate(ctx, op.Host); err != nil {
+			failures.Failures = append(failures.Failures, core.BatchFailure{
+				Index:    i,
+				Hostname: op.Host.Hostname,
+				Err:      err,
+			})
+			continue
+		}
+		switch op.Action {
+		case core.BatchCreate:
+			op.Host = withDefaultExtraInfo(op.Host)
+		case core.BatchUpdate:
+			op.Update = mergeInto(op.Host)
+		}
+	}
+	if len(failures.Failures) > 0 {
+		return failures
+	}
+	return in.Storage.ApplyBatch(ctx, ops)
+}
+
+func (in *Inventory) Delete(ctx context.Context, hostID string) error {
+	return in.Storage.DeleteHost(ctx, hostID)
+}
+
+// Rename changes hostname of the host while keeping its GUID, which fails if
+// newHostID is taken by another host.
+func (in *Inventory) Rename(ctx context.Context, hostID, newHostID string) error {
+	return in.Storage.RenameHost(ctx, hostID, newHostID)
+}
+
+// Trash moves the host into trash bin, where it could be restored until the
+// retention period of storage has elapsed.
+func (in *Inventory) Trash(ctx context.Context, hostID, reason string) error {
+	return in.Storage.TrashHost(ctx, hostID, reason)
+}
+
+func (in *Inventory) ListTrash(ctx context.Context) ([]core.TrashedHost, error) {
+	return in.Storage.ListTrash(ctx)
 }
 
-func (in *Inventory) Delete(hostID string) error {
-	return in.Storage.DeleteHost(hostID)
+func (in *Inventory) Restore(ctx context.Context, hostID string) error {
+	return in.Storage.RestoreHost(ctx, hostID)
 }
 
 func NewInventoryFromStorage(storage core.Storage) *Inventory {
@@ -15,6 +15,7 @@
 package cmdb
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -31,7 +32,7 @@ type HostLocationManager struct {
 	inventory *Inventory
 }
 
-func (in *HostLocationManager) Set(field, value string) (err error) {
+func (in *HostLocationManager) Set(ctx context.Context, field, value string) (err error) {
 	sp := cliutil.NewSpinner()
 	var table *tablewriter.Table
 	printMsgOnStop := func(succeeded bool) {
@@ -55,8 +56,16 @@ func (in *HostLocationManager) Set(field, value string) (err error) {
 	}()
 	sp.Prefix = "Modify node location (1/2): "
 	sp.Start()
+	// hold the lock of host until changes are verified, so that operators
+	// would not modify the same host at the same time
+	var unlock func() error
+	unlock, err = in.inventory.Lock(ctx, in.hostID, fmt.Sprintf("locate --modify %s=%s", field, value), DefaultLockWait)
+	if err != nil {
+		return
+	}
+	defer unlock()
 	var host core.Host
-	host, err = in.inventory.Get(in.hostID)
+	host, err = in.getHost(ctx)
 	if err != nil {
 		return
 	}
@@ -76,7 +85,7 @@ func (in *HostLocationManager) Set(field, value string) (err error) {
 		return fmt.Errorf("No such field. Only 'aisle', 'datacenter', 'rackname', 'rackslot', and 'roomname' are acceptable")
 	}
 	setTask := NewRacadmCommandTask("set", host, value, params...)
-	err = setTask.Execute()
+	err = setTask.Execute(ctx)
 	if err != nil {
 		return
 	}
@@ -84,7 +93,7 @@ func (in *HostLocationManager) Set(field, value string) (err error) {
 	sp.Prefix = "Verify changes (2/2): "
 	sp.Start()
 	getTask := NewRacadmCommandTask("get", host, "", "System", "Location")
-	err = getTask.Execute()
+	err = getTask.Execute(ctx)
 	if err != nil {
 		return
 	}
@@ -135,7 +144,7 @@ func (in *HostLocationManager) Set(field, value string) (err error) {
 	return nil
 }
 
-func (in *HostLocationManager) Describe() (err error) {
+func (in *HostLocationManager) Describe(ctx context.Context) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = r.(error)
@@ -145,12 +154,12 @@ func (in *HostLocationManager) Describe() (err error) {
 		}
 	}()
 	var host core.Host
-	host, err = in.inventory.Get(in.hostID)
+	host, err = in.getHost(ctx)
 	if err != nil {
 		return
 	}
 	getTask := NewRacadmCommandTask("get", host, "", "System", "Location")
-	err = getTask.Execute()
+	err = getTask.Execute(ctx)
 	if err != nil {
 		return
 	}
@@ -180,6 +189,19 @@ func (in *HostLocationManager) Describe() (err error) {
 	return nil
 }
 
+// getHost returns the host with the IPMI credential of its profile applied.
+func (in *HostLocationManager) getHost(ctx context.Context) (core.Host, error) {
+	host, err := in.inventory.Get(ctx, in.hostID)
+	if err != nil {
+		return host, err
+	}
+	hosts, err := in.inventory.WithCredentials(ctx, host)
+	if err != nil {
+		return host, err
+	}
+	return hosts[0], nil
+}
+
 func NewHostLocationManager(hostID string, storage core.Storage) *HostLocationManager {
 	return &HostLocationManager{
 		hostID:    hostID,
@@ -0,0 +1,125 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+const (
+	// DefaultLockWait is how long mutating commands wait for the lock of host.
+	DefaultLockWait = 5 * time.Second
+
+	// lockTTL is how long a lock outlives its holder, e.g. after it crashed.
+	lockTTL = 30 * time.Second
+)
+
+// LockHolder describes the current process as a lock holder, e.g.
+// 'alice@ops-01 (pid 1234)'.
+func LockHolder() string {
+	username := "unknown"
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s@%s (pid %d)", username, hostname, os.Getpid())
+}
+
+// Lock acquires the lock of host for reason, waiting for wait at most. If the
+// storage does not support locks, a no-op unlock is returned, so that callers
+// work with any storage.
+func (in *Inventory) Lock(ctx context.Context, hostID, reason string, wait time.Duration) (unlock func() error, err error) {
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	unlock, err = in.Storage.LockHost(waitCtx, core.HostLock{
+		Hostname: hostID,
+		Holder:   LockHolder(),
+		Reason:   reason,
+	}, lockTTL)
+	switch err {
+	case nil:
+		return unlock, nil
+	case core.ErrNotSupported:
+		return func() error { return nil }, nil
+	case core.ErrHostLocked:
+		if ctx.Err() != nil {
+			// interrupted rather than timed out
+			return nil, ctx.Err()
+		}
+		locks, lerr := in.Storage.ListHostLocks(ctx)
+		if lerr != nil {
+			return nil, err
+		}
+		for _, each := range locks {
+			if each.Hostname == hostID {
+				return nil, fmt.Errorf("Host '%s' is locked by %s since %s for '%s'", hostID, each.Holder, each.AcquiredAt.Local().Format(time.RFC3339), each.Reason)
+			}
+		}
+	}
+	return nil, err
+}
+
+// LockAll acquires locks of all given hosts like Lock. Locks are acquired in
+// order of hostname, so that callers locking overlapping hosts could not
+// deadlock, and locks already acquired are released if any of them fails.
+func (in *Inventory) LockAll(ctx context.Context, hostIDs []string, reason string, wait time.Duration) (unlock func() error, err error) {
+	seen := make(map[string]bool)
+	var sorted []string
+	for _, each := range hostIDs {
+		if !seen[each] {
+			seen[each] = true
+			sorted = append(sorted, each)
+		}
+	}
+	sort.Strings(sorted)
+	var unlocks []func() error
+	unlock = func() (err error) {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			if e := unlocks[i](); e != nil && err == nil {
+				err = e
+			}
+		}
+		return err
+	}
+	for _, each := range sorted {
+		u, err := in.Lock(ctx, each, reason, wait)
+		if err != nil {
+			unlock()
+			return nil, err
+		}
+		unlocks = append(unlocks, u)
+	}
+	return unlock, nil
+}
+
+func (in *Inventory) ListLocks(ctx context.Context) ([]core.HostLock, error) {
+	return in.Storage.ListHostLocks(ctx)
+}
+
+// ForceUnlock releases the lock of host regardless of its holder, who is not
+// notified.
+func (in *Inventory) ForceUnlock(ctx context.Context, hostID string) error {
+	return in.Storage.ForceUnlockHost(ctx, hostID)
+}
@@ -0,0 +1,59 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"context"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// OutdatedHost is a host stored with an older schema version.
+type OutdatedHost struct {
+	Hostname string
+	Version  int
+}
+
+// MigrateSchema rewrites hosts stored with older schema versions, and returns
+// them. Nothing is written if dryRun is true. Trashed hosts are not rewritten,
+// but they are upgraded when read as well.
+func (in *Inventory) MigrateSchema(ctx context.Context, dryRun bool) (outdated []OutdatedHost, err error) {
+	hosts, err := in.Select(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, host := range hosts {
+		if host.SchemaVersion >= core.HostSchemaVersion {
+			continue
+		}
+		if !dryRun {
+			// hosts are upgraded when read, thus writing them back is enough
+			err = in.Storage.UpdateHost(ctx, host.Hostname, func(h core.Host) (core.Host, error) {
+				if h.GUID == "" {
+					return h, core.ErrResourceNotFound
+				}
+				return h, nil
+			})
+			if err == core.ErrResourceNotFound {
+				// removed in the meantime
+				continue
+			} else if err != nil {
+				return outdated, err
+			}
+		}
+		outdated = append(outdated, OutdatedHost{Hostname: host.Hostname, Version: host.SchemaVersion})
+	}
+	return outdated, nil
+}
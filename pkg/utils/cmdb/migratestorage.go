@@ -0,0 +1,245 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// StorageMigration copies all hosts and credential profiles from one storage
+// to another, preserving GUIDs of hosts. Trashed hosts and history are not
+// copied. Existing entities of the target storage are overwritten.
+type StorageMigration struct {
+	From core.Storage
+	To   core.Storage
+	// Checkpoint is the file recording progress, so that an interrupted
+	// migration could be resumed. It is removed once the migration has been
+	// verified. Progress is not recorded if it is empty.
+	Checkpoint string
+	// Fingerprint identifies the pair of storages, so that a checkpoint is
+	// never resumed against other storages.
+	Fingerprint string
+}
+
+// StorageMigrationResult is the outcome of a verified storage migration.
+type StorageMigrationResult struct {
+	// ResumedAfter is the last host copied before interruption.
+	ResumedAfter       string
+	Hosts              int
+	CredentialProfiles int
+	// Digest is the content hash shared by both storages.
+	Digest string
+}
+
+type migrationCheckpoint struct {
+	Fingerprint  string `json:"fingerprint"`
+	ProfilesDone bool   `json:"profiles_done"`
+	LastHost     string `json:"last_host"`
+}
+
+// Run copies entities page by page, and verifies counts and content hashes of
+// both storages afterwards. Copying a host twice is harmless, thus a resumed
+// migration starts over from the last recorded page. Progress is called with
+// the number of hosts copied so far after each page.
+func (in *StorageMigration) Run(ctx context.Context, progress func(hosts int)) (*StorageMigrationResult, error) {
+	checkpoint, err := in.loadCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	result := &StorageMigrationResult{ResumedAfter: checkpoint.LastHost}
+	if !checkpoint.ProfilesDone {
+		profiles, err := in.From.ListCredentialProfiles(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, profile := range profiles {
+			profile := profile
+			err = in.To.UpdateCredentialProfile(ctx, profile.Name, func(core.CredentialProfile) (core.CredentialProfile, error) {
+				return profile, nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("Could not copy credential profile '%s' due to: %v", profile.Name, err)
+			}
+		}
+		checkpoint.ProfilesDone = true
+		if err = in.saveCheckpoint(checkpoint); err != nil {
+			return nil, err
+		}
+	}
+	opts := core.ListOptions{Limit: selectPageSize}
+	copied := 0
+	for {
+		page, err := in.From.ListHosts(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range page.Hosts {
+			if host.Hostname <= checkpoint.LastHost {
+				continue
+			}
+			host := host
+			err = in.To.UpdateHost(ctx, host.Hostname, func(core.Host) (core.Host, error) {
+				return host, nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("Could not copy host '%s' due to: %v", host.Hostname, err)
+			}
+			copied++
+		}
+		if n := len(page.Hosts); n > 0 && page.Hosts[n-1].Hostname > checkpoint.LastHost {
+			checkpoint.LastHost = page.Hosts[n-1].Hostname
+			if err = in.saveCheckpoint(checkpoint); err != nil {
+				return nil, err
+			}
+		}
+		if progress != nil {
+			progress(copied)
+		}
+		if page.Continue == "" {
+			break
+		}
+		opts.Continue = page.Continue
+	}
+	if err = in.verify(ctx, result); err != nil {
+		return nil, err
+	}
+	if in.Checkpoint != "" {
+		if err = os.Remove(in.Checkpoint); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// verify compares counts and content hashes of both storages.
+func (in *StorageMigration) verify(ctx context.Context, result *StorageMigrationResult) error {
+	from, err := digestStorage(ctx, in.From)
+	if err != nil {
+		return fmt.Errorf("Could not digest source storage due to: %v", err)
+	}
+	to, err := digestStorage(ctx, in.To)
+	if err != nil {
+		return fmt.Errorf("Could not digest target storage due to: %v", err)
+	}
+	if from.hosts != to.hosts || from.profiles != to.profiles {
+		return fmt.Errorf("Source storage has %d hosts and %d credential profiles, but target storage has %d and %d", from.hosts, from.profiles, to.hosts, to.profiles)
+	}
+	if from.sum != to.sum {
+		return fmt.Errorf("Content of target storage differs from source storage: expected digest %s, got %s", from.sum, to.sum)
+	}
+	result.Hosts = from.hosts
+	result.CredentialProfiles = from.profiles
+	result.Digest = from.sum
+	return nil
+}
+
+type storageDigest struct {
+	hosts    int
+	profiles int
+	sum      string
+}
+
+// digestStorage hashes all credential profiles and hosts in order of their
+// names, regardless of when they were stored.
+func digestStorage(ctx context.Context, storage core.Storage) (*storageDigest, error) {
+	digest := new(storageDigest)
+	h := sha256.New()
+	profiles, err := storage.ListCredentialProfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, profile := range profiles {
+		profile.UpdatedAt = time.Time{}
+		if err = writeDigest(h, profile); err != nil {
+			return nil, err
+		}
+	}
+	digest.profiles = len(profiles)
+	opts := core.ListOptions{Limit: selectPageSize}
+	for {
+		page, err := storage.ListHosts(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range page.Hosts {
+			host.UpdatedAt = time.Time{}
+			if err = writeDigest(h, host); err != nil {
+				return nil, err
+			}
+		}
+		digest.hosts += len(page.Hosts)
+		if page.Continue == "" {
+			break
+		}
+		opts.Continue = page.Continue
+	}
+	digest.sum = hex.EncodeToString(h.Sum(nil))
+	return digest, nil
+}
+
+func writeDigest(h hash.Hash, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	h.Write(append(b, '\n'))
+	return nil
+}
+
+func (in *StorageMigration) loadCheckpoint() (*migrationCheckpoint, error) {
+	checkpoint := &migrationCheckpoint{Fingerprint: in.Fingerprint}
+	if in.Checkpoint == "" {
+		return checkpoint, nil
+	}
+	b, err := ioutil.ReadFile(in.Checkpoint)
+	if os.IsNotExist(err) {
+		return checkpoint, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, checkpoint); err != nil {
+		return nil, fmt.Errorf("Invalid checkpoint %s: %v", in.Checkpoint, err)
+	}
+	if checkpoint.Fingerprint != in.Fingerprint {
+		return nil, fmt.Errorf("Checkpoint %s belongs to another migration, remove it to start over", in.Checkpoint)
+	}
+	return checkpoint, nil
+}
+
+// saveCheckpoint replaces the checkpoint file atomically.
+func (in *StorageMigration) saveCheckpoint(checkpoint *migrationCheckpoint) error {
+	if in.Checkpoint == "" {
+		return nil
+	}
+	b, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	tmp := in.Checkpoint + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, in.Checkpoint)
+}
@@ -0,0 +1,175 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// interruptedCopy fails to write host failAt once, as if the migration was
+// interrupted there, and records writes otherwise.
+type interruptedCopy struct {
+	core.Storage
+	failAt   string
+	hosts    []string
+	profiles []string
+}
+
+func (in *interruptedCopy) UpdateHost(ctx context.Context, id string, updater func(host core.Host) (core.Host, error)) error {
+	if id == in.failAt {
+		in.failAt = ""
+		return errors.New("Connection reset by peer")
+	}
+	in.hosts = append(in.hosts, id)
+	return in.Storage.UpdateHost(ctx, id, updater)
+}
+
+func (in *interruptedCopy) UpdateCredentialProfile(ctx context.Context, name string, updater func(profile core.CredentialProfile) (core.CredentialProfile, error)) error {
+	in.profiles = append(in.profiles, name)
+	return in.Storage.UpdateCredentialProfile(ctx, name, updater)
+}
+
+func seedMigration(t *testing.T, storage core.Storage, hosts int) {
+	ctx := context.Background()
+	for i := 0; i < hosts; i++ {
+		host := core.NewHost()
+		host.Hostname = fmt.Sprintf("node-%04d", i)
+		host.IPMIAddress = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+		if err := storage.CreateHost(ctx, *host); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.CreateCredentialProfile(ctx, core.CredentialProfile{Name: "dell", User: "root"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStorageMigrationResumes(t *testing.T) {
+	ctx := context.Background()
+	hosts := 2*selectPageSize + 10
+	from := openMemory(t)
+	defer from.Close()
+	seedMigration(t, from, hosts)
+	dir, err := ioutil.TempDir("", "migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// interrupted within the second page
+	to := &interruptedCopy{Storage: openMemory(t), failAt: fmt.Sprintf("node-%04d", selectPageSize+5)}
+	defer to.Close()
+	migration := &StorageMigration{
+		From:        from,
+		To:          to,
+		Checkpoint:  filepath.Join(dir, "checkpoint.json"),
+		Fingerprint: "memory->memory",
+	}
+
+	if _, err = migration.Run(ctx, nil); err == nil {
+		t.Fatal("Expected the interrupted migration to fail")
+	}
+	if _, err = os.Stat(migration.Checkpoint); err != nil {
+		t.Fatalf("Expected checkpoint to be kept, got error: %v", err)
+	}
+	to.hosts, to.profiles = nil, nil
+
+	var progress []int
+	result, err := migration.Run(ctx, func(copied int) {
+		progress = append(progress, copied)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastOfFirstPage := fmt.Sprintf("node-%04d", selectPageSize-1)
+	if result.ResumedAfter != lastOfFirstPage {
+		t.Errorf("Expected migration resumed after '%s', got '%s'", lastOfFirstPage, result.ResumedAfter)
+	}
+	if copied := len(to.hosts); copied != hosts-selectPageSize {
+		t.Errorf("Expected %d hosts copied after resuming, got %d", hosts-selectPageSize, copied)
+	}
+	if to.hosts[0] != fmt.Sprintf("node-%04d", selectPageSize) {
+		t.Errorf("Expected copying to resume from the second page, got '%s' first", to.hosts[0])
+	}
+	if len(to.profiles) != 0 {
+		t.Errorf("Expected credential profiles not to be copied again, got: %v", to.profiles)
+	}
+	if len(progress) == 0 || progress[len(progress)-1] != hosts-selectPageSize {
+		t.Errorf("Expected progress to end at %d hosts, got: %v", hosts-selectPageSize, progress)
+	}
+	if result.Hosts != hosts || result.CredentialProfiles != 1 || result.Digest == "" {
+		t.Errorf("Expected %d hosts and 1 credential profile verified, got: %+v", hosts, result)
+	}
+	if _, err = os.Stat(migration.Checkpoint); !os.IsNotExist(err) {
+		t.Errorf("Expected checkpoint to be removed once verified, got error: %v", err)
+	}
+	source, err := from.GetHost(ctx, "node-0003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := to.GetHost(ctx, "node-0003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.GUID != target.GUID {
+		t.Errorf("Expected GUID '%s' to be preserved, got '%s'", source.GUID, target.GUID)
+	}
+}
+
+func TestStorageMigrationRejectsForeignCheckpoint(t *testing.T) {
+	from, to := openMemory(t), openMemory(t)
+	defer from.Close()
+	defer to.Close()
+	dir, err := ioutil.TempDir("", "migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	migration := &StorageMigration{
+		From:        from,
+		To:          to,
+		Checkpoint:  filepath.Join(dir, "checkpoint.json"),
+		Fingerprint: "memory->memory",
+	}
+	if err = ioutil.WriteFile(migration.Checkpoint, []byte(`{"fingerprint":"etcd->bolt","last_host":"node-0499"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = migration.Run(context.Background(), nil); err == nil {
+		t.Error("Expected checkpoint of another migration to be rejected")
+	}
+}
+
+func TestStorageMigrationVerifies(t *testing.T) {
+	ctx := context.Background()
+	from, to := openMemory(t), openMemory(t)
+	defer from.Close()
+	defer to.Close()
+	seedMigration(t, from, 3)
+	// existing hosts of target storage are not removed
+	if err := to.CreateHost(ctx, core.Host{Hostname: "node-0099"}); err != nil {
+		t.Fatal(err)
+	}
+	migration := &StorageMigration{From: from, To: to}
+	if _, err := migration.Run(ctx, nil); err == nil {
+		t.Error("Expected verification to fail as target storage has more hosts")
+	}
+}
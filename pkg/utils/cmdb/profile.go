@@ -0,0 +1,119 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	secret "github.com/universonic/ivy-utils/pkg/utils/secret"
+)
+
+var ErrProfileInUse = errors.New("Credential profile is still used by hosts")
+
+func validateProfile(profile core.CredentialProfile) error {
+	if profile.Name == "" {
+		return fmt.Errorf("Name of credential profile is required")
+	}
+	if profile.Secret != "" {
+		if _, err := secret.Parse(profile.Secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (in *Inventory) AddProfile(ctx context.Context, profile core.CredentialProfile) error {
+	if err := validateProfile(profile); err != nil {
+		return err
+	}
+	return in.Storage.CreateCredentialProfile(ctx, profile)
+}
+
+func (in *Inventory) GetProfile(ctx context.Context, name string) (core.CredentialProfile, error) {
+	return in.Storage.GetCredentialProfile(ctx, name)
+}
+
+func (in *Inventory) ListProfiles(ctx context.Context) ([]core.CredentialProfile, error) {
+	return in.Storage.ListCredentialProfiles(ctx)
+}
+
+// UpdateProfile updates fields of an existing profile which are not empty.
+// Like hosts, setting either password or secret replaces the other one.
+func (in *Inventory) UpdateProfile(ctx context.Context, profile core.CredentialProfile) error {
+	if err := validateProfile(profile); err != nil {
+		return err
+	}
+	return in.Storage.UpdateCredentialProfile(ctx, profile.Name, func(p core.CredentialProfile) (core.CredentialProfile, error) {
+		if p.Name == "" {
+			return p, core.ErrResourceNotFound
+		}
+		if profile.Type == "" {
+			profile.Type = p.Type
+		}
+		if profile.User == "" {
+			profile.User = p.User
+		}
+		if profile.Password == "" && profile.Secret == "" {
+			profile.Password = p.Password
+			profile.Secret = p.Secret
+		}
+		return profile, nil
+	})
+}
+
+// DeleteProfile removes the profile, unless hosts still refer to it.
+func (in *Inventory) DeleteProfile(ctx context.Context, name string) error {
+	page, err := in.Storage.ListHosts(ctx, core.ListOptions{
+		Limit: 1,
+		Predicates: []core.Predicate{
+			{Field: "credential_profile", Operator: core.OperatorEquals, Value: name},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if len(page.Hosts) > 0 {
+		return ErrProfileInUse
+	}
+	return in.Storage.DeleteCredentialProfile(ctx, name)
+}
+
+// WithCredentials returns hosts with the IPMI credential of their profiles
+// applied, see core.CredentialProfile.ApplyTo. The result is meant to be used
+// rather than saved.
+func (in *Inventory) WithCredentials(ctx context.Context, hosts ...core.Host) ([]core.Host, error) {
+	profiles := make(map[string]core.CredentialProfile)
+	result := make([]core.Host, 0, len(hosts))
+	for _, host := range hosts {
+		if host.CredentialProfile == "" {
+			result = append(result, host)
+			continue
+		}
+		profile, ok := profiles[host.CredentialProfile]
+		if !ok {
+			var err error
+			profile, err = in.Storage.GetCredentialProfile(ctx, host.CredentialProfile)
+			if err != nil {
+				return nil, fmt.Errorf("Could not retrieve credential profile '%s' of host '%s' due to: %v", host.CredentialProfile, host.Hostname, err)
+			}
+			profiles[host.CredentialProfile] = profile
+		}
+		result = append(result, profile.ApplyTo(host))
+	}
+	return result, nil
+}
@@ -16,6 +16,7 @@ package cmdb
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -78,7 +79,9 @@ type ReportGenerator struct {
 	inventory *Inventory
 }
 
-func (in *ReportGenerator) GenerateAndSaveAs(selectedHosts []string, all bool, mode ReportMode, output string) (err error) {
+// GenerateAndSaveAs generates report of selected hosts, or all hosts that
+// satisfy predicates if all is true.
+func (in *ReportGenerator) GenerateAndSaveAs(ctx context.Context, selectedHosts []string, all bool, predicates []core.Predicate, mode ReportMode, output string) (err error) {
 	err = mode.Validate()
 	if err != nil {
 		return
@@ -118,21 +121,25 @@ func (in *ReportGenerator) GenerateAndSaveAs(selectedHosts []string, all bool, m
 	sp.Prefix = fmt.Sprintf("Export inventory (1/%d): ", numOfTasks)
 	sp.Start()
 	if all {
-		hosts, err = in.inventory.List()
+		hosts, err = in.inventory.Select(ctx, predicates)
 		if err != nil {
 			return err
 		}
 	} else {
 		for _, host := range selectedHosts {
-			out, err := in.inventory.Get(host)
+			out, err := in.inventory.Get(ctx, host)
 			if err != nil {
 				return err
 			}
 			hosts = append(hosts, out)
 		}
 	}
+	hosts, err = in.inventory.WithCredentials(ctx, hosts...)
+	if err != nil {
+		return err
+	}
 	inventoryTask := NewInventoryExportTask(hosts)
-	err = inventoryTask.Execute()
+	err = inventoryTask.Execute(ctx)
 	if err != nil {
 		return err
 	}
@@ -205,7 +212,7 @@ func (in *ReportGenerator) GenerateAndSaveAs(selectedHosts []string, all bool, m
 		}
 		return result
 	}, zap.NewNop().Sugar())
-	err = ansibleTask.Execute()
+	err = ansibleTask.Execute(ctx)
 	if err != nil {
 		return err
 	}
@@ -254,7 +261,7 @@ func (in *ReportGenerator) GenerateAndSaveAs(selectedHosts []string, all bool, m
 	printMsgOnStop(true)
 	sp.Prefix = fmt.Sprintf("Generate html report (4/%d): ", numOfTasks)
 	sp.Start()
-	cmd := exec.Command("ansible-cmdb", "-i", inventoryFile, outputDir)
+	cmd := exec.CommandContext(ctx, "ansible-cmdb", "-i", inventoryFile, outputDir)
 	tpl, err := cmd.Output()
 	if err != nil {
 		return fmt.Errorf("Could not generate html due to: %v\n\n%s", err, tpl)
@@ -0,0 +1,345 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+)
+
+// RestoreMode decides how a backup is restored over existing data.
+type RestoreMode string
+
+const (
+	// RestoreMerge creates entities absent from storage and keeps existing
+	// ones, which are reported as conflicts if they differ from the backup.
+	RestoreMerge RestoreMode = "merge"
+	// RestoreReplace makes storage identical to the backup. Hosts absent from
+	// the backup are moved into trash bin, and credential profiles absent
+	// from the backup are deleted.
+	RestoreReplace RestoreMode = "replace"
+)
+
+const (
+	restoreKindHost    = "host"
+	restoreKindTrash   = "trashed host"
+	restoreKindProfile = "credential profile"
+	restoreTrashReason = "Replaced by restoring backup"
+
+	// restoreRollbackTimeout bounds deleting a host which could not be
+	// trashed while restoring the trash bin.
+	restoreRollbackTimeout = 10 * time.Second
+)
+
+// RestoreChange is a change made by restoring, or to be made in a dry run.
+// Action is one of 'create', 'update', 'trash' and 'delete'.
+type RestoreChange struct {
+	Kind   string
+	Name   string
+	Action string
+}
+
+// RestoreConflict is an entity of backup which could not be restored.
+type RestoreConflict struct {
+	Kind   string
+	Name   string
+	Reason string
+}
+
+// RestoreReport is the outcome of restoring a backup.
+type RestoreReport struct {
+	Changes   []RestoreChange
+	Unchanged int
+	Conflicts []RestoreConflict
+}
+
+// record runs write unless dryRun, and reports its outcome. Conflicting writes
+// are reported instead of failing the whole restoration.
+func (in *RestoreReport) record(kind, name, action string, dryRun bool, write func() error) error {
+	if !dryRun {
+		switch err := write(); err {
+		case nil:
+		case core.ErrResourceAlreadyExists, core.ErrIndexConflict:
+			in.conflict(kind, name, err.Error())
+			return nil
+		default:
+			return fmt.Errorf("Could not %s %s '%s' due to: %v", action, kind, name, err)
+		}
+	}
+	in.Changes = append(in.Changes, RestoreChange{Kind: kind, Name: name, Action: action})
+	return nil
+}
+
+func (in *RestoreReport) conflict(kind, name, reason string) {
+	in.Conflicts = append(in.Conflicts, RestoreConflict{Kind: kind, Name: name, Reason: reason})
+}
+
+// RestoreBackup loads backup into storage with given mode, preserving GUIDs
+// of hosts. Credential profiles are restored before hosts referencing them.
+// Trashed hosts are put back into the trash bin before hosts are restored, as
+// long as their hostnames are not held by hosts of storage, and they are
+// trashed again with their reasons, so that their retention period starts
+// over. Nothing is written if dryRun is true, while the report is the same.
+// The report is returned even if restoring fails.
+func (in *Inventory) RestoreBackup(ctx context.Context, backup *Backup, mode RestoreMode, dryRun bool) (*RestoreReport, error) {
+	report := new(RestoreReport)
+	if mode != RestoreMerge && mode != RestoreReplace {
+		return report, fmt.Errorf("Unknown restore mode '%s'", mode)
+	}
+	hosts, err := in.Select(ctx, nil)
+	if err != nil {
+		return report, err
+	}
+	trashed, err := in.Storage.ListTrash(ctx)
+	if err != nil {
+		return report, err
+	}
+	profiles, err := in.Storage.ListCredentialProfiles(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	currentProfiles := make(map[string]core.CredentialProfile)
+	for _, each := range profiles {
+		currentProfiles[each.Name] = each
+	}
+	for _, profile := range backup.CredentialProfiles {
+		profile := profile
+		current, ok := currentProfiles[profile.Name]
+		var write func() error
+		action := "create"
+		switch {
+		case !ok:
+			write = func() error { return in.Storage.CreateCredentialProfile(ctx, profile) }
+		case sameProfile(current, profile):
+			report.Unchanged++
+			continue
+		case mode == RestoreMerge:
+			report.conflict(restoreKindProfile, profile.Name, "Existing one differs from backup")
+			continue
+		default:
+			action = "update"
+			write = func() error {
+				return in.Storage.UpdateCredentialProfile(ctx, profile.Name, func(core.CredentialProfile) (core.CredentialProfile, error) {
+					return profile, nil
+				})
+			}
+		}
+		if err = report.record(restoreKindProfile, profile.Name, action, dryRun, write); err != nil {
+			return report, err
+		}
+	}
+
+	currentHosts := make(map[string]core.Host)
+	// hostnames holding values of unique indexes, keyed by index key
+	owners := make(map[string]string)
+	for _, each := range hosts {
+		currentHosts[each.Hostname] = each
+		claimUniqueValues(owners, each, true)
+	}
+	currentTrash := make(map[string]core.Host)
+	for _, each := range trashed {
+		currentTrash[each.Host.Hostname] = each.Host
+	}
+	// hostnames of hosts which are not in trash bin at the moment
+	live := make(map[string]bool)
+	for _, each := range hosts {
+		live[each.Hostname] = true
+	}
+	inBackup := make(map[string]bool)
+	for _, each := range backup.Hosts {
+		inBackup[each.Hostname] = true
+	}
+	if mode == RestoreReplace {
+		// hosts are trashed first, so that their unique values are released
+		for _, each := range hosts {
+			if inBackup[each.Hostname] {
+				continue
+			}
+			hostname := each.Hostname
+			err = report.record(restoreKindHost, hostname, "trash", dryRun, func() error {
+				return in.Storage.TrashHost(ctx, hostname, restoreTrashReason)
+			})
+			if err != nil {
+				return report, err
+			}
+			claimUniqueValues(owners, each, false)
+			delete(live, hostname)
+			currentTrash[hostname] = each
+		}
+	}
+	for _, each := range backup.TrashedHosts {
+		trashedHost := each
+		hostname := trashedHost.Host.Hostname
+		current, ok := currentTrash[hostname]
+		action := "create"
+		switch {
+		case ok && sameHost(current, trashedHost.Host):
+			report.Unchanged++
+			continue
+		case ok && mode == RestoreMerge:
+			report.conflict(restoreKindTrash, hostname, "Existing one differs from backup")
+			continue
+		case ok:
+			action = "update"
+		}
+		if live[hostname] {
+			report.conflict(restoreKindTrash, hostname, "Hostname is held by a host which is not trashed")
+			continue
+		}
+		if reason := uniqueConflict(owners, trashedHost.Host); reason != "" {
+			report.conflict(restoreKindTrash, hostname, reason)
+			continue
+		}
+		// trashing overwrites the trashed host of the same hostname
+		err = report.record(restoreKindTrash, hostname, action, dryRun, func() error {
+			return in.restoreTrashedHost(ctx, trashedHost)
+		})
+		if err != nil {
+			return report, err
+		}
+	}
+	for _, host := range backup.Hosts {
+		host := host
+		current, ok := currentHosts[host.Hostname]
+		var write func() error
+		action := "create"
+		switch {
+		case !ok:
+			write = func() error { return in.Storage.CreateHost(ctx, host) }
+		case sameHost(current, host):
+			report.Unchanged++
+			continue
+		case mode == RestoreMerge:
+			report.conflict(restoreKindHost, host.Hostname, "Existing one differs from backup")
+			continue
+		default:
+			action = "update"
+			write = func() error {
+				return in.Storage.UpdateHost(ctx, host.Hostname, func(core.Host) (core.Host, error) {
+					return host, nil
+				})
+			}
+		}
+		if reason := uniqueConflict(owners, host); reason != "" {
+			report.conflict(restoreKindHost, host.Hostname, reason)
+			continue
+		}
+		if err = report.record(restoreKindHost, host.Hostname, action, dryRun, write); err != nil {
+			return report, err
+		}
+		if ok {
+			claimUniqueValues(owners, current, false)
+		}
+		claimUniqueValues(owners, host, true)
+	}
+
+	if mode == RestoreReplace {
+		inBackup = make(map[string]bool)
+		for _, each := range backup.CredentialProfiles {
+			inBackup[each.Name] = true
+		}
+		for _, each := range profiles {
+			if inBackup[each.Name] {
+				continue
+			}
+			name := each.Name
+			err = report.record(restoreKindProfile, name, "delete", dryRun, func() error {
+				return in.Storage.DeleteCredentialProfile(ctx, name)
+			})
+			if err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
+
+// restoreTrashedHost puts trashed back into the trash bin by creating and
+// trashing the host. If trashing fails, e.g. as ctx is done in between, the
+// host is deleted again, so that a deleted host never comes back as a live
+// one.
+func (in *Inventory) restoreTrashedHost(ctx context.Context, trashed core.TrashedHost) error {
+	hostname := trashed.Host.Hostname
+	if err := in.Storage.CreateHost(ctx, trashed.Host); err != nil {
+		return err
+	}
+	err := in.Storage.TrashHost(ctx, hostname, trashed.Reason)
+	if err == nil {
+		return nil
+	}
+	// ctx may be done already, which must not stop rolling back
+	rollbackCtx, cancel := context.WithTimeout(context.Background(), restoreRollbackTimeout)
+	defer cancel()
+	if derr := in.Storage.DeleteHost(rollbackCtx, hostname); derr != nil {
+		return fmt.Errorf("%v, while host '%s' created for trashing could not be deleted due to: %v", err, hostname, derr)
+	}
+	return err
+}
+
+// claimUniqueValues records host as the owner of its unique values, or
+// releases them if claim is false.
+func claimUniqueValues(owners map[string]string, host core.Host, claim bool) {
+	for _, entry := range core.HostIndexEntries(host) {
+		if !entry.Field.Unique() {
+			continue
+		}
+		if claim {
+			owners[entry.Key()] = host.Hostname
+		} else if owners[entry.Key()] == host.Hostname {
+			delete(owners, entry.Key())
+		}
+	}
+}
+
+// uniqueConflict describes the unique value of host held by another host, or
+// returns an empty string if there is none.
+func uniqueConflict(owners map[string]string, host core.Host) string {
+	for _, entry := range core.HostIndexEntries(host) {
+		if !entry.Field.Unique() {
+			continue
+		}
+		if owner, ok := owners[entry.Key()]; ok && owner != host.Hostname {
+			return fmt.Sprintf("Value '%s' of %s is held by host '%s'", entry.Value, entry.Field, owner)
+		}
+	}
+	return ""
+}
+
+// sameHost compares hosts regardless of when and how they were stored.
+func sameHost(a, b core.Host) bool {
+	a.UpdatedAt = b.UpdatedAt
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(x, y)
+}
+
+// sameProfile compares credential profiles regardless of when they were
+// stored.
+func sameProfile(a, b core.CredentialProfile) bool {
+	a.UpdatedAt = b.UpdatedAt
+	return a == b
+}
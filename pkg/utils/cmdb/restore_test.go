@@ -0,0 +1,97 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	memory "github.com/universonic/ivy-utils/pkg/storage/memory"
+	zap "go.uber.org/zap"
+)
+
+func openMemory(t *testing.T) core.Storage {
+	storage, err := memory.New().Open(zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage
+}
+
+// failingTrash fails to trash hosts.
+type failingTrash struct {
+	core.Storage
+}
+
+func (failingTrash) TrashHost(ctx context.Context, id, reason string) error {
+	return errors.New("Trash bin is unavailable")
+}
+
+// interruptedCreate cancels the context of restoring once a host is created,
+// as if Ctrl-C was pressed right after.
+type interruptedCreate struct {
+	core.Storage
+	cancel context.CancelFunc
+}
+
+func (in interruptedCreate) CreateHost(ctx context.Context, host core.Host) error {
+	err := in.Storage.CreateHost(ctx, host)
+	in.cancel()
+	return err
+}
+
+func TestRestoreTrashedHostRollsBack(t *testing.T) {
+	backup := &Backup{
+		TrashedHosts: []core.TrashedHost{
+			{Host: core.Host{Hostname: "node-01", IPMIAddress: "10.0.0.1"}, DeletedAt: time.Now().UTC(), Reason: "Decommissioned"},
+		},
+	}
+	cases := []struct {
+		name string
+		wrap func(core.Storage, context.CancelFunc) core.Storage
+	}{
+		{"trashing fails", func(storage core.Storage, _ context.CancelFunc) core.Storage {
+			return failingTrash{storage}
+		}},
+		{"interrupted", func(storage core.Storage, cancel context.CancelFunc) core.Storage {
+			return interruptedCreate{storage, cancel}
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			storage := openMemory(t)
+			defer storage.Close()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			inventory := NewInventoryFromStorage(c.wrap(storage, cancel))
+			if _, err := inventory.RestoreBackup(ctx, backup, RestoreMerge, false); err == nil {
+				t.Fatal("Restoring succeeded although the host could not be trashed")
+			}
+			if _, err := storage.GetHost(context.Background(), "node-01"); err != core.ErrResourceNotFound {
+				t.Fatalf("Expected host created for trashing to be deleted, got error: %v", err)
+			}
+			trashed, err := storage.ListTrash(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(trashed) != 0 {
+				t.Fatalf("Expected empty trash bin, got %d trashed hosts", len(trashed))
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ package cmdb
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -25,6 +26,7 @@ import (
 	"strings"
 
 	core "github.com/universonic/ivy-utils/pkg/storage/core"
+	secret "github.com/universonic/ivy-utils/pkg/utils/secret"
 	zap "go.uber.org/zap"
 )
 
@@ -35,11 +37,11 @@ type ParallelTasks struct {
 	logger   *zap.SugaredLogger
 }
 
-func (in *ParallelTasks) Execute() (err error) {
+func (in *ParallelTasks) Execute(ctx context.Context) (err error) {
 	defer close(in.errChan)
 	for _, task := range in.tasks {
 		go func(t Task) {
-			if e := t.Execute(); e != nil {
+			if e := t.Execute(ctx); e != nil {
 				in.errChan <- e
 			} else {
 				in.errChan <- nil
@@ -78,7 +80,7 @@ func NewParallelTasks(tasks []Task, exportFn func() interface{}, logger *zap.Sug
 }
 
 type Task interface {
-	Execute() error
+	Execute(ctx context.Context) error
 	GetResult() interface{}
 }
 
@@ -89,12 +91,12 @@ type AnsibleTask struct {
 	Result        map[string][]byte
 }
 
-func (in *AnsibleTask) Execute() error {
+func (in *AnsibleTask) Execute(ctx context.Context) error {
 	dir, err := ioutil.TempDir("", "")
 	if err != nil {
 		panic(err)
 	}
-	cmd := exec.Command("ansible", "all", "-m", in.Module, "-i", in.InventoryFile, "-t", dir)
+	cmd := exec.CommandContext(ctx, "ansible", "all", "-m", in.Module, "-i", in.InventoryFile, "-t", dir)
 	reason, err := cmd.Output()
 	if err != nil && in.required {
 		parsedReason := bytes.Split(reason, []byte("\n"))
@@ -151,7 +153,7 @@ type InventoryExportTask struct {
 	Result string
 }
 
-func (in *InventoryExportTask) Execute() (err error) {
+func (in *InventoryExportTask) Execute(ctx context.Context) (err error) {
 	var rst string
 	for _, each := range in.Hosts {
 		var sshAddr string
@@ -162,7 +164,11 @@ func (in *InventoryExportTask) Execute() (err error) {
 		}
 		rst += fmt.Sprintf("%s ansible_connection=\"smart\" ansible_host=\"%s\" ansible_port=%d ansible_user=\"%s\"",
 			each.Hostname, sshAddr, each.SSHPort, each.SSHUser)
-		if each.IPMIAddress != "" && each.IPMIUser != "" && each.IPMIPassword != "" {
+		// Secret references are passed as is, so that secrets never land in
+		// the inventory file and are resolved by the ipmi action instead.
+		if each.IPMIAddress != "" && each.IPMIUser != "" && each.IPMISecret != "" {
+			rst += fmt.Sprintf(" ipmi_addr=\"%s\" ipmi_user=\"%s\" ipmi_secret=\"%s\"", each.IPMIAddress, each.IPMIUser, each.IPMISecret)
+		} else if each.IPMIAddress != "" && each.IPMIUser != "" && each.IPMIPassword != "" {
 			rst += fmt.Sprintf(" ipmi_addr=\"%s\" ipmi_user=\"%s\" ipmi_pass=\"%s\"", each.IPMIAddress, each.IPMIUser, each.IPMIPassword)
 		}
 		for k, v := range each.ExtraInfo {
@@ -202,11 +208,15 @@ type RacadmCommandTask struct {
 	Result     map[string]string
 }
 
-func (in *RacadmCommandTask) Execute() (err error) {
+func (in *RacadmCommandTask) Execute(ctx context.Context) (err error) {
 	if in.Host.IPMIAddress == "" {
 		return fmt.Errorf("Given host's IPMI address was not allocated")
 	}
-	cmd := exec.Command("racadm", "-r", in.Host.IPMIAddress, "-u", in.Host.IPMIUser, "-p", in.Host.IPMIPassword, "--nocertwarn", in.Subcommand, strings.Join(in.Namespace, "."))
+	password, err := resolveIPMIPassword(ctx, in.Host)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "racadm", "-r", in.Host.IPMIAddress, "-u", in.Host.IPMIUser, "-p", password, "--nocertwarn", in.Subcommand, strings.Join(in.Namespace, "."))
 	if in.Param != "" {
 		cmd.Args = append(cmd.Args, in.Param)
 	}
@@ -235,6 +245,15 @@ func (in *RacadmCommandTask) GetResult() interface{} {
 	return in.Result
 }
 
+// resolveIPMIPassword returns the IPMI password of given host, reading the
+// referenced secret if there is one.
+func resolveIPMIPassword(ctx context.Context, host core.Host) (string, error) {
+	if host.IPMISecret == "" {
+		return host.IPMIPassword, nil
+	}
+	return secret.Resolve(ctx, host.IPMISecret)
+}
+
 func NewRacadmCommandTask(subcommand string, host core.Host, param string, namespace ...string) *RacadmCommandTask {
 	return &RacadmCommandTask{
 		Subcommand: subcommand,
@@ -0,0 +1,147 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Supported schemes of secret references.
+const (
+	SchemeFile = "file"
+	SchemeEnv  = "env"
+	SchemeExec = "exec"
+)
+
+// ExecAllowlistEnv names the environment variable which lists absolute paths
+// of commands that 'exec:' references may run, separated like PATH, e.g.
+// '/usr/local/bin/bmc-pass:/opt/vault/bin/bmc-secret'. References are kept in
+// the CMDB, where the command is chosen by whoever may write hosts or
+// credential profiles, thus 'exec:' references are refused unless their
+// command is allowed by the machine resolving them.
+const ExecAllowlistEnv = "IVY_SECRET_EXEC_ALLOWLIST"
+
+// ExecTimeout bounds the time a command referenced by an 'exec:' secret may
+// run before it is killed.
+var ExecTimeout = 10 * time.Second
+
+// Ref is a parsed reference to a secret kept outside of the storage.
+type Ref struct {
+	Scheme string
+	// Target is the file path, the environment variable name or the command
+	// line, depending on Scheme.
+	Target string
+}
+
+// Parse parses given reference, which is one of 'file:///path/to/secret',
+// 'env:VARIABLE_NAME' or 'exec:/path/to/command [args...]'. Whether the
+// command of an 'exec:' reference may run is decided by Resolve.
+func Parse(ref string) (Ref, error) {
+	i := strings.Index(ref, ":")
+	if i <= 0 {
+		return Ref{}, fmt.Errorf("Invalid secret reference '%s': scheme is missing", ref)
+	}
+	r := Ref{Scheme: ref[:i], Target: ref[i+1:]}
+	switch r.Scheme {
+	case SchemeFile:
+		u, err := url.Parse(ref)
+		if err != nil {
+			return r, fmt.Errorf("Invalid secret reference '%s': %v", ref, err)
+		}
+		if u.Host != "" && u.Host != "localhost" {
+			return r, fmt.Errorf("Invalid secret reference '%s': only local files are supported", ref)
+		}
+		r.Target = u.Path
+	case SchemeEnv:
+	case SchemeExec:
+		r.Target = strings.TrimSpace(r.Target)
+	default:
+		return r, fmt.Errorf("Invalid secret reference '%s': unsupported scheme '%s'", ref, r.Scheme)
+	}
+	if r.Target == "" {
+		return r, fmt.Errorf("Invalid secret reference '%s': target is missing", ref)
+	}
+	return r, nil
+}
+
+// Resolve reads the secret referenced by ref. Trailing line breaks of files
+// and command outputs are stripped. Commands run only if they are listed by
+// ExecAllowlistEnv, and are killed when ctx is done or ExecTimeout elapses,
+// whichever comes first.
+func Resolve(ctx context.Context, ref string) (string, error) {
+	r, err := Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	var out []byte
+	switch r.Scheme {
+	case SchemeFile:
+		out, err = ioutil.ReadFile(r.Target)
+		if err != nil {
+			return "", fmt.Errorf("Could not read secret '%s' due to: %v", ref, err)
+		}
+	case SchemeEnv:
+		v, ok := os.LookupEnv(r.Target)
+		if !ok {
+			return "", fmt.Errorf("Could not read secret '%s': environment variable is not set", ref)
+		}
+		out = []byte(v)
+	case SchemeExec:
+		args := strings.Fields(r.Target)
+		if !execAllowed(args[0]) {
+			return "", fmt.Errorf("Could not read secret '%s': command '%s' is not allowed by %s", ref, args[0], ExecAllowlistEnv)
+		}
+		ctx, cancel := context.WithTimeout(ctx, ExecTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Stderr = os.Stderr
+		out, err = cmd.Output()
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("Could not read secret '%s': command did not finish in time", ref)
+		}
+		if err != nil {
+			return "", fmt.Errorf("Could not read secret '%s' due to: %v", ref, err)
+		}
+	}
+	out = bytes.TrimRight(out, "\r\n")
+	if len(out) == 0 {
+		return "", fmt.Errorf("Secret '%s' is empty", ref)
+	}
+	return string(out), nil
+}
+
+// execAllowed tells whether command is listed by ExecAllowlistEnv. Only
+// absolute paths are allowed, so that the command could not be looked up in
+// PATH.
+func execAllowed(command string) bool {
+	if !filepath.IsAbs(command) {
+		return false
+	}
+	for _, each := range filepath.SplitList(os.Getenv(ExecAllowlistEnv)) {
+		if filepath.IsAbs(each) && filepath.Clean(each) == filepath.Clean(command) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+// Copyright © 2018 Alfred Chou <unioverlord@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestResolveExecAllowlist(t *testing.T) {
+	defer os.Unsetenv(ExecAllowlistEnv)
+	ref := "exec:/bin/echo s3cret"
+
+	os.Unsetenv(ExecAllowlistEnv)
+	if _, err := Resolve(context.Background(), ref); err == nil || !strings.Contains(err.Error(), ExecAllowlistEnv) {
+		t.Fatalf("Expected command to be refused without allowlist, got: %v", err)
+	}
+
+	os.Setenv(ExecAllowlistEnv, "/usr/bin/true"+string(os.PathListSeparator)+"/bin/echo")
+	value, err := Resolve(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "s3cret" {
+		t.Errorf("Expected 's3cret', got '%s'", value)
+	}
+
+	// commands are matched by absolute path, never looked up in PATH
+	os.Setenv(ExecAllowlistEnv, "echo")
+	if _, err = Resolve(context.Background(), "exec:echo s3cret"); err == nil {
+		t.Error("Expected relative command to be refused")
+	}
+	if _, err = Resolve(context.Background(), "exec:/bin/../bin/cat /etc/hostname"); err == nil {
+		t.Error("Expected command not listed to be refused")
+	}
+}